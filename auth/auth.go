@@ -0,0 +1,111 @@
+// Package auth provides server interceptors authenticating callers through
+// bearer tokens or TLS client certificates, and client credentials attaching
+// tokens to requests.
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"github.com/arf-rpc/arf-go"
+	"github.com/arf-rpc/arf-go/status"
+	"strings"
+)
+
+// MetadataKey is the metadata key carrying bearer tokens, in the form
+// "Bearer <token>".
+const MetadataKey = "authorization"
+
+const bearerPrefix = "Bearer "
+
+// TokenVerifier validates a bearer token, returning the principal it
+// identifies. Returning a *status.BadStatus, such as one with the
+// PermissionDenied code, rejects the call with it; other errors reject it as
+// Unauthenticated.
+type TokenVerifier func(ctx context.Context, token string) (principal any, err error)
+
+// CertificateVerifier validates the certificate chain presented by a client,
+// returning the principal it identifies. chain starts with the client
+// certificate, and was already verified against the server's ClientCAs when
+// the server requires it. Errors are handled as in TokenVerifier.
+type CertificateVerifier func(ctx context.Context, chain []*x509.Certificate) (principal any, err error)
+
+// Authorizer decides whether principal may call method of service.
+type Authorizer func(ctx context.Context, principal any, service, method string) bool
+
+type principalKey struct{}
+
+// NewContext returns a copy of ctx carrying principal.
+func NewContext(ctx context.Context, principal any) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal stored by an authentication
+// interceptor.
+func PrincipalFromContext(ctx context.Context) (any, bool) {
+	p := ctx.Value(principalKey{})
+	return p, p != nil
+}
+
+func reject(err error) error {
+	var bad *status.BadStatus
+	if errors.As(err, &bad) {
+		return err
+	}
+	return &status.BadStatus{Code: status.Unauthenticated, Message: err.Error()}
+}
+
+// BearerToken returns an interceptor authenticating calls through the token
+// carried by the MetadataKey metadata. Calls lacking a token are rejected as
+// Unauthenticated.
+func BearerToken(verify TokenVerifier) arf.Interceptor {
+	return func(ctx context.Context, req arf.Context, next arf.Interceptor) error {
+		value, ok := req.Request().Metadata.LookupString(MetadataKey)
+		if !ok || !strings.HasPrefix(value, bearerPrefix) {
+			return status.Error(status.Unauthenticated, "missing bearer token")
+		}
+
+		principal, err := verify(ctx, strings.TrimSpace(strings.TrimPrefix(value, bearerPrefix)))
+		if err != nil {
+			return reject(err)
+		}
+		return next(NewContext(ctx, principal), req, nil)
+	}
+}
+
+// ClientCertificate returns an interceptor authenticating calls through the
+// certificate presented by the client during the TLS handshake. Calls made
+// over connections lacking a client certificate are rejected as
+// Unauthenticated.
+func ClientCertificate(verify CertificateVerifier) arf.Interceptor {
+	return func(ctx context.Context, req arf.Context, next arf.Interceptor) error {
+		peer, ok := arf.PeerFromContext(ctx)
+		if !ok || peer.TLS == nil || len(peer.TLS.PeerCertificates) == 0 {
+			return status.Error(status.Unauthenticated, "missing client certificate")
+		}
+
+		principal, err := verify(ctx, peer.TLS.PeerCertificates)
+		if err != nil {
+			return reject(err)
+		}
+		return next(NewContext(ctx, principal), req, nil)
+	}
+}
+
+// Authorize returns an interceptor rejecting calls as PermissionDenied
+// unless allowed approves them. It must be registered after an
+// authentication interceptor; calls lacking a principal are rejected as
+// Unauthenticated.
+func Authorize(allowed Authorizer) arf.Interceptor {
+	return func(ctx context.Context, req arf.Context, next arf.Interceptor) error {
+		principal, ok := PrincipalFromContext(ctx)
+		if !ok {
+			return status.Error(status.Unauthenticated, "call is not authenticated")
+		}
+		r := req.Request()
+		if !allowed(ctx, principal, r.Service, r.Method) {
+			return status.Error(status.PermissionDenied, "permission denied")
+		}
+		return next(ctx, req, nil)
+	}
+}
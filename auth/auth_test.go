@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/arf-rpc/arf-go"
+	"github.com/arf-rpc/arf-go/internal/testpki"
+	"github.com/arf-rpc/arf-go/rpc"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+)
+
+func makeServer(t *testing.T, opts arf.ServerOptions, interceptors ...arf.Interceptor) (arf.Server, net.Listener) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server, err := arf.NewServer(l, opts)
+	require.NoError(t, err)
+	go func() { _ = server.Serve() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	server.RegisterInterceptor(interceptors...)
+	server.MustRegisterService(arf.ServiceAdapter{
+		ServiceID: "org.example.test/Users",
+		Methods: map[string]arf.ServiceExecutor{
+			"WhoAmI": func(ctx context.Context, c arf.Context) error {
+				principal, _ := PrincipalFromContext(ctx)
+				return c.SendResponse(status.OK, []any{principal}, false, nil)
+			},
+			"Delete": func(ctx context.Context, c arf.Context) error {
+				return c.SendResponse(status.OK, nil, false, nil)
+			},
+		},
+	})
+	return server, l
+}
+
+func makeClient(t *testing.T, interceptors ...arf.Interceptor) arf.Client {
+	t.Helper()
+
+	server, _ := makeServer(t, arf.ServerOptions{}, interceptors...)
+	client, err := server.InProcessClient()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// makeTLSClient serves over TLS, verifying client certificates issued by
+// pki when presented, and dials the server presenting cert, unless nil.
+func makeTLSClient(t *testing.T, pki *testpki.PKI, cert *tls.Certificate, opts []arf.ClientOption, interceptors ...arf.Interceptor) arf.Client {
+	t.Helper()
+
+	serverCert, err := pki.Issue("server", "127.0.0.1")
+	require.NoError(t, err)
+	_, l := makeServer(t, arf.ServerOptions{TLSConfig: &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pki.Pool,
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+	}}, interceptors...)
+
+	cfg := &tls.Config{RootCAs: pki.Pool}
+	if cert != nil {
+		cfg.Certificates = []tls.Certificate{*cert}
+	}
+	client, err := arf.Dial(l.Addr().String(), append(opts, arf.WithTLSConfig(cfg))...)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func verifyToken(_ context.Context, token string) (any, error) {
+	switch token {
+	case "alice-token":
+		return "alice", nil
+	case "bob-token":
+		return "bob", nil
+	case "suspended-token":
+		return nil, status.Error(status.PermissionDenied, "account suspended")
+	}
+	return nil, errors.New("invalid token")
+}
+
+func callStatus(t *testing.T, client arf.Client, method string, opts ...arf.CallOption) (status.Status, []any) {
+	t.Helper()
+
+	call, err := client.Call(context.Background(), "org.example.test/Users", method, opts...)
+	require.NoError(t, err)
+	resp := call.Response()
+	return status.Status(resp.Status), resp.Params
+}
+
+func TestBearerToken(t *testing.T) {
+	client := makeClient(t, BearerToken(verifyToken))
+
+	t.Run("credentials attach the token", func(t *testing.T) {
+		code, params := callStatus(t, client, "WhoAmI",
+			arf.WithCallCredentials(TokenCredentials(StaticToken("alice-token"))))
+		assert.Equal(t, status.OK, code)
+		assert.Equal(t, []any{"alice"}, params)
+	})
+
+	t.Run("calls without a token are unauthenticated", func(t *testing.T) {
+		code, _ := callStatus(t, client, "WhoAmI")
+		assert.Equal(t, status.Unauthenticated, code)
+	})
+
+	t.Run("invalid tokens are unauthenticated", func(t *testing.T) {
+		code, _ := callStatus(t, client, "WhoAmI",
+			arf.WithMetadata(rpc.MetadataFromStringPairs(MetadataKey, "Bearer nope")))
+		assert.Equal(t, status.Unauthenticated, code)
+	})
+
+	t.Run("verifier statuses are returned as is", func(t *testing.T) {
+		code, _ := callStatus(t, client, "WhoAmI",
+			arf.WithCallCredentials(TokenCredentials(StaticToken("suspended-token"))))
+		assert.Equal(t, status.PermissionDenied, code)
+	})
+
+	t.Run("credentials replace existing metadata", func(t *testing.T) {
+		code, params := callStatus(t, client, "WhoAmI",
+			arf.WithMetadata(rpc.MetadataFromStringPairs(MetadataKey, "Bearer nope")),
+			arf.WithCallCredentials(TokenCredentials(StaticToken("bob-token"))))
+		assert.Equal(t, status.OK, code)
+		assert.Equal(t, []any{"bob"}, params)
+	})
+}
+
+func TestAuthorize(t *testing.T) {
+	client := makeClient(t, BearerToken(verifyToken), Authorize(func(_ context.Context, principal any, _, method string) bool {
+		return method != "Delete" || principal == "alice"
+	}))
+
+	code, _ := callStatus(t, client, "Delete", arf.WithCallCredentials(TokenCredentials(StaticToken("alice-token"))))
+	assert.Equal(t, status.OK, code)
+
+	code, _ = callStatus(t, client, "Delete", arf.WithCallCredentials(TokenCredentials(StaticToken("bob-token"))))
+	assert.Equal(t, status.PermissionDenied, code)
+
+	code, _ = callStatus(t, client, "WhoAmI", arf.WithCallCredentials(TokenCredentials(StaticToken("bob-token"))))
+	assert.Equal(t, status.OK, code)
+}
+
+func TestClientCertificate(t *testing.T) {
+	pki, err := testpki.New()
+	require.NoError(t, err)
+	interceptor := ClientCertificate(func(_ context.Context, chain []*x509.Certificate) (any, error) {
+		if name := chain[0].Subject.CommonName; name != "mallory" {
+			return name, nil
+		}
+		return nil, errors.New("certificate revoked")
+	})
+	issue := func(t *testing.T, name string) *tls.Certificate {
+		cert, err := pki.Issue(name)
+		require.NoError(t, err)
+		return &cert
+	}
+
+	t.Run("connections without certificates are unauthenticated", func(t *testing.T) {
+		client := makeTLSClient(t, pki, nil, nil, interceptor)
+		code, _ := callStatus(t, client, "WhoAmI")
+		assert.Equal(t, status.Unauthenticated, code)
+	})
+
+	t.Run("the verified identity becomes the principal", func(t *testing.T) {
+		client := makeTLSClient(t, pki, issue(t, "alice"), nil, interceptor)
+		code, params := callStatus(t, client, "WhoAmI")
+		assert.Equal(t, status.OK, code)
+		assert.Equal(t, []any{"alice"}, params)
+	})
+
+	t.Run("certificates rejected by the verifier are unauthenticated", func(t *testing.T) {
+		client := makeTLSClient(t, pki, issue(t, "mallory"), nil, interceptor)
+		code, _ := callStatus(t, client, "WhoAmI")
+		assert.Equal(t, status.Unauthenticated, code)
+	})
+}
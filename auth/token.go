@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"github.com/arf-rpc/arf-go"
+	"github.com/arf-rpc/arf-go/rpc"
+	"sync"
+	"time"
+)
+
+// Token is a bearer token, optionally expiring at Expiry.
+type Token struct {
+	Value  string
+	Expiry time.Time
+}
+
+// TokenSource provides tokens attached to requests.
+type TokenSource interface {
+	Token(ctx context.Context) (Token, error)
+}
+
+// TokenSourceFunc adapts a function into a TokenSource.
+type TokenSourceFunc func(ctx context.Context) (Token, error)
+
+func (f TokenSourceFunc) Token(ctx context.Context) (Token, error) {
+	return f(ctx)
+}
+
+// StaticToken returns a TokenSource always providing value.
+func StaticToken(value string) TokenSource {
+	return TokenSourceFunc(func(context.Context) (Token, error) {
+		return Token{Value: value}, nil
+	})
+}
+
+type refreshingSource struct {
+	src    TokenSource
+	margin time.Duration
+
+	mu    sync.Mutex
+	token Token
+	valid bool
+}
+
+// RefreshingTokenSource caches tokens obtained from src, requesting a new
+// one once the cached token is within margin of its expiry. Tokens without
+// an expiry are cached indefinitely.
+func RefreshingTokenSource(src TokenSource, margin time.Duration) TokenSource {
+	return &refreshingSource{src: src, margin: margin}
+}
+
+func (r *refreshingSource) Token(ctx context.Context) (Token, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.valid && (r.token.Expiry.IsZero() || time.Now().Add(r.margin).Before(r.token.Expiry)) {
+		return r.token, nil
+	}
+
+	token, err := r.src.Token(ctx)
+	if err != nil {
+		return Token{}, err
+	}
+	r.token, r.valid = token, true
+	return token, nil
+}
+
+type tokenCredentials struct {
+	src TokenSource
+}
+
+// TokenCredentials returns credentials setting the MetadataKey metadata of
+// every request to a bearer token obtained from src. Use them through
+// arf.WithCredentials or arf.WithCallCredentials.
+func TokenCredentials(src TokenSource) arf.PerCallCredentials {
+	return &tokenCredentials{src: src}
+}
+
+func (t *tokenCredentials) RequestMetadata(ctx context.Context, _, _ string) (rpc.Metadata, error) {
+	token, err := t.src.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return rpc.MetadataFromStringPairs(MetadataKey, bearerPrefix+token.Value), nil
+}
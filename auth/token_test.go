@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"github.com/arf-rpc/arf-go"
+	"github.com/arf-rpc/arf-go/internal/testpki"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefreshingTokenSource(t *testing.T) {
+	pki, err := testpki.New()
+	require.NoError(t, err)
+	verify := func(_ context.Context, token string) (any, error) {
+		return token, nil
+	}
+
+	// makeClient returns a client authenticating through a
+	// RefreshingTokenSource, along with the amount of tokens it fetched.
+	makeClient := func(t *testing.T, validity time.Duration) (arf.Client, *atomic.Int32) {
+		var fetched atomic.Int32
+		src := RefreshingTokenSource(TokenSourceFunc(func(context.Context) (Token, error) {
+			n := fetched.Add(1)
+			token := Token{Value: "token-" + strconv.Itoa(int(n))}
+			if validity > 0 {
+				token.Expiry = time.Now().Add(validity)
+			}
+			return token, nil
+		}), time.Minute)
+
+		opts := []arf.ClientOption{arf.WithCredentials(TokenCredentials(src))}
+		return makeTLSClient(t, pki, nil, opts, BearerToken(verify)), &fetched
+	}
+	whoAmI := func(t *testing.T, client arf.Client) any {
+		t.Helper()
+		code, params := callStatus(t, client, "WhoAmI")
+		require.Equal(t, status.OK, code)
+		return params[0]
+	}
+
+	t.Run("tokens are refreshed once within margin of their expiry", func(t *testing.T) {
+		client, fetched := makeClient(t, time.Minute+300*time.Millisecond)
+
+		assert.Equal(t, "token-1", whoAmI(t, client))
+		assert.Equal(t, "token-1", whoAmI(t, client))
+
+		time.Sleep(350 * time.Millisecond)
+		assert.Equal(t, "token-2", whoAmI(t, client))
+		assert.Equal(t, "token-2", whoAmI(t, client))
+		assert.Equal(t, int32(2), fetched.Load())
+	})
+
+	t.Run("tokens without an expiry are cached indefinitely", func(t *testing.T) {
+		client, fetched := makeClient(t, 0)
+
+		for range 3 {
+			assert.Equal(t, "token-1", whoAmI(t, client))
+		}
+		assert.Equal(t, int32(1), fetched.Load())
+	})
+}
@@ -0,0 +1,61 @@
+package arf
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff computes delays between successive connection attempts. Fields
+// left zero take their value from DefaultBackoff.
+type Backoff struct {
+	// BaseDelay is the delay before the first retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the computed delay.
+	MaxDelay time.Duration
+	// Multiplier is applied to the delay after each failed attempt.
+	Multiplier float64
+	// Jitter randomizes delays by up to the given fraction, in either
+	// direction. A negative Jitter disables it.
+	Jitter float64
+}
+
+// DefaultBackoff provides the values of fields left zero in a Backoff.
+var DefaultBackoff = Backoff{
+	BaseDelay:  100 * time.Millisecond,
+	MaxDelay:   30 * time.Second,
+	Multiplier: 1.6,
+	Jitter:     0.2,
+}
+
+// Delay returns the amount of time to wait before the given attempt, where
+// zero is the first retry.
+func (b Backoff) Delay(attempt int) time.Duration {
+	if b.BaseDelay == 0 {
+		b.BaseDelay = DefaultBackoff.BaseDelay
+	}
+	if b.MaxDelay == 0 {
+		b.MaxDelay = DefaultBackoff.MaxDelay
+	}
+	if b.Multiplier == 0 {
+		b.Multiplier = DefaultBackoff.Multiplier
+	}
+	if b.Jitter == 0 {
+		b.Jitter = DefaultBackoff.Jitter
+	}
+	if b.Multiplier < 1 {
+		b.Multiplier = 1
+	}
+
+	delay := float64(b.BaseDelay) * math.Pow(b.Multiplier, float64(attempt))
+	if delay > float64(b.MaxDelay) {
+		delay = float64(b.MaxDelay)
+	}
+	if b.Jitter > 0 {
+		delay *= 1 + b.Jitter*(rand.Float64()*2-1)
+	}
+	if delay < 0 {
+		return 0
+	}
+	return time.Duration(delay)
+}
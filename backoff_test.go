@@ -0,0 +1,15 @@
+package arf
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	t.Run("zero fields take their default", func(t *testing.T) {
+		b := Backoff{MaxDelay: time.Second, Jitter: -1}
+		assert.Equal(t, DefaultBackoff.BaseDelay, b.Delay(0))
+		assert.Equal(t, time.Second, b.Delay(20))
+	})
+}
@@ -0,0 +1,171 @@
+// Package channelz exposes the connections established with a server and
+// their streams, for debugging purposes. They can be inspected in process
+// through Connections, remotely through the service installed by Register,
+// or through the page served by Handler.
+package channelz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/arf-rpc/arf-go"
+	"github.com/arf-rpc/arf-go/proto"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/arf-rpc/arf-go/wire"
+	"time"
+)
+
+// ServiceID identifies the service installed by Register.
+const ServiceID = "arf.channelz/Channelz"
+
+// Connection describes a connection established with a server.
+type Connection struct {
+	ID          uint64 `arf:"0"`
+	RemoteAddr  string `arf:"1"`
+	LocalAddr   string `arf:"2"`
+	Compression string `arf:"3"`
+	TLS         bool   `arf:"4"`
+	// EstablishedAt is expressed in nanoseconds since the Unix epoch.
+	EstablishedAt int64 `arf:"5"`
+	// LastPingRTT is expressed in nanoseconds, and is zero until the
+	// connection is pinged.
+	LastPingRTT    int64    `arf:"6"`
+	StreamsStarted uint64   `arf:"7"`
+	StreamsActive  uint64   `arf:"8"`
+	Streams        []Stream `arf:"9"`
+}
+
+func (Connection) ArfStructID() string { return "arf.channelz/Connection" }
+
+// Age returns the time elapsed since the connection was established.
+func (c Connection) Age() time.Duration {
+	return time.Since(time.Unix(0, c.EstablishedAt))
+}
+
+// Stream describes a stream of a connection.
+type Stream struct {
+	ID uint32 `arf:"0"`
+	// State is either "open", "half-closed (local)" or "half-closed
+	// (remote)".
+	State string `arf:"1"`
+	// Buffered is the amount of bytes received and not yet read.
+	Buffered  uint64 `arf:"2"`
+	RequestID string `arf:"3"`
+	Service   string `arf:"4"`
+	Method    string `arf:"5"`
+}
+
+func (Stream) ArfStructID() string { return "arf.channelz/Stream" }
+
+func init() {
+	proto.RegisterMessage(Connection{})
+	proto.RegisterMessage(Stream{})
+}
+
+// Connections describes the connections currently established with s.
+func Connections(s arf.Server) []Connection {
+	statuses := s.Connections()
+	conns := make([]Connection, len(statuses))
+	for i, st := range statuses {
+		conns[i] = fromStatus(st)
+	}
+	return conns
+}
+
+func fromStatus(st arf.ConnectionStatus) Connection {
+	c := Connection{
+		ID:             uint64(st.ID),
+		Compression:    st.Compression.String(),
+		TLS:            st.TLS != nil,
+		EstablishedAt:  st.EstablishedAt.UnixNano(),
+		LastPingRTT:    int64(st.LastPingRTT),
+		StreamsStarted: uint64(st.StreamsStarted),
+		StreamsActive:  uint64(st.StreamsActive),
+		Streams:        make([]Stream, len(st.Streams)),
+	}
+	if st.RemoteAddr != nil {
+		c.RemoteAddr = st.RemoteAddr.String()
+	}
+	if st.LocalAddr != nil {
+		c.LocalAddr = st.LocalAddr.String()
+	}
+	for i, str := range st.Streams {
+		c.Streams[i] = Stream{
+			ID:        str.ID,
+			State:     str.State,
+			Buffered:  uint64(str.Buffered),
+			RequestID: str.ExternalID,
+			Service:   str.Service,
+			Method:    str.Method,
+		}
+	}
+	return c
+}
+
+// Register installs the channelz service on s. Its ListConnections method
+// responds with the result of Connections, while Ping pings the connection
+// whose ID is given as parameter, responding with the round-trip time in
+// nanoseconds. Restrict access to the service through interceptors, as it
+// discloses the addresses of every client.
+func Register(s arf.Server) error {
+	return s.RegisterService(arf.ServiceAdapter{
+		ServiceID: ServiceID,
+		Methods: map[string]arf.ServiceExecutor{
+			"ListConnections": func(ctx context.Context, c arf.Context) error {
+				return c.SendResponse(status.OK, []any{Connections(s)}, false, nil)
+			},
+			"Ping": func(ctx context.Context, c arf.Context) error {
+				params := c.Request().Params
+				if len(params) != 1 {
+					return status.Error(status.InvalidArgument, "expected a connection ID")
+				}
+				id, err := proto.Convert[uint64](params[0])
+				if err != nil {
+					return status.Convert(err)
+				}
+				rtt, err := s.PingConnection(ctx, int(id))
+				if errors.Is(err, wire.UnknownConnErr) {
+					return status.Error(status.NotFound, fmt.Sprintf("connection %d not found", id))
+				} else if err != nil {
+					return status.Error(status.Unavailable, err.Error())
+				}
+				return c.SendResponse(status.OK, []any{uint64(rtt)}, false, nil)
+			},
+		},
+	})
+}
+
+// ListConnections calls the ListConnections method of the channelz service
+// through c.
+func ListConnections(ctx context.Context, c arf.Client) ([]Connection, error) {
+	call, err := c.Call(ctx, ServiceID, "ListConnections")
+	if err != nil {
+		return nil, err
+	}
+	params, err := call.Response().Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(params) != 1 {
+		return nil, status.Error(status.Unknown, "malformed response")
+	}
+	return proto.Convert[[]Connection](params[0])
+}
+
+// Ping calls the Ping method of the channelz service through c, pinging the
+// connection identified by id.
+func Ping(ctx context.Context, c arf.Client, id uint64) (time.Duration, error) {
+	call, err := c.Call(ctx, ServiceID, "Ping", arf.WithParams(id))
+	if err != nil {
+		return 0, err
+	}
+	params, err := call.Response().Result()
+	if err != nil {
+		return 0, err
+	}
+	if len(params) != 1 {
+		return 0, status.Error(status.Unknown, "malformed response")
+	}
+	rtt, err := proto.Convert[uint64](params[0])
+	return time.Duration(rtt), err
+}
@@ -0,0 +1,107 @@
+package channelz
+
+import (
+	"context"
+	"fmt"
+	"github.com/arf-rpc/arf-go"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/arf-rpc/arf-go/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChannelz(t *testing.T) {
+	var ids int
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server, err := arf.NewServer(l, arf.ServerOptions{
+		IDGenerator: func() (string, error) {
+			ids++
+			return fmt.Sprintf("req-%d", ids), nil
+		},
+	})
+	require.NoError(t, err)
+	go func() { _ = server.Serve() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	require.NoError(t, Register(server))
+	release := make(chan struct{})
+	server.MustRegisterService(arf.ServiceAdapter{
+		ServiceID: "org.example.test/Channelz",
+		Methods: map[string]arf.ServiceExecutor{
+			"Wait": func(ctx context.Context, c arf.Context) error {
+				<-release
+				return nil
+			},
+		},
+	})
+
+	c, err := arf.Dial(l.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+
+	go func() { _, _ = c.Call(context.Background(), "org.example.test/Channelz", "Wait") }()
+	require.Eventually(t, func() bool {
+		conns := Connections(server)
+		return len(conns) == 1 && len(conns[0].Streams) == 1 && conns[0].Streams[0].Method == "Wait"
+	}, time.Second, 5*time.Millisecond)
+
+	t.Run("ListConnections", func(t *testing.T) {
+		conns, err := ListConnections(context.Background(), c)
+		require.NoError(t, err)
+		require.Len(t, conns, 1)
+		conn := conns[0]
+		assert.Equal(t, uint64(0), conn.ID)
+		assert.Equal(t, l.Addr().String(), conn.LocalAddr)
+		assert.NotEmpty(t, conn.RemoteAddr)
+		assert.Equal(t, wire.CompressionMethodNone.String(), conn.Compression)
+		assert.False(t, conn.TLS)
+		assert.Less(t, conn.Age(), time.Second)
+		assert.Equal(t, uint64(2), conn.StreamsStarted)
+		assert.Equal(t, uint64(2), conn.StreamsActive)
+		require.Len(t, conn.Streams, 2)
+		assert.Equal(t, Stream{ID: conn.Streams[0].ID, State: "half-closed (remote)", RequestID: "req-1", Service: "org.example.test/Channelz", Method: "Wait"}, conn.Streams[0])
+		assert.Equal(t, ServiceID, conn.Streams[1].Service)
+		assert.Equal(t, "ListConnections", conn.Streams[1].Method)
+	})
+
+	t.Run("Ping", func(t *testing.T) {
+		rtt, err := Ping(context.Background(), c, 0)
+		require.NoError(t, err)
+		assert.Positive(t, rtt)
+		assert.Equal(t, int64(rtt), Connections(server)[0].LastPingRTT)
+
+		_, err = Ping(context.Background(), c, 42)
+		bad, ok := status.FromError(err)
+		require.True(t, ok)
+		assert.Equal(t, status.NotFound, bad.Code)
+	})
+
+	t.Run("Handler", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		Handler(server).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/channelz", nil))
+		assert.Equal(t, http.StatusSeeOther, rec.Code)
+		assert.Equal(t, "/channelz", rec.Header().Get("Location"))
+		assert.NotZero(t, server.Connections()[0].LastPingRTT)
+
+		rec = httptest.NewRecorder()
+		Handler(server).ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/channelz", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+		rec = httptest.NewRecorder()
+		Handler(server).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/channelz", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		body, err := io.ReadAll(rec.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "<h2>Connection 0</h2>")
+		assert.Contains(t, string(body), "<td>org.example.test/Channelz</td><td>Wait</td>")
+	})
+
+	close(release)
+}
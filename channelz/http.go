@@ -0,0 +1,112 @@
+package channelz
+
+import (
+	"bytes"
+	"context"
+	"github.com/arf-rpc/arf-go"
+	"html/template"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// PingTimeout bounds the ping of each connection performed by the page
+// served by Handler.
+const PingTimeout = time.Second
+
+var page = template.Must(template.New("channelz").Funcs(template.FuncMap{
+	"duration": func(ns int64) string {
+		if ns == 0 {
+			return "-"
+		}
+		return time.Duration(ns).String()
+	},
+	"age": func(c Connection) string {
+		return c.Age().Round(time.Second).String()
+	},
+	"or": func(s, fallback string) string {
+		if s == "" {
+			return fallback
+		}
+		return s
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>channelz</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; text-align: left; }
+</style>
+</head>
+<body>
+<h1>Connections ({{len .}})</h1>
+<form method="post"><button type="submit">Ping every connection</button></form>
+{{range .}}
+<h2>Connection {{.ID}}</h2>
+<table>
+<tr><th>Remote address</th><td>{{or .RemoteAddr "-"}}</td></tr>
+<tr><th>Local address</th><td>{{or .LocalAddr "-"}}</td></tr>
+<tr><th>Compression</th><td>{{.Compression}}</td></tr>
+<tr><th>TLS</th><td>{{.TLS}}</td></tr>
+<tr><th>Age</th><td>{{age .}}</td></tr>
+<tr><th>Last ping RTT</th><td>{{duration .LastPingRTT}}</td></tr>
+<tr><th>Streams started</th><td>{{.StreamsStarted}}</td></tr>
+<tr><th>Streams active</th><td>{{.StreamsActive}}</td></tr>
+</table>
+{{if .Streams}}
+<table>
+<tr><th>Stream</th><th>State</th><th>Buffered bytes</th><th>Request ID</th><th>Service</th><th>Method</th></tr>
+{{range .Streams}}<tr><td>{{.ID}}</td><td>{{.State}}</td><td>{{.Buffered}}</td><td>{{or .RequestID "-"}}</td><td>{{or .Service "-"}}</td><td>{{or .Method "-"}}</td></tr>
+{{end}}</table>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// Handler returns a handler serving a page describing the connections
+// established with s. POST requests ping every connection concurrently,
+// updating their round-trip times, and redirect back to the page. As the
+// service installed by Register, the page should not be publicly reachable.
+func Handler(s arf.Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPost:
+			pingAll(r.Context(), s)
+			http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+			return
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := page.Execute(&buf, Connections(s)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(buf.Bytes())
+	})
+}
+
+// pingAll pings every connection established with s concurrently, each
+// bound by PingTimeout.
+func pingAll(ctx context.Context, s arf.Server) {
+	var wg sync.WaitGroup
+	for _, c := range s.Connections() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, PingTimeout)
+			defer cancel()
+			_, _ = s.PingConnection(ctx, c.ID)
+		}()
+	}
+	wg.Wait()
+}
@@ -3,9 +3,17 @@ package arf
 import (
 	"context"
 	"crypto/tls"
+	"errors"
+	"github.com/arf-rpc/arf-go/proto"
 	"github.com/arf-rpc/arf-go/rpc"
+	"github.com/arf-rpc/arf-go/status"
 	"github.com/arf-rpc/arf-go/wire"
+	"github.com/go-stdlog/stdlog"
 	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type ClientOption func(*client)
@@ -16,49 +24,370 @@ func WithTLSConfig(cfg *tls.Config) ClientOption {
 	}
 }
 
-func Dial(addr string, opts ...ClientOption) (Client, error) {
-	c := &client{}
+// WithDecodeOptions limits the resources used when decoding responses and
+// stream items received from the server.
+func WithDecodeOptions(opts proto.DecodeOptions) ClientOption {
+	return func(c *client) {
+		c.decodeOptions = opts
+	}
+}
+
+// WithMaxRecvMessageSize sets the maximum size, in bytes, of a single
+// response or stream item received from the server.
+func WithMaxRecvMessageSize(n int) ClientOption {
+	return func(c *client) {
+		c.wireOptions = append(c.wireOptions, wire.WithMaxRecvMessageSize(n))
+	}
+}
+
+// WithMaxSendMessageSize sets the maximum size, in bytes, of a single request
+// or stream item sent to the server.
+func WithMaxSendMessageSize(n int) ClientOption {
+	return func(c *client) {
+		c.wireOptions = append(c.wireOptions, wire.WithMaxSendMessageSize(n))
+	}
+}
+
+// WithLogger routes diagnostics about the connections of the client, such
+// as protocol violations committed by the server, to l.
+func WithLogger(l stdlog.Logger) ClientOption {
+	return func(c *client) {
+		c.wireOptions = append(c.wireOptions, wire.WithLogger(l))
+	}
+}
+
+// WithFrameTap makes t observe every frame exchanged through the connections
+// of the client. Use a wire.CaptureWriter to record them to a capture file.
+func WithFrameTap(t wire.FrameTap) ClientOption {
+	return func(c *client) {
+		c.wireOptions = append(c.wireOptions, wire.WithFrameTap(t))
+	}
+}
+
+// Dialer establishes the connection to addr, the target given to Dial or
+// DialContext, or an address provided by a pool's Resolver.
+type Dialer func(ctx context.Context, addr string) (net.Conn, error)
+
+// WithDialer replaces the default dialer, which connects through TCP or,
+// for targets in the form unix:///path/to/socket, through a Unix socket.
+// When a TLS configuration is set, the handshake is performed over the
+// connection returned by d.
+func WithDialer(d Dialer) ClientOption {
+	return func(c *client) {
+		c.dialer = d
+	}
+}
+
+// WithDialTimeout bounds every connection attempt, including the HELLO
+// exchange and reconnections.
+func WithDialTimeout(d time.Duration) ClientOption {
+	return func(c *client) {
+		c.dialTimeout = d
+	}
+}
+
+// WithCompression sets the compression method negotiated with the server
+// during the handshake, including after reconnections.
+func WithCompression(method wire.CompressionMethod) ClientOption {
+	return func(c *client) {
+		c.compression = method
+	}
+}
+
+// WithReconnectBackoff sets delays between reconnection attempts. Defaults
+// to DefaultBackoff.
+func WithReconnectBackoff(b Backoff) ClientOption {
+	return func(c *client) {
+		c.backoff = b
+	}
+}
+
+// WithMaxReconnectAttempts limits the amount of consecutive reconnection
+// attempts made after the connection is lost. Once exhausted, the client
+// becomes Idle, and a new round of attempts is started by the next call.
+// Zero, the default, retries indefinitely.
+func WithMaxReconnectAttempts(n int) ClientOption {
+	return func(c *client) {
+		c.maxAttempts = n
+	}
+}
+
+// WithoutReconnect disables automatic reconnection. Once the connection is
+// lost, the client remains in TransientFailure and calls fail.
+func WithoutReconnect() ClientOption {
+	return func(c *client) {
+		c.noReconnect = true
+	}
+}
+
+// Dial connects to target. In case the connection is later lost, the client
+// transparently redials it with backoff; calls made meanwhile wait for the
+// connection to be reestablished, bound by their contexts.
+func Dial(target string, opts ...ClientOption) (Client, error) {
+	return DialContext(context.Background(), target, opts...)
+}
+
+// DialContext connects to target like Dial, failing in case ctx is done
+// before the connection is established and the HELLO exchange completes.
+// target is either a TCP address, or a Unix socket in the form
+// unix:///path/to/socket.
+func DialContext(ctx context.Context, target string, opts ...ClientOption) (Client, error) {
+	c := newClient(opts)
+	c.addr = target
+	if err := c.dial(ctx); err != nil {
+		c.state.set(Shutdown)
+		return nil, err
+	}
+	return c, nil
+}
+
+func newClient(opts []ClientOption) *client {
+	c := &client{
+		state: newConnectivity(Connecting),
+	}
+	c.closed, c.markClosed = context.WithCancel(context.Background())
 	for _, fn := range opts {
 		fn(c)
 	}
+	return c
+}
 
-	var conn net.Conn
-	var err error
-	if c.tlsConfig != nil {
-		conn, err = tls.Dial("tcp", addr, c.tlsConfig)
-	} else {
-		conn, err = net.Dial("tcp", addr)
+// splitTarget returns the network and address to dial for target.
+func splitTarget(target string) (network, addr string) {
+	switch {
+	case strings.HasPrefix(target, "unix://"):
+		return "unix", strings.TrimPrefix(target, "unix://")
+	case strings.HasPrefix(target, "unix:"):
+		return "unix", strings.TrimPrefix(target, "unix:")
+	case strings.HasPrefix(target, "tcp://"):
+		return "tcp", strings.TrimPrefix(target, "tcp://")
+	}
+	return "tcp", target
+}
+
+func defaultDialer(ctx context.Context, target string) (net.Conn, error) {
+	network, addr := splitTarget(target)
+	var d net.Dialer
+	return d.DialContext(ctx, network, addr)
+}
+
+func (c *client) dial(ctx context.Context) error {
+	if c.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.dialTimeout)
+		defer cancel()
 	}
 
+	dialer := c.dialer
+	if dialer == nil {
+		dialer = defaultDialer
+	}
+	conn, err := dialer(ctx, c.addr)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	c.c = wire.NewClient(conn)
+	if c.tlsConfig != nil {
+		cfg := c.tlsConfig
+		if cfg.ServerName == "" {
+			if network, addr := splitTarget(c.addr); network == "tcp" {
+				if host, _, err := net.SplitHostPort(addr); err == nil {
+					cfg = cfg.Clone()
+					cfg.ServerName = host
+				}
+			}
+		}
+		tlsConn := tls.Client(conn, cfg)
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return err
+		}
+		conn = tlsConn
+	}
 
-	if err = c.c.Configure(wire.CompressionMethodNone); err != nil {
-		return nil, err
+	return c.start(ctx, conn)
+}
+
+// start performs the handshake with the server over conn, and makes the
+// resulting connection the one used by calls.
+func (c *client) start(ctx context.Context, conn net.Conn) error {
+	w := wire.NewClient(conn, c.wireOptions...)
+	if err := w.ConfigureContext(ctx, c.compression); err != nil {
+		_ = w.Close()
+		return err
 	}
 
-	return c, nil
+	c.mu.Lock()
+	select {
+	case <-c.closed.Done():
+		c.mu.Unlock()
+		_ = w.Close()
+		return ClientClosedErr
+	default:
+	}
+	c.c = w
+	c.mu.Unlock()
+
+	c.state.set(Ready)
+	go c.monitor(w)
+	return nil
 }
 
 type Client interface {
 	Close() error
 	Call(ctx context.Context, serviceIdentifier, serviceMethod string, opts ...CallOption) (Context, error)
+	// State returns the current connectivity state, along with a channel
+	// closed once the client transitions out of it.
+	State() (ConnectivityState, <-chan struct{})
 }
 
+// ClientClosedErr is returned by calls made on a closed Client.
+var ClientClosedErr = errors.New("arf: client is closed")
+
 type client struct {
-	c         wire.Client
-	tlsConfig *tls.Config
+	mu            sync.Mutex
+	c             wire.Client
+	addr          string
+	tlsConfig     *tls.Config
+	dialer        Dialer
+	dialTimeout   time.Duration
+	decodeOptions proto.DecodeOptions
+	wireOptions   []wire.Option
+	compression   wire.CompressionMethod
+
+	state        *connectivity
+	lastErr      error
+	backoff      Backoff
+	maxAttempts  int
+	noReconnect  bool
+	reconnecting atomic.Bool
+	closeOnce    sync.Once
+	// closed is canceled once the client is closed, aborting reconnection
+	// attempts in flight.
+	closed     context.Context
+	markClosed context.CancelFunc
+
+	retryPolicies   map[retryKey]RetryPolicy
+	hedgingPolicies map[retryKey]HedgingPolicy
+	credentials     PerCallCredentials
+	stats           StatsHandler
+	interceptors    []ClientInterceptor
 }
 
 type callOptions struct {
 	outputMetadataTarget *rpc.Metadata
+	credentials          PerCallCredentials
 }
 
 func (c *client) Close() error {
-	return c.c.Close()
+	var err error
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.markClosed()
+		w := c.c
+		c.mu.Unlock()
+
+		c.state.set(Shutdown)
+		if w != nil {
+			err = w.Close()
+		}
+	})
+	return err
+}
+
+func (c *client) State() (ConnectivityState, <-chan struct{}) {
+	return c.state.get()
+}
+
+// monitor waits for w to be terminated, and starts reconnecting unless the
+// client was closed.
+func (c *client) monitor(w wire.Client) {
+	select {
+	case <-c.closed.Done():
+		return
+	case <-w.Done():
+	}
+
+	c.mu.Lock()
+	c.lastErr = w.Err()
+	c.mu.Unlock()
+
+	if c.noReconnect || c.addr == "" {
+		c.state.set(TransientFailure)
+		return
+	}
+	c.reconnect()
+}
+
+// reconnect redials the server until it succeeds, the client is closed, or
+// the maximum amount of attempts is reached, in which case the client
+// becomes Idle. Only one reconnection loop runs at a time.
+func (c *client) reconnect() {
+	if !c.reconnecting.CompareAndSwap(false, true) {
+		return
+	}
+	defer c.reconnecting.Store(false)
+
+	for attempt := 0; c.maxAttempts == 0 || attempt < c.maxAttempts; attempt++ {
+		c.state.set(Connecting)
+		err := c.dial(c.closed)
+		if err == nil || c.closed.Err() != nil {
+			return
+		}
+
+		c.mu.Lock()
+		c.lastErr = err
+		c.mu.Unlock()
+		c.state.set(TransientFailure)
+
+		t := time.NewTimer(c.backoff.Delay(attempt))
+		select {
+		case <-c.closed.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+	c.state.set(Idle)
+}
+
+// transport returns the connection to be used by a call, waiting for it to
+// become Ready in case the client is connecting, or about to reconnect.
+func (c *client) transport(ctx context.Context) (wire.Client, error) {
+	for {
+		state, changed := c.state.get()
+		switch state {
+		case Ready:
+			c.mu.Lock()
+			w := c.c
+			c.mu.Unlock()
+			return w, nil
+		case Shutdown:
+			return nil, ClientClosedErr
+		case TransientFailure:
+			if !c.noReconnect && c.addr != "" {
+				// The reconnection loop retries once its backoff
+				// elapses.
+				break
+			}
+			c.mu.Lock()
+			err := c.lastErr
+			c.mu.Unlock()
+			msg := "connection unavailable"
+			if err != nil {
+				msg += ": " + err.Error()
+			}
+			return nil, &status.BadStatus{Code: status.Unavailable, Message: msg}
+		case Idle:
+			go c.reconnect()
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
 }
 
 type CallOption func(*rpc.Request, *callOptions)
@@ -75,6 +404,15 @@ func WithMetadata(m rpc.Metadata) CallOption {
 	}
 }
 
+// WithAdditionalMetadata sets the keys of m on the request metadata,
+// replacing values set for them by previous options while keeping other
+// keys.
+func WithAdditionalMetadata(m rpc.Metadata) CallOption {
+	return func(r *rpc.Request, o *callOptions) {
+		r.Metadata = mergeMetadata(r.Metadata, m)
+	}
+}
+
 func WithParams(params ...any) CallOption {
 	return func(r *rpc.Request, o *callOptions) {
 		r.Params = params
@@ -95,19 +433,67 @@ func (c *client) cancelErr(str wire.Stream, err error) error {
 	return err
 }
 
-func (c *client) Call(cctx context.Context, serviceIdentifier, serviceMethod string, opts ...CallOption) (Context, error) {
-	str, err := c.c.NewStream()
+func (c *client) Call(ctx context.Context, serviceIdentifier, serviceMethod string, opts ...CallOption) (Context, error) {
+	return c.intercept(ctx, serviceIdentifier, serviceMethod, opts, func(ctx context.Context, serviceIdentifier, serviceMethod string, opts ...CallOption) (Context, error) {
+		return c.invoke(ctx, serviceIdentifier, serviceMethod, opts, func(ctx context.Context, opts []CallOption) (Context, error) {
+			return c.call(ctx, serviceIdentifier, serviceMethod, opts...)
+		})
+	})
+}
+
+// invoke performs a call through attempt, hedging or retrying it according
+// to the policies configured for the method. Errors are converted into a
+// *status.BadStatus.
+func (c *client) invoke(ctx context.Context, serviceIdentifier, serviceMethod string, opts []CallOption, attempt attemptFunc) (Context, error) {
+	call, err := c.invokePolicy(ctx, serviceIdentifier, serviceMethod, opts, attempt)
 	if err != nil {
-		return nil, err
+		return nil, status.Convert(err)
 	}
+	return call, nil
+}
+
+func (c *client) invokePolicy(ctx context.Context, serviceIdentifier, serviceMethod string, opts []CallOption, attempt attemptFunc) (Context, error) {
+	if policy, ok := c.hedgingPolicy(serviceIdentifier, serviceMethod, opts); ok {
+		return callHedged(ctx, policy, opts, attempt)
+	}
+	if policy, ok := c.retryPolicy(serviceIdentifier, serviceMethod); ok {
+		return callWithRetries(ctx, policy, opts, attempt)
+	}
+	return attempt(ctx, opts)
+}
+
+// call performs a single attempt of a call.
+func (c *client) call(cctx context.Context, serviceIdentifier, serviceMethod string, opts ...CallOption) (_ Context, err error) {
+	tracker := newRPCTracker(c.stats, serviceIdentifier, serviceMethod, true)
+	defer func() {
+		if err != nil {
+			tracker.end(status.Convert(err).Code)
+		}
+	}()
+
 	req := &rpc.Request{
 		Service: serviceIdentifier,
 		Method:  serviceMethod,
 	}
-	extraOpts := callOptions{}
+	extraOpts := callOptions{credentials: c.credentials}
 	for _, v := range opts {
 		v(req, &extraOpts)
 	}
+	if extraOpts.credentials != nil {
+		if err := applyCredentials(cctx, extraOpts.credentials, req); err != nil {
+			return nil, err
+		}
+	}
+
+	w, err := c.transport(cctx)
+	if err != nil {
+		return nil, err
+	}
+	str, err := w.NewStream()
+	if err != nil {
+		return nil, err
+	}
+	str = newCountingStream(str)
 
 	encoded, err := req.Wrap()
 	if err != nil {
@@ -115,12 +501,15 @@ func (c *client) Call(cctx context.Context, serviceIdentifier, serviceMethod str
 	}
 
 	if err = str.Write(encoded, !req.Streaming); err != nil {
+		if st, ok := messageSizeStatus(err); ok {
+			return nil, c.cancelErr(str, st)
+		}
 		_ = str.CloseLocal()
 		return nil, err
 	}
 
 	if cctx.Err() != nil {
-		return nil, c.cancelErr(str, err)
+		return nil, c.cancelErr(str, status.Convert(cctx.Err()))
 	}
 
 	if req.Streaming {
@@ -133,26 +522,57 @@ func (c *client) Call(cctx context.Context, serviceIdentifier, serviceMethod str
 		if err != nil {
 			return nil, c.cancelErr(str, err)
 		}
+
+		// The server may only respond once it consumed the request stream,
+		// so its response is read once needed.
+		return &ctx{
+			context:           cctx,
+			str:               str,
+			hasSendStream:     true,
+			sendStreamStarted: true,
+			req:               req,
+			pendingResponse:   true,
+			outputMetadata:    extraOpts.outputMetadataTarget,
+			decodeOptions:     c.decodeOptions,
+			rpc:               tracker,
+			ended:             make(chan struct{}),
+		}, nil
 	}
 
-	resp, err := rpc.MessageTFromReader[*rpc.Response](str)
+	// Reset the stream in case cctx is done while waiting for the response,
+	// so the server stops processing the request.
+	stop := context.AfterFunc(cctx, func() { _ = c.cancelErr(str, nil) })
+	resp, err := rpc.MessageTFromReader[*rpc.Response](proto.NewDecoder(str, c.decodeOptions))
+	if !stop() && cctx.Err() != nil {
+		return nil, cctx.Err()
+	}
 	if err != nil {
+		if st, ok := messageSizeStatus(err); ok {
+			_ = str.Reset(wire.ErrorCodeEnhanceYourCalm)
+			return nil, st
+		}
 		return nil, c.cancelErr(str, err)
 	}
 
 	if extraOpts.outputMetadataTarget != nil {
-		*extraOpts.outputMetadataTarget = resp.Metadata
+		*extraOpts.outputMetadataTarget = resp.Header()
 	}
-
-	return &ctx{
+	call := &ctx{
 		context:           cctx,
 		str:               str,
 		hasRecvStream:     resp.Streaming,
 		recvStreamError:   nil,
 		recvStreamStarted: false,
-		hasSendStream:     req.Streaming,
+		hasSendStream:     false,
 		sendStreamError:   nil,
 		resp:              resp,
 		req:               req,
-	}, nil
+		decodeOptions:     c.decodeOptions,
+		rpc:               tracker,
+		ended:             make(chan struct{}),
+	}
+	if !resp.Streaming {
+		call.end(status.Status(resp.Status))
+	}
+	return call, nil
 }
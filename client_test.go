@@ -0,0 +1,238 @@
+package arf
+
+import (
+	"context"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// watchState reports every state c transitions to, until Shutdown.
+func watchState(c Client) <-chan ConnectivityState {
+	states := make(chan ConnectivityState, 64)
+	go func() {
+		defer close(states)
+		state, changed := c.State()
+		for state != Shutdown {
+			<-changed
+			state, changed = c.State()
+			states <- state
+		}
+	}()
+	return states
+}
+
+// waitState consumes states until want is reported.
+func waitState(t *testing.T, states <-chan ConnectivityState, want ConnectivityState) {
+	t.Helper()
+
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case s, ok := <-states:
+			require.True(t, ok, "expected %s before Shutdown", want)
+			if s == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s", want)
+		}
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	t.Run("lost connections are transparently redialed", func(t *testing.T) {
+		l := listen(t)
+		server := serveNamed(t, l, "a")
+		c, err := Dial(l.Addr().String(), WithReconnectBackoff(testBackoff))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = c.Close() })
+		assert.Equal(t, "a", mustCallName(t, c))
+
+		states := watchState(c)
+		require.NoError(t, server.Shutdown())
+		waitState(t, states, TransientFailure)
+
+		serveNamed(t, relisten(t, l.Addr().String()), "a2")
+		waitState(t, states, Ready)
+		assert.Equal(t, "a2", mustCallName(t, c))
+
+		require.NoError(t, c.Close())
+		waitState(t, states, Shutdown)
+		_, err = callName(context.Background(), c, "Name")
+		assert.ErrorContains(t, err, ClientClosedErr.Error())
+	})
+
+	t.Run("calls wait for the connection while connecting", func(t *testing.T) {
+		l := listen(t)
+		server := serveNamed(t, l, "a")
+		c, err := Dial(l.Addr().String(), WithReconnectBackoff(testBackoff), WithMaxReconnectAttempts(2))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = c.Close() })
+
+		states := watchState(c)
+		require.NoError(t, server.Shutdown())
+		waitState(t, states, Idle)
+
+		// Idle clients only reconnect once a call is made.
+		serveNamed(t, relisten(t, l.Addr().String()), "a2")
+		time.Sleep(50 * time.Millisecond)
+		state, _ := c.State()
+		assert.Equal(t, Idle, state)
+
+		assert.Equal(t, "a2", mustCallName(t, c))
+		state, _ = c.State()
+		assert.Equal(t, Ready, state)
+	})
+
+	t.Run("calls wait for the connection while backing off", func(t *testing.T) {
+		l := listen(t)
+		server := serveNamed(t, l, "a")
+		backoff := Backoff{BaseDelay: 200 * time.Millisecond, MaxDelay: 200 * time.Millisecond, Jitter: -1}
+		c, err := Dial(l.Addr().String(), WithReconnectBackoff(backoff))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = c.Close() })
+
+		states := watchState(c)
+		require.NoError(t, server.Shutdown())
+		waitState(t, states, TransientFailure)
+
+		name := make(chan string, 1)
+		go func() {
+			v, _ := callName(context.Background(), c, "Name")
+			name <- v
+		}()
+		serveNamed(t, relisten(t, l.Addr().String()), "a2")
+		assert.Equal(t, "a2", waitSignal(t, name))
+	})
+
+	t.Run("Close aborts reconnection attempts in flight", func(t *testing.T) {
+		l := listen(t)
+		server := serveNamed(t, l, "a")
+		aborted := make(chan error, 1)
+		dials := 0
+		c, err := Dial(l.Addr().String(), WithDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			dials++
+			if dials == 1 {
+				return defaultDialer(ctx, addr)
+			}
+			<-ctx.Done()
+			aborted <- ctx.Err()
+			return nil, ctx.Err()
+		}))
+		require.NoError(t, err)
+
+		states := watchState(c)
+		require.NoError(t, server.Shutdown())
+		waitState(t, states, Connecting)
+		require.NoError(t, c.Close())
+		assert.ErrorIs(t, waitSignal(t, aborted), context.Canceled)
+	})
+
+	t.Run("WithMaxReconnectAttempts bounds consecutive attempts", func(t *testing.T) {
+		l := listen(t)
+		server := serveNamed(t, l, "a")
+		attempts := 0
+		c, err := Dial(l.Addr().String(),
+			WithReconnectBackoff(testBackoff),
+			WithMaxReconnectAttempts(3),
+			WithDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+				attempts++
+				return defaultDialer(ctx, addr)
+			}))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = c.Close() })
+
+		states := watchState(c)
+		require.NoError(t, server.Shutdown())
+		waitState(t, states, Idle)
+		assert.Equal(t, 1+3, attempts)
+	})
+
+	t.Run("WithoutReconnect leaves the client failed", func(t *testing.T) {
+		l := listen(t)
+		server := serveNamed(t, l, "a")
+		c, err := Dial(l.Addr().String(), WithoutReconnect())
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = c.Close() })
+
+		states := watchState(c)
+		require.NoError(t, server.Shutdown())
+		waitState(t, states, TransientFailure)
+
+		serveNamed(t, relisten(t, l.Addr().String()), "a2")
+		_, err = callName(context.Background(), c, "Name")
+		assert.Equal(t, status.Unavailable, status.Convert(err).Code)
+		time.Sleep(50 * time.Millisecond)
+		state, _ := c.State()
+		assert.Equal(t, TransientFailure, state)
+	})
+}
+
+func TestDial(t *testing.T) {
+	t.Run("targets select the network", func(t *testing.T) {
+		sock := filepath.Join(t.TempDir(), "arf.sock")
+		lu, err := net.Listen("unix", sock)
+		require.NoError(t, err)
+		serveNamed(t, lu, "unix")
+		lt := listen(t)
+		serveNamed(t, lt, "tcp")
+
+		for target, name := range map[string]string{
+			"unix://" + sock:              "unix",
+			"unix:" + sock:                "unix",
+			"tcp://" + lt.Addr().String(): "tcp",
+			lt.Addr().String():            "tcp",
+		} {
+			c, err := Dial(target)
+			require.NoError(t, err, target)
+			assert.Equal(t, name, mustCallName(t, c), target)
+			_ = c.Close()
+		}
+	})
+
+	t.Run("WithDialer replaces the default dialer", func(t *testing.T) {
+		l := listen(t)
+		serveNamed(t, l, "a")
+
+		var dialed []string
+		c, err := Dial("custom-target", WithDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			dialed = append(dialed, addr)
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", l.Addr().String())
+		}))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = c.Close() })
+		assert.Equal(t, "a", mustCallName(t, c))
+		assert.Equal(t, []string{"custom-target"}, dialed)
+	})
+
+	t.Run("WithDialTimeout bounds the HELLO exchange", func(t *testing.T) {
+		// The listener accepts connections, but nothing answers them.
+		l := listen(t)
+		t.Cleanup(func() { _ = l.Close() })
+
+		start := time.Now()
+		_, err := Dial(l.Addr().String(), WithDialTimeout(50*time.Millisecond))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("DialContext gives up on peers not reading the HELLO frame", func(t *testing.T) {
+		local, remote := net.Pipe()
+		t.Cleanup(func() { _ = remote.Close() })
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := DialContext(ctx, "pipe", WithDialer(func(context.Context, string) (net.Conn, error) {
+			return local, nil
+		}))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"github.com/arf-rpc/arf-go/proto"
+	"github.com/arf-rpc/arf-go/rpc"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/arf-rpc/arf-go/wire"
+	"io"
+	"os"
+	"strings"
+)
+
+const captureTimeLayout = "15:04:05.000000"
+
+// messageKey identifies the message being reassembled from DATA frames
+// exchanged through a stream in a given direction.
+type messageKey struct {
+	conn   int
+	dir    wire.Direction
+	stream uint32
+}
+
+func runCapture(args []string) error {
+	fs := flag.NewFlagSet("capture", flag.ContinueOnError)
+	raw := fs.Bool("raw", false, "do not decode DATA payloads as rpc messages")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	in, err := openInput(fs.Args())
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	r, err := wire.NewCaptureReader(bufio.NewReader(in))
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	pending := make(map[messageKey][]byte)
+	for {
+		c, err := r.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		fr := c.Frame
+		fmt.Fprintf(out, "%s conn=%d %-3s %s stream=%d flags=%#02x length=%d\n",
+			c.Time.UTC().Format(captureTimeLayout), c.ConnID, c.Direction,
+			fr.FrameKind, fr.StreamID, fr.Flags, len(fr.Payload))
+
+		if fr.FrameKind != wire.FrameKindData {
+			describeControlFrame(out, fr)
+			continue
+		}
+		data := &wire.DataFrame{}
+		if err = data.FromFrame(fr); err != nil {
+			fmt.Fprintf(out, "\tinvalid frame: %s\n", err)
+			continue
+		}
+		if *raw {
+			continue
+		}
+
+		key := messageKey{conn: c.ConnID, dir: c.Direction, stream: fr.StreamID}
+		pending[key] = append(pending[key], data.Payload...)
+		if !data.EndData {
+			continue
+		}
+		msg := pending[key]
+		delete(pending, key)
+		describeMessage(out, msg)
+	}
+}
+
+func describeControlFrame(w io.Writer, fr *wire.Frame) {
+	var err error
+	switch fr.FrameKind {
+	case wire.FrameKindHello:
+		hello := &wire.HelloFrame{}
+		if err = hello.FromFrame(fr); err == nil {
+			fmt.Fprintf(w, "\tack=%t gzip=%t max_concurrent_streams=%d\n",
+				hello.Ack, hello.CompressionGZip, hello.MaxConcurrentStreams)
+		}
+	case wire.FrameKindPing:
+		ping := &wire.PingFrame{}
+		if err = ping.FromFrame(fr); err == nil {
+			fmt.Fprintf(w, "\tack=%t payload=%x\n", ping.Ack, ping.Payload)
+		}
+	case wire.FrameKindGoAway:
+		goAway := &wire.GoAwayFrame{}
+		if err = goAway.FromFrame(fr); err == nil {
+			fmt.Fprintf(w, "\tlast_stream=%d error=%s data=%q\n",
+				goAway.LastStreamID, goAway.ErrorCode, goAway.AdditionalData)
+		}
+	case wire.FrameKindResetStream:
+		reset := &wire.ResetStreamFrame{}
+		if err = reset.FromFrame(fr); err == nil {
+			fmt.Fprintf(w, "\terror=%s\n", reset.ErrorCode)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(w, "\tinvalid frame: %s\n", err)
+	}
+}
+
+// describeMessage prints the rpc message reassembled from DATA frames.
+// Values are printed as annotated trees, as they may contain structs whose
+// types are not registered.
+func describeMessage(w io.Writer, msg []byte) {
+	r := bytes.NewReader(msg)
+	kind, err := rpc.MessageKindFromReader(r)
+	if err != nil {
+		fmt.Fprintf(w, "\tinvalid message: %s\n", err)
+		return
+	}
+	fmt.Fprintf(w, "\t%s\n", kind)
+	if err = describeMessageBody(w, kind, r); err != nil {
+		fmt.Fprintf(w, "\t\tinvalid %s: %s\n", kind, err)
+	}
+}
+
+func describeMessageBody(w io.Writer, kind rpc.MessageKind, r *bytes.Reader) error {
+	switch kind {
+	case rpc.MessageKindRequest:
+		service, err := proto.DecodeString(r)
+		if err != nil {
+			return err
+		}
+		method, err := proto.DecodeString(r)
+		if err != nil {
+			return err
+		}
+		flags, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "\t\tservice=%s method=%s streaming=%t\n", service, method, flags&0x01 != 0)
+		return describeParams(w, r)
+
+	case rpc.MessageKindResponse:
+		code, err := readUint16(r)
+		if err != nil {
+			return err
+		}
+		flags, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "\t\tstatus=%q streaming=%t\n", status.Status(code).Error(), flags&0x01 != 0)
+		return describeParams(w, r)
+
+	case rpc.MessageKindStreamItem:
+		return describeValues(w, r)
+	}
+
+	msg, err := rpc.InitializeMessageKind(kind)
+	if err != nil {
+		return err
+	}
+	if err = msg.FromReader(r); err != nil {
+		return err
+	}
+	switch m := msg.(type) {
+	case *rpc.StreamMetadata:
+		describeMetadata(w, m.Metadata)
+	case *rpc.EndStream:
+		describeMetadata(w, m.Metadata)
+	case *rpc.StreamError:
+		fmt.Fprintf(w, "\t\tstatus=%q\n", status.Status(m.Status).Error())
+		describeMetadata(w, m.Metadata)
+	}
+	return nil
+}
+
+// describeParams prints the metadata and parameters closing a Request or
+// Response.
+func describeParams(w io.Writer, r *bytes.Reader) error {
+	meta, err := rpc.MetadataFromReader(r)
+	if err != nil {
+		return err
+	}
+	describeMetadata(w, meta)
+	count, err := readUint16(r)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\t\tparams=%d\n", count)
+	return describeValues(w, r)
+}
+
+func describeMetadata(w io.Writer, meta rpc.Metadata) {
+	for _, p := range meta {
+		fmt.Fprintf(w, "\t\tmetadata %s=%q\n", p.Key, p.Value)
+	}
+}
+
+func describeValues(w io.Writer, r io.Reader) error {
+	dump, err := proto.Dump(r)
+	for _, line := range strings.SplitAfter(dump, "\n") {
+		if line != "" {
+			fmt.Fprint(w, "\t\t"+line)
+		}
+	}
+	return err
+}
+
+func readUint16(r io.Reader) (uint16, error) {
+	var b [2]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b[:]), nil
+}
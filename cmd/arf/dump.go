@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"github.com/arf-rpc/arf-go/proto"
+	"io"
+	"os"
+	"strings"
+)
+
+func openInput(args []string) (io.ReadCloser, error) {
+	if len(args) == 0 || args[0] == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(args[0])
+}
+
+func runDump(args []string) error {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	isHex := fs.Bool("hex", false, "input is hex-encoded; whitespace is ignored")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	in, err := openInput(fs.Args())
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	if *isHex {
+		data, err = hex.DecodeString(strings.Join(strings.Fields(string(data)), ""))
+		if err != nil {
+			return err
+		}
+	}
+
+	out, err := proto.Dump(bytes.NewReader(data))
+	fmt.Print(out)
+	return err
+}
@@ -0,0 +1,47 @@
+// Command arf provides debugging utilities for the arf protocol.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"dump", "dump [-hex] [file]\tprint an annotated tree of arf-encoded values", runDump},
+	{"capture", "capture [-raw] [file]\tprint the frames recorded by a wire.CaptureWriter", runCapture},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: arf <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "\t%s\n", c.usage)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			if err := c.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "arf %s: %s\n", c.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	usage()
+	os.Exit(2)
+}
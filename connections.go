@@ -0,0 +1,50 @@
+package arf
+
+import (
+	"context"
+	"github.com/arf-rpc/arf-go/wire"
+	"time"
+)
+
+// ConnectionStatus describes a connection established with a Server.
+type ConnectionStatus struct {
+	wire.ConnStatus
+	Streams []StreamStatus
+}
+
+// StreamStatus describes a stream of a connection established with a
+// Server. ExternalID holds the identifier assigned to its call by the
+// IDGenerator of the server.
+type StreamStatus struct {
+	wire.StreamStatus
+	// Service and Method identify the call carried by the stream while it
+	// is handled. Both are empty before its request is decoded, and once it
+	// concludes.
+	Service string
+	Method  string
+}
+
+func (s *srv) Connections() []ConnectionStatus {
+	conns := s.wireServer.Connections()
+	result := make([]ConnectionStatus, 0, len(conns))
+	for _, c := range conns {
+		st, streams := c.Status()
+		status := ConnectionStatus{ConnStatus: st, Streams: make([]StreamStatus, len(streams))}
+
+		s.streamsMu.Lock()
+		for i, str := range streams {
+			status.Streams[i].StreamStatus = str
+			if sc, ok := s.streamContext[str.ExternalID]; ok {
+				status.Streams[i].Service = sc.service
+				status.Streams[i].Method = sc.method
+			}
+		}
+		s.streamsMu.Unlock()
+		result = append(result, status)
+	}
+	return result
+}
+
+func (s *srv) PingConnection(ctx context.Context, id int) (time.Duration, error) {
+	return s.wireServer.Ping(ctx, id)
+}
@@ -0,0 +1,70 @@
+package arf
+
+import (
+	"sync"
+)
+
+// ConnectivityState describes the state of the connection held by a Client.
+type ConnectivityState int
+
+const (
+	// Idle indicates the client is not connected, and will only attempt to
+	// reconnect once a call is made.
+	Idle ConnectivityState = iota
+	// Connecting indicates a connection attempt is in progress.
+	Connecting
+	// Ready indicates the client is connected and able to perform calls.
+	Ready
+	// TransientFailure indicates the last connection attempt failed, and
+	// another will be made after a backoff delay.
+	TransientFailure
+	// Shutdown indicates the client was closed.
+	Shutdown
+)
+
+func (s ConnectivityState) String() string {
+	switch s {
+	case Idle:
+		return "Idle"
+	case Connecting:
+		return "Connecting"
+	case Ready:
+		return "Ready"
+	case TransientFailure:
+		return "TransientFailure"
+	case Shutdown:
+		return "Shutdown"
+	default:
+		return "Unknown"
+	}
+}
+
+// connectivity holds a ConnectivityState, notifying watchers of transitions
+// by closing a channel.
+type connectivity struct {
+	mu      sync.Mutex
+	state   ConnectivityState
+	changed chan struct{}
+}
+
+func newConnectivity(initial ConnectivityState) *connectivity {
+	return &connectivity{state: initial, changed: make(chan struct{})}
+}
+
+func (c *connectivity) get() (ConnectivityState, <-chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state, c.changed
+}
+
+// set transitions to s. Once in Shutdown, the state no longer changes.
+func (c *connectivity) set(s ConnectivityState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state == s || c.state == Shutdown {
+		return
+	}
+	c.state = s
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
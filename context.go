@@ -2,20 +2,52 @@ package arf
 
 import (
 	"context"
+	"errors"
+	"github.com/arf-rpc/arf-go/proto"
 	"github.com/arf-rpc/arf-go/rpc"
 	"github.com/arf-rpc/arf-go/status"
 	"github.com/arf-rpc/arf-go/wire"
+	"io"
+	"slices"
+	"sync"
 )
 
 type Context interface {
+	// Recv and Send honor the context of the call, failing with the
+	// Cancelled or DeadlineExceeded status once it ends.
 	Recv() (any, error)
 	Send(v any) error
+	// RecvContext and SendContext behave as Recv and Send, honoring ctx
+	// instead. In case ctx ends before they complete, the stream is reset.
+	RecvContext(ctx context.Context) (any, error)
+	SendContext(ctx context.Context, v any) error
 	EndSend() error
 	Response() *rpc.Response
 	Request() *rpc.Request
 	SendResponse(code status.Status, params []any, streaming bool, metadata rpc.Metadata) error
+
+	// SetHeader adds md to the metadata sent along the response. It fails
+	// once the response was sent.
+	SetHeader(md rpc.Metadata) error
+	// SendHeader sends md to the client right away. It requires a streaming
+	// response to have been sent; use SetHeader before that.
+	SendHeader(md rpc.Metadata) error
+	// SetTrailer adds md to the metadata sent once the outgoing stream ends,
+	// either through EndSend or by failing the call. Trailers set before a
+	// non-streaming response is sent are included in it.
+	SetTrailer(md rpc.Metadata)
+	// Header returns the metadata received along the response, merged with
+	// metadata sent by the server through SendHeader.
+	Header() rpc.Metadata
+	// Trailer returns the metadata received once the incoming stream ended,
+	// either normally or by a stream error, or along a non-streaming
+	// response.
+	Trailer() rpc.Metadata
 }
 
+// ResponseSentErr is returned by SetHeader once the response was sent.
+var ResponseSentErr = errors.New("arf: response already sent")
+
 type ctx struct {
 	str               wire.Stream
 	err               error
@@ -29,12 +61,180 @@ type ctx struct {
 	req               *rpc.Request
 	context           context.Context
 	hasSentResponse   bool
+	sendStreamEnded   bool
+	decodeOptions     proto.DecodeOptions
+
+	// server is set for contexts handling a request. pendingResponse is set
+	// on the client for streaming requests, whose response is only read once
+	// needed, so the request stream can be sent before it arrives.
+	server          bool
+	pendingResponse bool
+	outputMetadata  *rpc.Metadata
+
+	// rpc reports the call to a StatsHandler, when set, and sentStatus
+	// holds the status of the response sent by a handler.
+	rpc        *rpcTracker
+	sentStatus status.Status
+	// ended is closed once a client call concludes.
+	ended   chan struct{}
+	endOnce sync.Once
+
+	// header and trailer hold metadata to be sent, while recvTrailer holds
+	// the trailer received from the peer.
+	header      rpc.Metadata
+	trailer     rpc.Metadata
+	recvTrailer rpc.Metadata
+}
+
+// end reports the call as concluded with code, unless it already was.
+func (c *ctx) end(code status.Status) {
+	c.rpc.end(code)
+	if c.ended != nil {
+		c.endOnce.Do(func() { close(c.ended) })
+	}
+}
+
+func (c *ctx) reader() io.Reader {
+	return proto.NewDecoder(c.str, c.decodeOptions)
+}
+
+// messageSizeStatus converts errors caused by exceeding the maximum message
+// size, either locally or by the peer, into a ResourceExhausted status.
+func messageSizeStatus(err error) (*status.BadStatus, bool) {
+	var sizeErr *wire.MessageTooLargeError
+	var resetErr *wire.StreamResetError
+	switch {
+	case errors.As(err, &sizeErr):
+		return &status.BadStatus{Code: status.ResourceExhausted, Message: sizeErr.Error()}, true
+	case errors.As(err, &resetErr) && resetErr.Reason == wire.ErrorCodeEnhanceYourCalm:
+		return &status.BadStatus{Code: status.ResourceExhausted, Message: resetErr.Error()}, true
+	}
+	return nil, false
+}
+
+// recvErr records an error obtained while reading from the stream. Messages
+// exceeding the maximum receive size cause the stream to be reset so the
+// peer stops sending.
+func (c *ctx) recvErr(err error) error {
+	if st, ok := messageSizeStatus(err); ok {
+		_ = c.str.Reset(wire.ErrorCodeEnhanceYourCalm)
+		err = st
+	}
+	c.err = err
+	return err
+}
+
+// sendErr records an error obtained while writing to the stream. Messages
+// exceeding the maximum send size are never written, so the stream remains
+// usable in that case.
+func (c *ctx) sendErr(err error) error {
+	st, ok := messageSizeStatus(err)
+	if !ok {
+		c.err = err
+		return err
+	}
+	var sizeErr *wire.MessageTooLargeError
+	if !errors.As(err, &sizeErr) {
+		c.err = st
+	}
+	return st
+}
+
+// statusErr converts errors returned by Context into a *status.BadStatus,
+// except for the StreamEndError marking the end of a stream.
+func statusErr(err error) error {
+	if err == nil || IsStreamEnd(err) {
+		return err
+	}
+	return status.Convert(err)
+}
+
+// IsStreamEnd reports whether err marks the end of a stream, as returned by
+// Recv once the peer ended its stream normally.
+func IsStreamEnd(err error) bool {
+	var endErr *rpc.StreamEndError
+	return errors.As(err, &endErr)
+}
+
+// withContext performs op, resetting the stream in case ctx ends before op
+// completes, in which case ctx's error is returned and recorded.
+func (c *ctx) withContext(ctx context.Context, op func() error) error {
+	stop := context.AfterFunc(ctx, func() { _ = c.str.Reset(wire.ErrorCodeCancel) })
+	err := op()
+	if !stop() && ctx.Err() != nil {
+		c.err = ctx.Err()
+		return c.err
+	}
+	return err
+}
+
+func (c *ctx) Response() *rpc.Response {
+	_ = c.awaitResponse(c.context)
+	return c.resp
 }
 
-func (c *ctx) Response() *rpc.Response { return c.resp }
+// awaitResponse reads the response of a streaming request, unless it was
+// already read. In case it cannot be read, resp is set to a response
+// carrying the status of the failure.
+func (c *ctx) awaitResponse(ctx context.Context) error {
+	if !c.pendingResponse {
+		return nil
+	}
+	c.pendingResponse = false
+
+	var resp *rpc.Response
+	err := c.withContext(ctx, func() (err error) {
+		resp, err = c.ReadResponse()
+		return err
+	})
+	if err != nil {
+		bad := status.Convert(err)
+		meta, merr := rpc.StatusMetadata(bad)
+		if merr != nil {
+			meta = rpc.MetadataFromStringPairs(rpc.StatusDescriptionKey, bad.Message)
+		}
+		c.resp = &rpc.Response{Status: uint16(bad.Code), Metadata: meta}
+		c.end(bad.Code)
+		return err
+	}
+	if c.outputMetadata != nil {
+		*c.outputMetadata = resp.Header()
+	}
+	if !resp.Streaming {
+		c.end(status.Status(resp.Status))
+	}
+	return nil
+}
 
 func (c *ctx) Recv() (any, error) {
+	return c.RecvContext(c.context)
+}
+
+func (c *ctx) RecvContext(ctx context.Context) (any, error) {
+	if err := c.awaitResponse(ctx); err != nil {
+		return nil, statusErr(err)
+	}
+
+	var v any
+	err := c.withContext(ctx, func() (err error) {
+		v, err = c.recv()
+		return err
+	})
+	err = statusErr(err)
+	if IsStreamEnd(err) {
+		c.end(status.OK)
+	} else if err != nil {
+		c.end(status.Convert(err).Code)
+	}
+	return v, err
+}
+
+func (c *ctx) recv() (any, error) {
 	if !c.hasRecvStream {
+		if c.resp != nil && c.resp.Status != uint16(status.OK) {
+			_, err := c.resp.Result()
+			return nil, err
+		}
 		return nil, &rpc.NoStreamError{Recv: true}
 	}
 	if c.err != nil {
@@ -45,10 +245,9 @@ func (c *ctx) Recv() (any, error) {
 	}
 
 	if !c.recvStreamStarted {
-		msg, err := rpc.MessageFromReader(c.str)
+		msg, err := rpc.MessageFromReader(c.reader())
 		if err != nil {
-			c.err = err
-			return nil, err
+			return nil, c.recvErr(err)
 		}
 		if msg.Kind() == rpc.MessageKindStartStream {
 			c.recvStreamStarted = true
@@ -61,24 +260,29 @@ func (c *ctx) Recv() (any, error) {
 	}
 
 	for {
-		msg, err := rpc.MessageFromReader(c.str)
+		msg, err := rpc.MessageFromReader(c.reader())
 		if err != nil {
-			c.err = err
-			return nil, err
+			return nil, c.recvErr(err)
 		}
 
 		switch msg.Kind() {
 		case rpc.MessageKindStreamItem:
+			c.rpc.itemReceived()
 			return msg.(*rpc.StreamItem).Value, nil
 		case rpc.MessageKindEndStream:
+			c.recvTrailer = append(c.recvTrailer, msg.(*rpc.EndStream).Metadata...)
 			c.recvStreamError = &rpc.StreamEndError{}
 			return nil, c.recvStreamError
 		case rpc.MessageKindStreamError:
-			c.recvStreamError = msg.(*rpc.StreamError)
+			streamErr := msg.(*rpc.StreamError)
+			c.recvTrailer = append(c.recvTrailer, streamErr.Trailer()...)
+			c.recvStreamError = streamErr
 			return nil, c.recvStreamError
 		case rpc.MessageKindStreamMetadata:
 			meta := msg.(*rpc.StreamMetadata)
-			c.resp.Metadata = meta.Metadata
+			if c.resp != nil {
+				c.resp.Metadata = append(c.resp.Metadata, meta.Metadata...)
+			}
 		default:
 			c.err = &rpc.StreamFailure{Msg: "received unexpected message kind"}
 			return nil, c.err
@@ -87,6 +291,20 @@ func (c *ctx) Recv() (any, error) {
 }
 
 func (c *ctx) Send(v any) error {
+	return c.SendContext(c.context, v)
+}
+
+func (c *ctx) SendContext(ctx context.Context, v any) error {
+	err := statusErr(c.withContext(ctx, func() error {
+		return c.send(ctx, v)
+	}))
+	if err != nil && c.err != nil {
+		c.end(status.Convert(err).Code)
+	}
+	return err
+}
+
+func (c *ctx) send(ctx context.Context, v any) error {
 	if !c.hasSendStream {
 		return &rpc.NoStreamError{Recv: false}
 	}
@@ -97,20 +315,8 @@ func (c *ctx) Send(v any) error {
 		return c.sendStreamError
 	}
 
-	if !c.sendStreamStarted {
-		enc, err := (&rpc.StartStream{}).Wrap()
-		if err != nil {
-			c.sendStreamError = err
-			return err
-		}
-
-		err = c.str.Write(enc, false)
-		if err != nil {
-			c.err = err
-			return err
-		}
-
-		c.sendStreamStarted = true
+	if err := c.startSendStream(ctx); err != nil {
+		return err
 	}
 
 	enc, err := (&rpc.StreamItem{Value: v}).Wrap()
@@ -119,14 +325,39 @@ func (c *ctx) Send(v any) error {
 		return err
 	}
 
-	err = c.str.Write(enc, false)
+	if err = c.str.WriteContext(ctx, enc, false); err != nil {
+		return c.sendErr(err)
+	}
+	c.rpc.itemSent()
+	return nil
+}
+
+// startSendStream writes the StartStream message preceding every other
+// message of the outgoing stream, unless it was already written.
+func (c *ctx) startSendStream(ctx context.Context) error {
+	if c.sendStreamStarted {
+		return nil
+	}
+
+	enc, err := (&rpc.StartStream{}).Wrap()
 	if err != nil {
-		c.err = err
+		c.sendStreamError = err
+		return err
 	}
-	return err
+
+	if err = c.str.WriteContext(ctx, enc, false); err != nil {
+		return c.sendErr(err)
+	}
+
+	c.sendStreamStarted = true
+	return nil
 }
 
 func (c *ctx) EndSend() error {
+	return statusErr(c.endSend())
+}
+
+func (c *ctx) endSend() error {
 	if !c.hasSendStream {
 		return &rpc.NoStreamError{Recv: false}
 	}
@@ -137,17 +368,30 @@ func (c *ctx) EndSend() error {
 		return c.sendStreamError
 	}
 
-	msg := &rpc.EndStream{}
+	if err := c.startSendStream(context.Background()); err != nil {
+		return err
+	}
+
+	msg := &rpc.EndStream{Metadata: c.trailer}
 	data, err := msg.Wrap()
 	if err != nil {
 		c.err = err
 		return err
 	}
-	err = c.str.Write(data, true)
-	if err != nil {
-		c.err = err
+	if err = c.str.Write(data, true); err != nil {
+		return c.sendErr(err)
 	}
-	return err
+	c.sendStreamEnded = true
+	return nil
+}
+
+// beginStream sends a streaming response with the OK status on behalf of a
+// handler sending stream items without having sent a response first.
+func (c *ctx) beginStream() error {
+	if !c.server || c.hasSentResponse {
+		return nil
+	}
+	return c.SendResponse(status.OK, nil, true, nil)
 }
 
 func (c *ctx) ReadResponse() (*rpc.Response, error) {
@@ -155,10 +399,9 @@ func (c *ctx) ReadResponse() (*rpc.Response, error) {
 		return nil, c.err
 	}
 
-	resp, err := rpc.MessageTFromReader[*rpc.Response](c.str)
+	resp, err := rpc.MessageTFromReader[*rpc.Response](c.reader())
 	if err != nil {
-		c.err = err
-		return nil, err
+		return nil, c.recvErr(err)
 	}
 
 	c.resp = resp
@@ -168,10 +411,14 @@ func (c *ctx) ReadResponse() (*rpc.Response, error) {
 }
 
 func (c *ctx) SendResponse(code status.Status, params []any, streaming bool, metadata rpc.Metadata) error {
+	merged := append(slices.Clone(c.header), metadata...)
+	if !streaming {
+		merged = append(merged, rpc.TrailerMetadata(c.trailer)...)
+	}
 	resp := &rpc.Response{
 		Status:    uint16(code),
 		Streaming: streaming,
-		Metadata:  metadata,
+		Metadata:  merged,
 		Params:    params,
 	}
 
@@ -182,9 +429,70 @@ func (c *ctx) SendResponse(code status.Status, params []any, streaming bool, met
 
 	c.hasSentResponse = true
 	c.hasSendStream = streaming
+	c.sentStatus = code
 	c.sendStreamStarted = false
 
-	return c.str.Write(enc, !streaming)
+	if err = c.str.Write(enc, !streaming); err != nil {
+		var sizeErr *wire.MessageTooLargeError
+		if errors.As(err, &sizeErr) {
+			// Nothing was written, so an error response can still be sent in
+			// place of this one.
+			c.hasSentResponse = false
+			c.hasSendStream = false
+		}
+		return c.sendErr(err)
+	}
+
+	return nil
 }
 
 func (c *ctx) Request() *rpc.Request { return c.req }
+
+func (c *ctx) SetHeader(md rpc.Metadata) error {
+	if c.hasSentResponse {
+		return ResponseSentErr
+	}
+	c.header = append(c.header, md...)
+	return nil
+}
+
+func (c *ctx) SendHeader(md rpc.Metadata) error {
+	if !c.hasSentResponse || !c.hasSendStream {
+		return &rpc.NoStreamError{Recv: false}
+	}
+	if c.err != nil {
+		return c.err
+	}
+	if err := c.startSendStream(context.Background()); err != nil {
+		return statusErr(err)
+	}
+
+	enc, err := (&rpc.StreamMetadata{Metadata: md}).Wrap()
+	if err != nil {
+		return err
+	}
+	if err = c.str.Write(enc, false); err != nil {
+		return statusErr(c.sendErr(err))
+	}
+	return nil
+}
+
+func (c *ctx) SetTrailer(md rpc.Metadata) {
+	c.trailer = append(c.trailer, md...)
+}
+
+func (c *ctx) Header() rpc.Metadata {
+	_ = c.awaitResponse(c.context)
+	if c.resp == nil {
+		return nil
+	}
+	return c.resp.Header()
+}
+
+func (c *ctx) Trailer() rpc.Metadata {
+	// Non-streaming responses carry their trailer along with the header.
+	if c.resp != nil && !c.resp.Streaming {
+		return c.resp.Trailer()
+	}
+	return c.recvTrailer
+}
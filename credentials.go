@@ -0,0 +1,58 @@
+package arf
+
+import (
+	"context"
+	"errors"
+	"github.com/arf-rpc/arf-go/rpc"
+	"github.com/arf-rpc/arf-go/status"
+	"slices"
+)
+
+// PerCallCredentials provides metadata attached to every request a client
+// makes, such as authorization tokens.
+type PerCallCredentials interface {
+	// RequestMetadata returns the metadata to be set on a request to method
+	// of service. Keys present in the request metadata are replaced.
+	RequestMetadata(ctx context.Context, service, method string) (rpc.Metadata, error)
+}
+
+// WithCredentials attaches creds to every call made by the client.
+func WithCredentials(creds PerCallCredentials) ClientOption {
+	return func(c *client) {
+		c.credentials = creds
+	}
+}
+
+// WithCallCredentials attaches creds to a single call, replacing credentials
+// configured on the client.
+func WithCallCredentials(creds PerCallCredentials) CallOption {
+	return func(r *rpc.Request, o *callOptions) {
+		o.credentials = creds
+	}
+}
+
+// applyCredentials sets the metadata provided by creds on req. Failures
+// obtaining credentials are reported as Unauthenticated, unless creds
+// returned a status of its own.
+func applyCredentials(ctx context.Context, creds PerCallCredentials, req *rpc.Request) error {
+	meta, err := creds.RequestMetadata(ctx, req.Service, req.Method)
+	if err != nil {
+		var bad *status.BadStatus
+		if errors.As(err, &bad) {
+			return err
+		}
+		return &status.BadStatus{Code: status.Unauthenticated, Message: "obtaining credentials: " + err.Error()}
+	}
+
+	req.Metadata = mergeMetadata(req.Metadata, meta)
+	return nil
+}
+
+// mergeMetadata returns a copy of base in which the keys present in meta
+// are replaced by their values in meta.
+func mergeMetadata(base, meta rpc.Metadata) rpc.Metadata {
+	merged := slices.DeleteFunc(slices.Clone(base), func(pair rpc.MetadataPair) bool {
+		return slices.ContainsFunc(meta, func(p rpc.MetadataPair) bool { return p.Key == pair.Key })
+	})
+	return append(merged, meta...)
+}
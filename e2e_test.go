@@ -0,0 +1,484 @@
+package arf
+
+import (
+	"context"
+	"github.com/arf-rpc/arf-go/proto"
+	"github.com/arf-rpc/arf-go/rpc"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+const e2eService = "org.example.test/E2E"
+
+func recvAll[T any](in InStreamer[T]) ([]T, error) {
+	var items []T
+	for {
+		v, err := in.Recv()
+		if IsStreamEnd(err) {
+			return items, nil
+		} else if err != nil {
+			return items, err
+		}
+		items = append(items, v)
+	}
+}
+
+func makeServer(t *testing.T, methods map[string]ServiceExecutor) Server {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server, err := NewServer(l, ServerOptions{})
+	require.NoError(t, err)
+	go func() { _ = server.Serve() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	server.MustRegisterService(ServiceAdapter{ServiceID: e2eService, Methods: methods})
+	return server
+}
+
+func waitSignal[T any](t *testing.T, ch chan T) T {
+	t.Helper()
+
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for signal")
+		panic("unreachable")
+	}
+}
+
+func makeE2EServer(t *testing.T) Server {
+	t.Helper()
+
+	return makeServer(t, map[string]ServiceExecutor{
+		"Echo": func(ctx context.Context, c Context) error {
+			return c.SendResponse(status.OK, c.Request().Params, false, nil)
+		},
+		"Fail": func(ctx context.Context, c Context) error {
+			return status.Error(status.InvalidArgument, "rejected")
+		},
+		"Count": func(ctx context.Context, c Context) error {
+			out := MakeOutStream[string](c)
+			for _, v := range []string{"a", "b", "c"} {
+				if err := out.Send(v); err != nil {
+					return err
+				}
+			}
+			c.SetTrailer(rpc.MetadataFromStringPairs("count", "3"))
+			return nil
+		},
+		"CountFail": func(ctx context.Context, c Context) error {
+			if err := MakeOutStream[string](c).Send("a"); err != nil {
+				return err
+			}
+			return status.Error(status.Aborted, "interrupted")
+		},
+		"Concat": func(ctx context.Context, c Context) error {
+			items, err := recvAll(MakeInStream[string](c))
+			if err != nil {
+				return err
+			}
+			return c.SendResponse(status.OK, []any{strings.Join(items, "")}, false, nil)
+		},
+		"ConcatFail": func(ctx context.Context, c Context) error {
+			if _, err := recvAll(MakeInStream[string](c)); err != nil {
+				return err
+			}
+			return status.Error(status.FailedPrecondition, "cannot concat")
+		},
+		"Upper": func(ctx context.Context, c Context) error {
+			str := MakeInOutStream[string, string](c)
+			for {
+				v, err := str.Recv()
+				if IsStreamEnd(err) {
+					return nil
+				} else if err != nil {
+					return err
+				}
+				if err = str.Send(strings.ToUpper(v)); err != nil {
+					return err
+				}
+			}
+		},
+		"UpperFail": func(ctx context.Context, c Context) error {
+			str := MakeInOutStream[string, string](c)
+			v, err := str.Recv()
+			if err != nil {
+				return err
+			}
+			if err = str.Send(strings.ToUpper(v)); err != nil {
+				return err
+			}
+			return status.Error(status.Aborted, "interrupted")
+		},
+	})
+}
+
+func TestEndToEnd(t *testing.T) {
+	server := makeE2EServer(t)
+	transports := map[string]func(t *testing.T) Client{
+		"tcp": func(t *testing.T) Client {
+			c, err := Dial(server.(*srv).listener.Addr().String())
+			require.NoError(t, err)
+			return c
+		},
+		"in-process": func(t *testing.T) Client {
+			c, err := server.InProcessClient()
+			require.NoError(t, err)
+			return c
+		},
+	}
+
+	for name, connect := range transports {
+		t.Run(name, func(t *testing.T) {
+			c := connect(t)
+			t.Cleanup(func() { _ = c.Close() })
+			ctx := context.Background()
+
+			t.Run("unary", func(t *testing.T) {
+				call, err := c.Call(ctx, e2eService, "Echo", WithParams("hello"))
+				require.NoError(t, err)
+				result, err := call.Response().Result()
+				require.NoError(t, err)
+				assert.Equal(t, []any{"hello"}, result)
+			})
+
+			t.Run("unary error", func(t *testing.T) {
+				call, err := c.Call(ctx, e2eService, "Fail")
+				require.NoError(t, err)
+				_, err = call.Response().Result()
+				assert.Equal(t, status.InvalidArgument, status.Convert(err).Code)
+			})
+
+			t.Run("server streaming", func(t *testing.T) {
+				call, err := c.Call(ctx, e2eService, "Count")
+				require.NoError(t, err)
+				require.True(t, call.Response().Streaming)
+				items, err := recvAll(MakeInStream[string](call))
+				require.NoError(t, err)
+				assert.Equal(t, []string{"a", "b", "c"}, items)
+				v, _ := call.Trailer().LookupString("count")
+				assert.Equal(t, "3", v)
+			})
+
+			t.Run("server streaming error", func(t *testing.T) {
+				call, err := c.Call(ctx, e2eService, "CountFail")
+				require.NoError(t, err)
+				items, err := recvAll(MakeInStream[string](call))
+				assert.Equal(t, []string{"a"}, items)
+				assert.Equal(t, status.Aborted, status.Convert(err).Code)
+				assert.ErrorAs(t, err, new(*status.BadStatus))
+			})
+
+			t.Run("client streaming", func(t *testing.T) {
+				call, err := c.Call(ctx, e2eService, "Concat", WithStream())
+				require.NoError(t, err)
+				out := MakeOutStream[string](call)
+				for _, v := range []string{"a", "b", "c"} {
+					require.NoError(t, out.Send(v))
+				}
+				require.NoError(t, out.Close())
+				result, err := call.Response().Result()
+				require.NoError(t, err)
+				assert.Equal(t, []any{"abc"}, result)
+			})
+
+			t.Run("client streaming error", func(t *testing.T) {
+				call, err := c.Call(ctx, e2eService, "ConcatFail", WithStream())
+				require.NoError(t, err)
+				out := MakeOutStream[string](call)
+				require.NoError(t, out.Send("a"))
+				require.NoError(t, out.Close())
+				_, err = call.Response().Result()
+				assert.Equal(t, status.FailedPrecondition, status.Convert(err).Code)
+			})
+
+			t.Run("bidirectional streaming", func(t *testing.T) {
+				call, err := c.Call(ctx, e2eService, "Upper", WithStream())
+				require.NoError(t, err)
+				str := MakeInOutStream[string, string](call)
+				for _, v := range []string{"a", "b"} {
+					require.NoError(t, str.Send(v))
+					got, err := str.Recv()
+					require.NoError(t, err)
+					assert.Equal(t, strings.ToUpper(v), got)
+				}
+				require.NoError(t, str.Close())
+				_, err = str.Recv()
+				assert.True(t, IsStreamEnd(err))
+			})
+
+			t.Run("bidirectional streaming error", func(t *testing.T) {
+				call, err := c.Call(ctx, e2eService, "UpperFail", WithStream())
+				require.NoError(t, err)
+				str := MakeInOutStream[string, string](call)
+				require.NoError(t, str.Send("a"))
+				got, err := str.Recv()
+				require.NoError(t, err)
+				assert.Equal(t, "A", got)
+				_, err = str.Recv()
+				assert.Equal(t, status.Aborted, status.Convert(err).Code)
+			})
+
+			t.Run("unknown method", func(t *testing.T) {
+				call, err := c.Call(ctx, e2eService, "Missing", WithStream())
+				require.NoError(t, err)
+				_, err = call.Recv()
+				assert.Equal(t, status.Unimplemented, status.Convert(err).Code)
+			})
+		})
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	md := rpc.MetadataFromStringPairs
+	sentErrs := make(chan error, 1)
+	server := makeServer(t, map[string]ServiceExecutor{
+		"Unary": func(ctx context.Context, c Context) error {
+			if err := c.SetHeader(md("h", "1")); err != nil {
+				return err
+			}
+			c.SetTrailer(md("t", "1"))
+			if err := c.SendResponse(status.OK, nil, false, md("r", "1")); err != nil {
+				return err
+			}
+			sentErrs <- c.SetHeader(md("late", "1"))
+			return nil
+		},
+		"UnaryFail": func(ctx context.Context, c Context) error {
+			if err := c.SetHeader(md("h", "1")); err != nil {
+				return err
+			}
+			c.SetTrailer(md("t", "1"))
+			return status.Error(status.InvalidArgument, "rejected")
+		},
+		"Stream": func(ctx context.Context, c Context) error {
+			if err := c.SetHeader(md("h", "1")); err != nil {
+				return err
+			}
+			out := MakeOutStream[string](c)
+			if err := out.Send("a"); err != nil {
+				return err
+			}
+			if err := c.SendHeader(md("h", "2")); err != nil {
+				return err
+			}
+			if err := out.Send("b"); err != nil {
+				return err
+			}
+			c.SetTrailer(md("t", "2"))
+			return nil
+		},
+		"StreamFail": func(ctx context.Context, c Context) error {
+			if err := MakeOutStream[string](c).Send("a"); err != nil {
+				return err
+			}
+			c.SetTrailer(md("t", "3"))
+			return status.Error(status.Aborted, "interrupted")
+		},
+	})
+	c, err := server.InProcessClient()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	ctx := context.Background()
+
+	t.Run("unary responses carry headers and trailers apart", func(t *testing.T) {
+		call, err := c.Call(ctx, e2eService, "Unary")
+		require.NoError(t, err)
+		_, err = call.Response().Result()
+		require.NoError(t, err)
+		assert.Equal(t, md("h", "1", "r", "1"), call.Header())
+		assert.Equal(t, md("t", "1"), call.Trailer())
+		assert.ErrorIs(t, waitSignal(t, sentErrs), ResponseSentErr)
+	})
+
+	t.Run("unary errors carry headers and trailers apart", func(t *testing.T) {
+		call, err := c.Call(ctx, e2eService, "UnaryFail")
+		require.NoError(t, err)
+		_, err = call.Response().Result()
+		assert.Equal(t, status.InvalidArgument, status.Convert(err).Code)
+		assert.Equal(t, []string{"1"}, call.Header().GetAllString("h"))
+		assert.Empty(t, call.Header().GetAllString("t"))
+		assert.Equal(t, md("t", "1"), call.Trailer())
+	})
+
+	t.Run("SendHeader merges into Header", func(t *testing.T) {
+		call, err := c.Call(ctx, e2eService, "Stream")
+		require.NoError(t, err)
+		assert.Equal(t, md("h", "1"), call.Header())
+		items, err := recvAll(MakeInStream[string](call))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, items)
+		assert.Equal(t, md("h", "1", "h", "2"), call.Header())
+		assert.Equal(t, md("t", "2"), call.Trailer())
+	})
+
+	t.Run("stream errors carry trailers", func(t *testing.T) {
+		call, err := c.Call(ctx, e2eService, "StreamFail")
+		require.NoError(t, err)
+		items, err := recvAll(MakeInStream[string](call))
+		assert.Equal(t, []string{"a"}, items)
+		assert.Equal(t, status.Aborted, status.Convert(err).Code)
+		assert.Equal(t, md("t", "3"), call.Trailer())
+	})
+}
+
+func TestStreamContext(t *testing.T) {
+	canceled := make(chan error, 1)
+	received := make(chan error, 1)
+	server := makeServer(t, map[string]ServiceExecutor{
+		"Hang": func(ctx context.Context, c Context) error {
+			if err := c.SendResponse(status.OK, nil, true, nil); err != nil {
+				return err
+			}
+			<-ctx.Done()
+			canceled <- ctx.Err()
+			return ctx.Err()
+		},
+		"Drain": func(ctx context.Context, c Context) error {
+			rctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+			defer cancel()
+			_, err := c.RecvContext(rctx)
+			received <- err
+			return err
+		},
+	})
+	c, err := server.InProcessClient()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+
+	t.Run("RecvContext gives up once its context is done", func(t *testing.T) {
+		call, err := c.Call(context.Background(), e2eService, "Hang")
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err = MakeInStream[string](call).RecvContext(ctx)
+		assert.Equal(t, status.DeadlineExceeded, status.Convert(err).Code)
+		assert.ErrorIs(t, waitSignal(t, canceled), context.Canceled)
+
+		_, err = call.Recv()
+		assert.Equal(t, status.DeadlineExceeded, status.Convert(err).Code)
+	})
+
+	t.Run("Recv honors the context of the call", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		call, err := c.Call(ctx, e2eService, "Hang")
+		require.NoError(t, err)
+
+		time.AfterFunc(20*time.Millisecond, cancel)
+		_, err = call.Recv()
+		assert.Equal(t, status.Cancelled, status.Convert(err).Code)
+		assert.ErrorIs(t, waitSignal(t, canceled), context.Canceled)
+	})
+
+	t.Run("calls with a canceled context fail with Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		call, err := c.Call(ctx, e2eService, "Hang")
+		assert.Nil(t, call)
+		assert.Equal(t, status.Cancelled, status.Convert(err).Code)
+	})
+
+	t.Run("handlers may bound RecvContext", func(t *testing.T) {
+		call, err := c.Call(context.Background(), e2eService, "Drain", WithStream())
+		require.NoError(t, err)
+		err = waitSignal(t, received)
+		assert.Equal(t, status.DeadlineExceeded, status.Convert(err).Code)
+		_, err = call.Recv()
+		assert.Error(t, err)
+	})
+}
+
+type E2EItem struct {
+	Name  string `arf:"0"`
+	Count uint32 `arf:"1"`
+}
+
+func (E2EItem) ArfStructID() string { return "org.example.test/E2EItem" }
+
+func init() {
+	proto.RegisterMessage(E2EItem{})
+}
+
+func TestTypedStreams(t *testing.T) {
+	server := makeServer(t, map[string]ServiceExecutor{
+		"Numbers": func(ctx context.Context, c Context) error {
+			out := MakeOutStream[int32](c)
+			for i := range int32(3) {
+				if err := out.Send(i); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		"Items": func(ctx context.Context, c Context) error {
+			return MakeOutStream[E2EItem](c).Send(E2EItem{Name: "a", Count: 1})
+		},
+		"Sum": func(ctx context.Context, c Context) error {
+			numbers, err := recvAll(MakeInStream[int16](c))
+			if err != nil {
+				return err
+			}
+			var sum int16
+			for _, n := range numbers {
+				sum += n
+			}
+			return c.SendResponse(status.OK, []any{sum}, false, nil)
+		},
+	})
+	c, err := server.InProcessClient()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	ctx := context.Background()
+
+	t.Run("integers are narrowed", func(t *testing.T) {
+		call, err := c.Call(ctx, e2eService, "Numbers")
+		require.NoError(t, err)
+		numbers, err := recvAll(MakeInStream[int32](call))
+		require.NoError(t, err)
+		assert.Equal(t, []int32{0, 1, 2}, numbers)
+	})
+
+	t.Run("structs are received as values or pointers", func(t *testing.T) {
+		call, err := c.Call(ctx, e2eService, "Items")
+		require.NoError(t, err)
+		items, err := recvAll(MakeInStream[E2EItem](call))
+		require.NoError(t, err)
+		assert.Equal(t, []E2EItem{{Name: "a", Count: 1}}, items)
+
+		call, err = c.Call(ctx, e2eService, "Items")
+		require.NoError(t, err)
+		ptrs, err := recvAll(MakeInStream[*E2EItem](call))
+		require.NoError(t, err)
+		assert.Equal(t, []*E2EItem{{Name: "a", Count: 1}}, ptrs)
+	})
+
+	t.Run("handlers receive typed items", func(t *testing.T) {
+		call, err := c.Call(ctx, e2eService, "Sum", WithStream())
+		require.NoError(t, err)
+		out := MakeOutStream[int16](call)
+		for _, n := range []int16{1, 2, 3} {
+			require.NoError(t, out.Send(n))
+		}
+		require.NoError(t, out.Close())
+		result, err := call.Response().Result()
+		require.NoError(t, err)
+		assert.Equal(t, []any{int64(6)}, result)
+	})
+
+	t.Run("mismatching items fail with InvalidArgument", func(t *testing.T) {
+		call, err := c.Call(ctx, e2eService, "Items")
+		require.NoError(t, err)
+		_, err = MakeInStream[string](call).Recv()
+		assert.Equal(t, status.InvalidArgument, status.Convert(err).Code)
+		assert.ErrorAs(t, err, new(*proto.ConversionError))
+	})
+}
@@ -0,0 +1,330 @@
+// Package gateway exposes arf services over HTTP, accepting JSON-encoded
+// parameters and producing JSON responses.
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"github.com/arf-rpc/arf-go"
+	"github.com/arf-rpc/arf-go/proto"
+	"github.com/arf-rpc/arf-go/rpc"
+	"github.com/arf-rpc/arf-go/status"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// MetadataHeaderPrefix is the prefix of HTTP headers converted to and from
+// arf metadata. A request header "Arf-Metadata-Foo" becomes the "foo"
+// metadata key, and response metadata is returned the same way.
+const MetadataHeaderPrefix = "Arf-Metadata-"
+
+const (
+	contentTypeJSON   = "application/json"
+	contentTypeNDJSON = "application/x-ndjson"
+	contentTypeSSE    = "text/event-stream"
+)
+
+// HeaderMatcher maps an HTTP request header name to a metadata key. ok
+// reports whether the header should be forwarded.
+type HeaderMatcher func(name string) (key string, ok bool)
+
+// DefaultHeaderMatcher forwards headers prefixed by MetadataHeaderPrefix, and
+// the Authorization header as the "authorization" key.
+func DefaultHeaderMatcher(name string) (string, bool) {
+	name = http.CanonicalHeaderKey(name)
+	if name == "Authorization" {
+		return "authorization", true
+	}
+	if strings.HasPrefix(name, MetadataHeaderPrefix) {
+		return strings.ToLower(strings.TrimPrefix(name, MetadataHeaderPrefix)), true
+	}
+	return "", false
+}
+
+type Option func(*Gateway)
+
+// WithHeaderMatcher replaces DefaultHeaderMatcher.
+func WithHeaderMatcher(m HeaderMatcher) Option {
+	return func(g *Gateway) {
+		g.headerMatcher = m
+	}
+}
+
+// WithMaxBodySize limits the size of request bodies. Defaults to 4 MiB.
+func WithMaxBodySize(n int64) Option {
+	return func(g *Gateway) {
+		g.maxBodySize = n
+	}
+}
+
+// Gateway is an http.Handler accepting POST requests to /{service}/{method}
+// with a JSON array of parameters as body, and invoking the method through
+// an arf.Client. Unary responses are written as a JSON array of response
+// parameters; server-streaming responses are written as newline-delimited
+// JSON, or as server-sent events when the client accepts text/event-stream.
+type Gateway struct {
+	client        arf.Client
+	headerMatcher HeaderMatcher
+	maxBodySize   int64
+}
+
+// New returns a Gateway proxying calls through client.
+func New(client arf.Client, opts ...Option) *Gateway {
+	g := &Gateway{
+		client:        client,
+		headerMatcher: DefaultHeaderMatcher,
+		maxBodySize:   4 << 20,
+	}
+	for _, fn := range opts {
+		fn(g)
+	}
+	return g
+}
+
+// NewLocal returns a Gateway invoking services registered on server through
+// an in-process connection.
+func NewLocal(server arf.Server, opts ...Option) (*Gateway, error) {
+	client, err := server.InProcessClient()
+	if err != nil {
+		return nil, err
+	}
+	return New(client, opts...), nil
+}
+
+// Close closes the underlying client.
+func (g *Gateway) Close() error {
+	return g.client.Close()
+}
+
+// HTTPStatus returns the HTTP status code corresponding to an arf status.
+func HTTPStatus(code status.Status) int {
+	switch code {
+	case status.OK:
+		return http.StatusOK
+	case status.Cancelled:
+		return 499 // Client Closed Request
+	case status.InvalidArgument, status.FailedPrecondition, status.OutOfRange:
+		return http.StatusBadRequest
+	case status.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case status.NotFound:
+		return http.StatusNotFound
+	case status.AlreadyExists, status.Aborted:
+		return http.StatusConflict
+	case status.PermissionDenied:
+		return http.StatusForbidden
+	case status.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case status.Unimplemented:
+		return http.StatusNotImplemented
+	case status.Unavailable:
+		return http.StatusServiceUnavailable
+	case status.Unauthenticated:
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func errorJSON(bad *status.BadStatus) []byte {
+	obj := map[string]any{
+		"code":    int(bad.Code),
+		"status":  bad.Code.Error(),
+		"message": bad.Message,
+	}
+	if len(bad.Details) > 0 {
+		obj["details"] = bad.Details
+	}
+	data, err := proto.ToJSON(obj)
+	if err != nil {
+		delete(obj, "details")
+		data, _ = proto.ToJSON(obj)
+	}
+	return data
+}
+
+func writeError(w http.ResponseWriter, bad *status.BadStatus) {
+	writeErrorStatus(w, HTTPStatus(bad.Code), bad)
+}
+
+// writeErrorStatus writes bad as writeError does, with an HTTP status code
+// not derived from bad.Code.
+func writeErrorStatus(w http.ResponseWriter, code int, bad *status.BadStatus) {
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(code)
+	_, _ = w.Write(errorJSON(bad))
+}
+
+func splitPath(path string) (service, method string, ok bool) {
+	path = strings.Trim(path, "/")
+	idx := strings.LastIndexByte(path, '/')
+	if idx <= 0 || idx == len(path)-1 {
+		return "", "", false
+	}
+	return path[:idx], path[idx+1:], true
+}
+
+func (g *Gateway) metadataFromHeaders(h http.Header) rpc.Metadata {
+	meta := rpc.Metadata{}
+	for name, values := range h {
+		key, ok := g.headerMatcher(name)
+		if !ok {
+			continue
+		}
+		for _, v := range values {
+			meta.AddString(key, v)
+		}
+	}
+	return meta
+}
+
+func metadataToHeaders(meta rpc.Metadata, h http.Header) {
+	for _, pair := range meta {
+		if pair.Key == rpc.StatusDescriptionKey || pair.Key == rpc.StatusDetailsKey {
+			continue
+		}
+		h.Add(MetadataHeaderPrefix+pair.Key, string(pair.Value))
+	}
+}
+
+func (g *Gateway) readParams(r *http.Request) ([]any, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, g.maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > g.maxBodySize {
+		return nil, fmt.Errorf("request body exceeds %d bytes", g.maxBodySize)
+	}
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return nil, nil
+	}
+
+	parsed, err := proto.FromJSON(body, "")
+	if err != nil {
+		return nil, err
+	}
+	params, ok := parsed.([]any)
+	if !ok {
+		return nil, fmt.Errorf("request body must be a JSON array of parameters")
+	}
+	return params, nil
+}
+
+func acceptsSSE(r *http.Request) bool {
+	for _, v := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, _, err := mime.ParseMediaType(strings.TrimSpace(v))
+		if err == nil && mt == contentTypeSSE {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeErrorStatus(w, http.StatusMethodNotAllowed, &status.BadStatus{Code: status.Unimplemented, Message: "only POST is supported"})
+		return
+	}
+
+	service, method, ok := splitPath(r.URL.Path)
+	if !ok {
+		writeError(w, &status.BadStatus{Code: status.NotFound, Message: "expected a path in the form /{service}/{method}"})
+		return
+	}
+
+	params, err := g.readParams(r)
+	if err != nil {
+		writeError(w, &status.BadStatus{Code: status.InvalidArgument, Message: err.Error()})
+		return
+	}
+
+	call, err := g.client.Call(r.Context(), service, method,
+		arf.WithParams(params...),
+		arf.WithMetadata(g.metadataFromHeaders(r.Header)))
+	if err != nil {
+		writeError(w, status.Convert(err))
+		return
+	}
+
+	resp := call.Response()
+	result, err := resp.Result()
+	meta := slices.Concat(resp.Header(), resp.Trailer())
+	if err != nil {
+		metadataToHeaders(meta, w.Header())
+		writeError(w, status.Convert(err))
+		return
+	}
+	metadataToHeaders(meta, w.Header())
+
+	if !resp.Streaming {
+		data, err := proto.ToJSON(result)
+		if err != nil {
+			writeError(w, &status.BadStatus{Code: status.InternalError, Message: err.Error()})
+			return
+		}
+		w.Header().Set("Content-Type", contentTypeJSON)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(data)
+		return
+	}
+
+	g.stream(w, r, call)
+}
+
+// stream writes items received from call as they arrive, until the server
+// ends the stream. Errors found after the response headers were written are
+// reported in-band.
+func (g *Gateway) stream(w http.ResponseWriter, r *http.Request, call arf.Context) {
+	sse := acceptsSSE(r)
+	if sse {
+		w.Header().Set("Content-Type", contentTypeSSE)
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", contentTypeNDJSON)
+	}
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	write := func(event string, data []byte) {
+		if sse {
+			if event != "" {
+				_, _ = fmt.Fprintf(w, "event: %s\n", event)
+			}
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", data)
+		} else if event == "error" {
+			_, _ = fmt.Fprintf(w, "{\"error\":%s}\n", data)
+		} else {
+			_, _ = fmt.Fprintf(w, "%s\n", data)
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	for {
+		item, err := call.Recv()
+		var endErr *rpc.StreamEndError
+		var streamErr *rpc.StreamError
+		switch {
+		case errors.As(err, &endErr):
+			return
+		case errors.As(err, &streamErr):
+			write("error", errorJSON(streamErr.BadStatus()))
+			return
+		case err != nil:
+			write("error", errorJSON(status.Convert(err)))
+			return
+		}
+
+		data, err := proto.ToJSON(item)
+		if err != nil {
+			write("error", errorJSON(&status.BadStatus{Code: status.InternalError, Message: err.Error()}))
+			return
+		}
+		write("", data)
+	}
+}
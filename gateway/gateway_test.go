@@ -0,0 +1,150 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"github.com/arf-rpc/arf-go"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func makeGateway(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server, err := arf.NewServer(l, arf.ServerOptions{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	server.MustRegisterService(arf.ServiceAdapter{
+		ServiceID: "org.example.test/Greeter",
+		Methods: map[string]arf.ServiceExecutor{
+			"Greet": func(ctx context.Context, c arf.Context) error {
+				params := c.Request().Params
+				if len(params) != 1 {
+					return status.Error(status.InvalidArgument, "expected a name")
+				}
+				if params[0] == "" {
+					return status.WithDetails(status.InvalidArgument, "invalid name", &status.BadRequest{
+						FieldViolations: []status.FieldViolation{{Field: "name", Description: "must not be empty"}},
+					})
+				}
+				greeting := c.Request().Metadata.GetString("greeting")
+				return c.SendResponse(status.OK, []any{greeting + ", " + params[0].(string)}, false, c.Request().Metadata)
+			},
+			"Count": func(ctx context.Context, c arf.Context) error {
+				if err := c.SendResponse(status.OK, nil, true, nil); err != nil {
+					return err
+				}
+				for i := range c.Request().Params[0].(int64) {
+					if err := c.Send(uint64(i)); err != nil {
+						return err
+					}
+				}
+				return c.EndSend()
+			},
+		},
+	})
+
+	gw, err := NewLocal(server)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = gw.Close() })
+
+	srv := httptest.NewServer(gw)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func post(t *testing.T, url, body string, headers ...string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	require.NoError(t, err)
+	for i := 0; i < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestGateway(t *testing.T) {
+	srv := makeGateway(t)
+
+	t.Run("unary call", func(t *testing.T) {
+		resp := post(t, srv.URL+"/org.example.test/Greeter/Greet", `["arf"]`,
+			"Arf-Metadata-Greeting", "Hello")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `["Hello, arf"]`, readBody(t, resp))
+		assert.Equal(t, "Hello", resp.Header.Get("Arf-Metadata-Greeting"))
+	})
+
+	t.Run("status codes are mapped", func(t *testing.T) {
+		resp := post(t, srv.URL+"/org.example.test/Greeter/Greet", `[]`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, `{"code":3,"message":"expected a name","status":"Invalid Argument"}`, readBody(t, resp))
+
+		resp = post(t, srv.URL+"/org.example.test/Greeter/Greet", `[""]`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, `{"code":3,"details":[{"@type":"arf.status/BadRequest","FieldViolations":[{"@type":"arf.status/FieldViolation","Field":"name","Description":"must not be empty"}]}],"message":"invalid name","status":"Invalid Argument"}`, readBody(t, resp))
+
+		resp = post(t, srv.URL+"/org.example.test/Greeter/Missing", `[]`)
+		assert.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		resp := post(t, srv.URL+"/org.example.test/Greeter/Greet", `{"not": "an array"}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp = post(t, srv.URL+"/Greet", `[]`)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		get, err := http.Get(srv.URL + "/org.example.test/Greeter/Greet")
+		require.NoError(t, err)
+		_ = get.Body.Close()
+		assert.Equal(t, http.StatusMethodNotAllowed, get.StatusCode)
+		assert.Equal(t, http.MethodPost, get.Header.Get("Allow"))
+	})
+
+	t.Run("streaming as NDJSON", func(t *testing.T) {
+		resp := post(t, srv.URL+"/org.example.test/Greeter/Count", `[3]`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, contentTypeNDJSON, resp.Header.Get("Content-Type"))
+		assert.Equal(t, "0\n1\n2\n", readBody(t, resp))
+	})
+
+	t.Run("streaming as server-sent events", func(t *testing.T) {
+		resp := post(t, srv.URL+"/org.example.test/Greeter/Count", `[2]`, "Accept", contentTypeSSE)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, contentTypeSSE, resp.Header.Get("Content-Type"))
+
+		var lines []string
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		assert.Equal(t, []string{"data: 0", "", "data: 1", ""}, lines)
+	})
+}
+
+func TestHTTPStatus(t *testing.T) {
+	assert.Equal(t, http.StatusOK, HTTPStatus(status.OK))
+	assert.Equal(t, http.StatusNotFound, HTTPStatus(status.NotFound))
+	assert.Equal(t, http.StatusUnauthorized, HTTPStatus(status.Unauthenticated))
+	assert.Equal(t, http.StatusInternalServerError, HTTPStatus(status.Status(999)))
+}
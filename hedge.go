@@ -0,0 +1,179 @@
+package arf
+
+import (
+	"context"
+	"github.com/arf-rpc/arf-go/rpc"
+	"github.com/arf-rpc/arf-go/status"
+	"slices"
+	"time"
+)
+
+// HedgingPolicy determines how unary calls are hedged: in case an attempt
+// takes longer than Delay to be answered, the same request is sent again
+// while the previous attempts remain in flight. The first response obtained
+// is used, and the remaining attempts are reset. As with retries, policies
+// should only be configured for idempotent methods.
+type HedgingPolicy struct {
+	// MaxAttempts is the maximum amount of attempts, including the original
+	// call. Values below 2 disable hedging.
+	MaxAttempts int
+	// Delay is the time to wait for a response before sending the next
+	// attempt.
+	Delay time.Duration
+	// NonFatalCodes lists statuses causing the next attempt to be sent right
+	// away instead of failing the call. Defaults to Unavailable.
+	NonFatalCodes []status.Status
+}
+
+func (p *HedgingPolicy) nonFatal(code status.Status) bool {
+	if len(p.NonFatalCodes) == 0 {
+		return code == status.Unavailable
+	}
+	return slices.Contains(p.NonFatalCodes, code)
+}
+
+// WithHedgingPolicy hedges unary calls to method of service according to
+// policy. An empty method applies policy to every method of service lacking
+// a policy of its own. Streaming calls are never hedged. Methods having both
+// a hedging and a retry policy are hedged.
+func WithHedgingPolicy(service, method string, policy HedgingPolicy) ClientOption {
+	return func(c *client) {
+		if c.hedgingPolicies == nil {
+			c.hedgingPolicies = map[retryKey]HedgingPolicy{}
+		}
+		c.hedgingPolicies[retryKey{service, method}] = policy
+	}
+}
+
+func (c *client) hedgingPolicy(service, method string, opts []CallOption) (HedgingPolicy, bool) {
+	p, ok := c.hedgingPolicies[retryKey{service, method}]
+	if !ok {
+		p, ok = c.hedgingPolicies[retryKey{service, ""}]
+	}
+	if !ok || p.MaxAttempts < 2 {
+		return p, false
+	}
+
+	req := &rpc.Request{}
+	for _, fn := range opts {
+		fn(req, &callOptions{})
+	}
+	return p, !req.Streaming
+}
+
+type hedgeResult struct {
+	index int
+	call  Context
+	err   error
+}
+
+// final reports whether r concludes the call, either by succeeding or by
+// failing with a fatal status.
+func (r *hedgeResult) final(policy *HedgingPolicy) bool {
+	if r.err == nil {
+		code := status.Status(r.call.Response().Status)
+		return code == status.OK || !policy.nonFatal(code)
+	}
+	return !policy.nonFatal(status.Convert(r.err).Code)
+}
+
+func callHedged(ctx context.Context, policy HedgingPolicy, opts []CallOption, attempt attemptFunc) (Context, error) {
+	results := make(chan hedgeResult, policy.MaxAttempts)
+	cancels := make([]context.CancelFunc, 0, policy.MaxAttempts)
+	pending := 0
+
+	launch := func() {
+		index := len(cancels)
+		actx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		attemptOpts := opts
+		if index > 0 {
+			attemptOpts = append(slices.Clone(opts), withRetryAttempt(index))
+		}
+		pending++
+		go func() {
+			call, err := attempt(actx, attemptOpts)
+			results <- hedgeResult{index: index, call: call, err: err}
+		}()
+	}
+
+	timer := time.NewTimer(policy.Delay)
+	defer timer.Stop()
+	launch()
+
+	// cancelOthers releases the contexts of every attempt but the one
+	// concluding the call, resetting those still in flight. The concluding
+	// attempt's context is released once its call concludes, as it outlives
+	// this function.
+	cancelOthers := func(res hedgeResult) {
+		for i, cancel := range cancels {
+			if i != res.index {
+				cancel()
+			}
+		}
+		releaseWhenEnded(res.call, cancels[res.index])
+	}
+
+	var last hedgeResult
+	for pending > 0 {
+		var res hedgeResult
+		select {
+		case <-timer.C:
+			if len(cancels) < policy.MaxAttempts && ctx.Err() == nil {
+				launch()
+				timer.Reset(policy.Delay)
+			}
+			continue
+		case res = <-results:
+			pending--
+		}
+
+		if res.final(&policy) {
+			if last.call != nil {
+				discard(last.call)
+			}
+			cancelOthers(res)
+			go discardHedged(results, pending)
+			return res.call, res.err
+		}
+
+		if last.call != nil {
+			discard(last.call)
+		}
+		last = res
+		if len(cancels) < policy.MaxAttempts && ctx.Err() == nil {
+			launch()
+			timer.Reset(policy.Delay)
+		}
+	}
+
+	cancelOthers(last)
+	return last.call, last.err
+}
+
+// releaseWhenEnded calls cancel once call concludes, or right away in case
+// the attempt failed without a call.
+func releaseWhenEnded(call Context, cancel context.CancelFunc) {
+	c, ok := call.(*ctx)
+	if !ok || c.ended == nil {
+		cancel()
+		return
+	}
+	go func() {
+		select {
+		case <-c.ended:
+		case <-c.context.Done():
+		}
+		cancel()
+	}()
+}
+
+// discardHedged resets calls of attempts answered after the call was
+// concluded.
+func discardHedged(results <-chan hedgeResult, n int) {
+	for range n {
+		if r := <-results; r.err == nil {
+			discard(r.call)
+		}
+	}
+}
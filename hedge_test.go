@@ -0,0 +1,192 @@
+package arf
+
+import (
+	"context"
+	"github.com/arf-rpc/arf-go/rpc"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/arf-rpc/arf-go/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// resetRecorder records the error codes of RESET_STREAM frames sent by a
+// client.
+type resetRecorder struct {
+	mu    sync.Mutex
+	codes []wire.ErrorCode
+}
+
+func (r *resetRecorder) TapFrame(_ int, dir wire.Direction, fr *wire.Frame) {
+	if dir != wire.DirectionOut || fr.FrameKind != wire.FrameKindResetStream {
+		return
+	}
+	reset := &wire.ResetStreamFrame{}
+	if reset.FromFrame(fr) != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.codes = append(r.codes, reset.ErrorCode)
+}
+
+func (r *resetRecorder) resets() []wire.ErrorCode {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.codes)
+}
+
+// respondAfter answers name once d elapses, unless the call is canceled
+// first, in which case canceled is signaled.
+func respondAfter(ctx context.Context, c Context, d time.Duration, name string, canceled chan<- struct{}) error {
+	select {
+	case <-time.After(d):
+		return c.SendResponse(status.OK, []any{name}, false, nil)
+	case <-ctx.Done():
+		canceled <- struct{}{}
+		return ctx.Err()
+	}
+}
+
+// credentialsFunc adapts a function into PerCallCredentials.
+type credentialsFunc func(ctx context.Context, service, method string) (rpc.Metadata, error)
+
+func (f credentialsFunc) RequestMetadata(ctx context.Context, service, method string) (rpc.Metadata, error) {
+	return f(ctx, service, method)
+}
+
+func TestHedgingPolicy(t *testing.T) {
+	ctx := context.Background()
+	policy := HedgingPolicy{MaxAttempts: 2, Delay: 30 * time.Millisecond}
+
+	result := func(t *testing.T, c Client, opts ...CallOption) ([]any, error) {
+		t.Helper()
+		call, err := c.Call(ctx, e2eService, "Do", opts...)
+		require.NoError(t, err)
+		return call.Response().Result()
+	}
+
+	t.Run("slow attempts are hedged after Delay", func(t *testing.T) {
+		canceled := make(chan struct{}, 2)
+		tap := &resetRecorder{}
+		c, seen := makeAttemptServer(t, func(ctx context.Context, attempt int, c Context) error {
+			if attempt == 0 {
+				return respondAfter(ctx, c, 3*time.Second, "first", canceled)
+			}
+			return c.SendResponse(status.OK, []any{"second"}, false, nil)
+		}, WithHedgingPolicy(e2eService, "Do", policy), WithFrameTap(tap))
+
+		start := time.Now()
+		res, err := result(t, c)
+		require.NoError(t, err)
+		assert.Equal(t, []any{"second"}, res)
+		assert.GreaterOrEqual(t, time.Since(start), policy.Delay)
+		assert.Equal(t, []string{"", "1"}, seen())
+
+		// The losing attempt is reset, canceling its handler.
+		waitSignal(t, canceled)
+		assert.Eventually(t, func() bool {
+			return slices.Equal([]wire.ErrorCode{wire.ErrorCodeCancel}, tap.resets())
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("the first final result wins", func(t *testing.T) {
+		canceled := make(chan struct{}, 2)
+		c, seen := makeAttemptServer(t, func(ctx context.Context, attempt int, c Context) error {
+			if attempt == 0 {
+				return respondAfter(ctx, c, 80*time.Millisecond, "first", canceled)
+			}
+			return respondAfter(ctx, c, 3*time.Second, "second", canceled)
+		}, WithHedgingPolicy(e2eService, "Do", policy))
+
+		res, err := result(t, c)
+		require.NoError(t, err)
+		assert.Equal(t, []any{"first"}, res)
+		assert.Len(t, seen(), 2)
+		waitSignal(t, canceled)
+	})
+
+	t.Run("non-fatal codes send the next attempt right away", func(t *testing.T) {
+		patient := policy
+		patient.Delay = 3 * time.Second
+		c, seen := makeAttemptServer(t, func(_ context.Context, attempt int, c Context) error {
+			if attempt == 0 {
+				return status.Error(status.Unavailable, "try again")
+			}
+			return c.SendResponse(status.OK, []any{"second"}, false, nil)
+		}, WithHedgingPolicy(e2eService, "Do", patient))
+
+		start := time.Now()
+		res, err := result(t, c)
+		require.NoError(t, err)
+		assert.Equal(t, []any{"second"}, res)
+		assert.Less(t, time.Since(start), patient.Delay)
+		assert.Len(t, seen(), 2)
+	})
+
+	t.Run("the last non-fatal result is returned once attempts are exhausted", func(t *testing.T) {
+		c, seen := makeAttemptServer(t, func(context.Context, int, Context) error {
+			return status.Error(status.Unavailable, "try again")
+		}, WithHedgingPolicy(e2eService, "Do", policy))
+
+		_, err := result(t, c)
+		assert.Equal(t, status.Unavailable, status.Convert(err).Code)
+		assert.Len(t, seen(), 2)
+	})
+
+	t.Run("fatal codes conclude the call", func(t *testing.T) {
+		c, seen := makeAttemptServer(t, func(context.Context, int, Context) error {
+			return status.Error(status.InvalidArgument, "rejected")
+		}, WithHedgingPolicy(e2eService, "Do", HedgingPolicy{MaxAttempts: 2, Delay: time.Second}))
+
+		_, err := result(t, c)
+		assert.Equal(t, status.InvalidArgument, status.Convert(err).Code)
+		assert.Len(t, seen(), 1)
+	})
+
+	t.Run("the winning attempt's context is released once its call concludes", func(t *testing.T) {
+		var attempts []context.Context
+		creds := credentialsFunc(func(ctx context.Context, _, _ string) (rpc.Metadata, error) {
+			attempts = append(attempts, ctx)
+			return nil, nil
+		})
+		c, _ := makeAttemptServer(t, func(_ context.Context, _ int, c Context) error {
+			if err := c.SendResponse(status.OK, nil, true, nil); err != nil {
+				return err
+			}
+			if err := c.Send("a"); err != nil {
+				return err
+			}
+			return c.EndSend()
+		}, WithHedgingPolicy(e2eService, "Do", policy), WithCredentials(creds))
+
+		call, err := c.Call(ctx, e2eService, "Do")
+		require.NoError(t, err)
+		require.Len(t, attempts, 1)
+		assert.NoError(t, attempts[0].Err())
+
+		items, err := recvAll(MakeInStream[string](call))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a"}, items)
+		assert.Eventually(t, func() bool {
+			return attempts[0].Err() != nil
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("streaming requests are not hedged", func(t *testing.T) {
+		c, seen := makeAttemptServer(t, func(_ context.Context, attempt int, c Context) error {
+			time.Sleep(3 * policy.Delay)
+			return MakeOutStream[string](c).Send("a")
+		}, WithHedgingPolicy(e2eService, "Do", policy))
+
+		call, err := c.Call(ctx, e2eService, "Do", WithStream())
+		require.NoError(t, err)
+		items, err := recvAll(MakeInStream[string](call))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a"}, items)
+		assert.Len(t, seen(), 1)
+	})
+}
@@ -0,0 +1,111 @@
+package arf
+
+import (
+	"context"
+	"github.com/arf-rpc/arf-go/wire"
+	"sync/atomic"
+)
+
+// Invoker starts a call, as Client.Call does.
+type Invoker func(ctx context.Context, serviceIdentifier, serviceMethod string, opts ...CallOption) (Context, error)
+
+// ClientInterceptor intercepts calls made by a Client. It proceeds with the
+// call through next, possibly with additional options, and may wrap the
+// returned Context to observe the streams of the call. Retries and hedged
+// attempts happen within next.
+type ClientInterceptor func(ctx context.Context, serviceIdentifier, serviceMethod string, opts []CallOption, next Invoker) (Context, error)
+
+// WithInterceptors makes every call made by the client go through
+// interceptors. The first interceptor is the outermost one.
+func WithInterceptors(interceptors ...ClientInterceptor) ClientOption {
+	return func(c *client) {
+		c.interceptors = append(c.interceptors, interceptors...)
+	}
+}
+
+// intercept performs a call through the interceptors of the client, ending
+// with invoker.
+func (c *client) intercept(ctx context.Context, serviceIdentifier, serviceMethod string, opts []CallOption, invoker Invoker) (Context, error) {
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := c.interceptors[i], invoker
+		invoker = func(ctx context.Context, serviceIdentifier, serviceMethod string, opts ...CallOption) (Context, error) {
+			return interceptor(ctx, serviceIdentifier, serviceMethod, opts, next)
+		}
+	}
+	return invoker(ctx, serviceIdentifier, serviceMethod, opts...)
+}
+
+// Wrapper is implemented by Context values wrapping another Context, such
+// as those passed along by interceptors, allowing the implicit behaviors of
+// streams created through MakeOutStream to reach the wrapped Context.
+type Wrapper interface {
+	Unwrap() Context
+}
+
+// unwrapContext returns the Context at the bottom of the chain of wrappers
+// around c.
+func unwrapContext(c Context) Context {
+	for {
+		w, ok := c.(Wrapper)
+		if !ok {
+			return c
+		}
+		c = w.Unwrap()
+	}
+}
+
+// PeerOf returns the remote party of the call performed through c.
+func PeerOf(c Context) (*Peer, bool) {
+	cc, ok := unwrapContext(c).(*ctx)
+	if !ok {
+		return nil, false
+	}
+	return peerFromConnInfo(cc.str.ConnInfo()), true
+}
+
+// MessageBytes returns the amount of bytes of the messages sent and received
+// so far through c, including the request and the response, before
+// compression and excluding frame headers.
+func MessageBytes(c Context) (sent, received int64) {
+	cc, ok := unwrapContext(c).(*ctx)
+	if !ok {
+		return 0, 0
+	}
+	if str, ok := cc.str.(*countingStream); ok {
+		return str.sent.Load(), str.received.Load()
+	}
+	return 0, 0
+}
+
+// countingStream counts the bytes written to and read from a stream.
+type countingStream struct {
+	wire.Stream
+	sent     atomic.Int64
+	received atomic.Int64
+}
+
+func newCountingStream(str wire.Stream) *countingStream {
+	return &countingStream{Stream: str}
+}
+
+func (s *countingStream) Read(p []byte) (int, error) {
+	n, err := s.Stream.Read(p)
+	s.received.Add(int64(n))
+	return n, err
+}
+
+func (s *countingStream) Write(data []byte, endStream bool) error {
+	err := s.Stream.Write(data, endStream)
+	if err == nil {
+		s.sent.Add(int64(len(data)))
+	}
+	return err
+}
+
+func (s *countingStream) WriteContext(ctx context.Context, data []byte, endStream bool) error {
+	err := s.Stream.WriteContext(ctx, data, endStream)
+	if err == nil {
+		s.sent.Add(int64(len(data)))
+	}
+	return err
+}
@@ -0,0 +1,149 @@
+package arf
+
+import (
+	"context"
+	"github.com/arf-rpc/arf-go/rpc"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type countingContext struct {
+	Context
+	sent int
+}
+
+func (c *countingContext) Unwrap() Context { return c.Context }
+
+func (c *countingContext) Send(v any) error {
+	c.sent++
+	return c.Context.Send(v)
+}
+
+func TestClientInterceptors(t *testing.T) {
+	server := makeServer(t, map[string]ServiceExecutor{
+		"Meta": func(ctx context.Context, c Context) error {
+			md := c.Request().Metadata
+			return c.SendResponse(status.OK, []any{md.GetString("order"), md.GetString("user")}, false, nil)
+		},
+	})
+
+	var order []string
+	record := func(name string) ClientInterceptor {
+		return func(ctx context.Context, service, method string, opts []CallOption, next Invoker) (Context, error) {
+			order = append(order, name)
+			return next(ctx, service, method, append(opts, WithAdditionalMetadata(rpc.MetadataFromStringPairs("order", name)))...)
+		}
+	}
+
+	c, err := Dial(server.(*srv).listener.Addr().String(), WithInterceptors(record("outer"), record("inner")))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+
+	call, err := c.Call(context.Background(), e2eService, "Meta", WithMetadata(rpc.MetadataFromStringPairs("user", "jane")))
+	require.NoError(t, err)
+	params, err := call.Response().Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"outer", "inner"}, order)
+	assert.Equal(t, []any{"inner", "jane"}, params)
+}
+
+func TestServerInterceptorWrapsContext(t *testing.T) {
+	var wrapped *countingContext
+	server := makeServer(t, map[string]ServiceExecutor{
+		"Count": func(ctx context.Context, c Context) error {
+			out := MakeOutStream[string](c)
+			for _, v := range []string{"a", "b"} {
+				if err := out.Send(v); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+	server.RegisterInterceptor(func(ctx context.Context, req Context, next Interceptor) error {
+		wrapped = &countingContext{Context: req}
+		return next(ctx, wrapped, nil)
+	})
+
+	c, err := Dial(server.(*srv).listener.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+
+	call, err := c.Call(context.Background(), e2eService, "Count")
+	require.NoError(t, err)
+	items, err := recvAll(MakeInStream[string](call))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, items)
+	assert.Equal(t, 2, wrapped.sent)
+}
+
+// callRecorder records the notifications of a CallObserver.
+type callRecorder struct {
+	sent, received []int
+	ended          []error
+}
+
+func (r *callRecorder) ItemSent(n int)     { r.sent = append(r.sent, n) }
+func (r *callRecorder) ItemReceived(n int) { r.received = append(r.received, n) }
+
+func (r *callRecorder) CallEnded(_ *ObservedCall, err error) {
+	r.ended = append(r.ended, err)
+}
+
+func TestObserveClientCall(t *testing.T) {
+	server := makeE2EServer(t)
+	c, err := server.InProcessClient()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	ctx := context.Background()
+
+	t.Run("unary calls end once observed", func(t *testing.T) {
+		call, err := c.Call(ctx, e2eService, "Fail")
+		require.NoError(t, err)
+		rec := &callRecorder{}
+		observed := ObserveClientCall(ctx, call, rec)
+		_ = observed.Response()
+		require.Len(t, rec.ended, 1)
+		assert.Equal(t, status.InvalidArgument, status.Convert(rec.ended[0]).Code)
+	})
+
+	t.Run("streams end along with their response stream", func(t *testing.T) {
+		call, err := c.Call(ctx, e2eService, "Upper", WithStream())
+		require.NoError(t, err)
+		rec := &callRecorder{}
+		observed := ObserveClientCall(ctx, call, rec)
+		str := MakeInOutStream[string, string](observed)
+		for _, v := range []string{"a", "b"} {
+			require.NoError(t, str.Send(v))
+			_, err := str.Recv()
+			require.NoError(t, err)
+		}
+		require.NoError(t, str.Close())
+		_, err = str.Recv()
+		assert.True(t, IsStreamEnd(err))
+		_, err = str.Recv()
+		assert.Error(t, err)
+
+		assert.Equal(t, []int{1, 2}, rec.sent)
+		assert.Equal(t, []int{1, 2}, rec.received)
+		assert.Equal(t, []error{nil}, rec.ended)
+		sent, received := observed.Items()
+		assert.Equal(t, 2, sent)
+		assert.Equal(t, 2, received)
+	})
+
+	t.Run("failing to end the request stream ends the call", func(t *testing.T) {
+		call, err := c.Call(ctx, e2eService, "Upper", WithStream())
+		require.NoError(t, err)
+		rec := &callRecorder{}
+		observed := ObserveClientCall(ctx, call, rec)
+		require.NoError(t, observed.EndSend())
+		assert.Empty(t, rec.ended)
+
+		err = observed.EndSend()
+		require.Error(t, err)
+		assert.Equal(t, []error{err}, rec.ended)
+	})
+}
@@ -0,0 +1,175 @@
+// Package logging provides client and server interceptors emitting one
+// structured access log line per call.
+package logging
+
+import (
+	"context"
+	"github.com/arf-rpc/arf-go"
+	"github.com/arf-rpc/arf-go/rpc"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/go-stdlog/stdlog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RedactedValue replaces the values of redacted metadata keys.
+const RedactedValue = "[REDACTED]"
+
+// DefaultRedactedKeys are the metadata keys redacted unless Options.Redact
+// is set.
+var DefaultRedactedKeys = []string{"authorization", "cookie", "set-cookie", "x-api-key"}
+
+type Options struct {
+	// Metadata lists the request metadata keys logged along each call, as
+	// fields named "metadata.<key>". Keys absent from a request are omitted.
+	Metadata []string
+	// Redact lists metadata keys whose values are logged as RedactedValue.
+	// Keys are compared case-insensitively. Defaults to
+	// DefaultRedactedKeys.
+	Redact []string
+}
+
+// Log line fields, besides metadata.
+const (
+	RequestIDField     = "request_id"
+	ServiceField       = "service"
+	MethodField        = "method"
+	PeerField          = "peer"
+	StatusField        = "status"
+	DurationField      = "duration"
+	BytesSentField     = "bytes_sent"
+	BytesReceivedField = "bytes_received"
+	ItemsSentField     = "items_sent"
+	ItemsReceivedField = "items_received"
+)
+
+type logger struct {
+	log    stdlog.Logger
+	keys   []string
+	redact []string
+}
+
+func newLogger(log stdlog.Logger, opts Options) *logger {
+	if opts.Redact == nil {
+		opts.Redact = DefaultRedactedKeys
+	}
+	redact := make([]string, len(opts.Redact))
+	for i, k := range opts.Redact {
+		redact[i] = strings.ToLower(k)
+	}
+	return &logger{log: log, keys: opts.Metadata, redact: redact}
+}
+
+// ServerInterceptor returns an interceptor logging calls handled by a
+// server to log once their handler returns. Register it first so its
+// duration and status cover other interceptors, including those rejecting
+// calls.
+func ServerInterceptor(log stdlog.Logger, opts Options) arf.Interceptor {
+	l := newLogger(log, opts)
+	return func(ctx context.Context, req arf.Context, next arf.Interceptor) error {
+		begin := time.Now()
+		call := arf.ObserveServerCall(req, callLog{l: l, begin: begin})
+		err := next(ctx, call, nil)
+
+		code := status.OK
+		if err != nil {
+			code = status.Convert(err).Code
+		} else if sent, ok := call.SentStatus(); ok {
+			code = sent
+		}
+
+		fields := make([]any, 0, 22)
+		if id, ok := arf.RequestIDFromContext(ctx); ok {
+			fields = append(fields, RequestIDField, id)
+		}
+		if p, ok := arf.PeerFromContext(ctx); ok && p.Addr != nil {
+			fields = append(fields, PeerField, p.Addr.String())
+		}
+		l.emit("Handled call", call, code, time.Since(begin), fields)
+		return err
+	}
+}
+
+// ClientInterceptor returns an interceptor logging calls made by a client
+// to log once they conclude: when their response is received, when their
+// response stream ends, either normally or by an error, or when their
+// context ends. Calls failing to start are logged right away.
+func ClientInterceptor(log stdlog.Logger, opts Options) arf.ClientInterceptor {
+	l := newLogger(log, opts)
+	return func(ctx context.Context, service, method string, opts []arf.CallOption, next arf.Invoker) (arf.Context, error) {
+		begin := time.Now()
+		call, err := next(ctx, service, method, opts...)
+		if err != nil || call == nil {
+			code := status.OK
+			if err != nil {
+				code = status.Convert(err).Code
+			}
+			l.log.Info("Finished call",
+				ServiceField, service,
+				MethodField, method,
+				StatusField, code.Error(),
+				DurationField, time.Since(begin))
+			return call, err
+		}
+
+		return arf.ObserveClientCall(ctx, call, callLog{l: l, begin: begin}), nil
+	}
+}
+
+// emit writes the line describing call, prefixed by fields.
+func (l *logger) emit(msg string, call *arf.ObservedCall, code status.Status, duration time.Duration, fields []any) {
+	req := call.Request()
+	sent, received := arf.MessageBytes(call)
+	itemsSent, itemsReceived := call.Items()
+
+	fields = append(fields,
+		ServiceField, req.Service,
+		MethodField, req.Method,
+		StatusField, code.Error(),
+		DurationField, duration,
+		BytesSentField, sent,
+		BytesReceivedField, received,
+		ItemsSentField, itemsSent,
+		ItemsReceivedField, itemsReceived,
+	)
+	l.log.Info(msg, append(fields, l.metadata(req.Metadata)...)...)
+}
+
+// metadata returns the fields holding the selected keys of md.
+func (l *logger) metadata(md rpc.Metadata) []any {
+	var fields []any
+	for _, key := range l.keys {
+		value, ok := md.LookupString(key)
+		if !ok {
+			continue
+		}
+		if slices.Contains(l.redact, strings.ToLower(key)) {
+			value = RedactedValue
+		}
+		fields = append(fields, "metadata."+key, value)
+	}
+	return fields
+}
+
+// callLog logs a client call once it concludes.
+type callLog struct {
+	l     *logger
+	begin time.Time
+}
+
+func (callLog) ItemSent(int)     {}
+func (callLog) ItemReceived(int) {}
+
+func (c callLog) CallEnded(call *arf.ObservedCall, err error) {
+	code := status.OK
+	if err != nil {
+		code = status.Convert(err).Code
+	}
+
+	var fields []any
+	if p, ok := arf.PeerOf(call); ok && p.Addr != nil {
+		fields = append(fields, PeerField, p.Addr.String())
+	}
+	c.l.emit("Finished call", call, code, time.Since(c.begin), fields)
+}
@@ -0,0 +1,204 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/arf-rpc/arf-go"
+	"github.com/arf-rpc/arf-go/rpc"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/go-stdlog/stdlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testService = "org.example.test/Logging"
+
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// take waits for a line to be logged, and returns its fields.
+func (b *logBuffer) take(t *testing.T) map[string]any {
+	t.Helper()
+
+	var line string
+	require.Eventually(t, func() bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		var err error
+		line, err = b.buf.ReadString('\n')
+		if err != nil {
+			b.buf.WriteString(line)
+			return false
+		}
+		return true
+	}, time.Second, 5*time.Millisecond)
+
+	var entry struct {
+		Msg   string         `json:"msg"`
+		Extra map[string]any `json:"extra"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(line), &entry))
+	entry.Extra["msg"] = entry.Msg
+	return entry.Extra
+}
+
+func TestInterceptors(t *testing.T) {
+	serverLog, clientLog := &logBuffer{}, &logBuffer{}
+	opts := Options{Metadata: []string{"tenant", "authorization", "missing"}}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server, err := arf.NewServer(l, arf.ServerOptions{
+		IDGenerator: func() (string, error) { return "req-1", nil },
+	})
+	require.NoError(t, err)
+	go func() { _ = server.Serve() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	server.RegisterInterceptor(ServerInterceptor(stdlog.NewStdJSON(serverLog), opts))
+	server.MustRegisterService(arf.ServiceAdapter{
+		ServiceID: testService,
+		Methods: map[string]arf.ServiceExecutor{
+			"Count": func(ctx context.Context, c arf.Context) error {
+				out := arf.MakeOutStream[string](c)
+				for _, v := range []string{"a", "b"} {
+					if err := out.Send(v); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			"Fail": func(ctx context.Context, c arf.Context) error {
+				return status.Error(status.NotFound, "no such thing")
+			},
+			"Sum": func(ctx context.Context, c arf.Context) error {
+				in := arf.MakeInStream[uint64](c)
+				var sum uint64
+				for {
+					v, err := in.Recv()
+					if err != nil {
+						break
+					}
+					sum += v
+				}
+				return c.SendResponse(status.OK, []any{sum}, false, nil)
+			},
+		},
+	})
+
+	c, err := arf.Dial(l.Addr().String(), arf.WithInterceptors(ClientInterceptor(stdlog.NewStdJSON(clientLog), opts)))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+
+	md := rpc.MetadataFromStringPairs("tenant", "acme", "authorization", "Bearer secret")
+
+	t.Run("server streaming", func(t *testing.T) {
+		call, err := c.Call(context.Background(), testService, "Count", arf.WithMetadata(md))
+		require.NoError(t, err)
+		in := arf.MakeInStream[string](call)
+		for {
+			if _, err = in.Recv(); err != nil {
+				break
+			}
+		}
+
+		line := clientLog.take(t)
+		assert.Equal(t, "Finished call", line["msg"])
+		assert.Equal(t, testService, line[ServiceField])
+		assert.Equal(t, "Count", line[MethodField])
+		assert.Equal(t, "OK", line[StatusField])
+		assert.Equal(t, l.Addr().String(), line[PeerField])
+		assert.EqualValues(t, 2, line[ItemsReceivedField])
+		assert.EqualValues(t, 0, line[ItemsSentField])
+		assert.Greater(t, line[BytesSentField], float64(0))
+		assert.Greater(t, line[BytesReceivedField], float64(0))
+		assert.Equal(t, "acme", line["metadata.tenant"])
+		assert.Equal(t, RedactedValue, line["metadata.authorization"])
+		assert.NotContains(t, line, "metadata.missing")
+		assert.NotContains(t, line, RequestIDField)
+
+		line = serverLog.take(t)
+		assert.Equal(t, "Handled call", line["msg"])
+		assert.Equal(t, "req-1", line[RequestIDField])
+		assert.Equal(t, "OK", line[StatusField])
+		assert.True(t, strings.HasPrefix(line[PeerField].(string), "127.0.0.1:"))
+		assert.EqualValues(t, 2, line[ItemsSentField])
+		assert.Greater(t, line[BytesReceivedField], float64(0))
+		assert.Equal(t, RedactedValue, line["metadata.authorization"])
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		_, err := c.Call(context.Background(), testService, "Fail")
+		require.NoError(t, err)
+
+		assert.Equal(t, "Not Found", clientLog.take(t)[StatusField])
+		line := serverLog.take(t)
+		assert.Equal(t, "Not Found", line[StatusField])
+		assert.NotContains(t, line, "metadata.tenant")
+	})
+
+	t.Run("client streaming", func(t *testing.T) {
+		call, err := c.Call(context.Background(), testService, "Sum", arf.WithStream())
+		require.NoError(t, err)
+		out := arf.MakeOutStream[uint64](call)
+		for i := uint64(1); i <= 3; i++ {
+			require.NoError(t, out.Send(i))
+		}
+		require.NoError(t, out.Close())
+		params, err := call.Response().Result()
+		require.NoError(t, err)
+		assert.Equal(t, []any{uint64(6)}, params)
+
+		line := clientLog.take(t)
+		assert.Equal(t, "OK", line[StatusField])
+		assert.EqualValues(t, 3, line[ItemsSentField])
+
+		line = serverLog.take(t)
+		assert.Equal(t, "OK", line[StatusField])
+		assert.EqualValues(t, 3, line[ItemsReceivedField])
+	})
+
+	t.Run("unimplemented", func(t *testing.T) {
+		_, err := c.Call(context.Background(), "org.example.test/Missing", "Get")
+		require.NoError(t, err)
+		assert.Equal(t, "Unimplemented", clientLog.take(t)[StatusField])
+	})
+
+	t.Run("calls never read are logged once their context ends", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		call, err := c.Call(ctx, testService, "Sum", arf.WithStream())
+		require.NoError(t, err)
+		require.NoError(t, arf.MakeOutStream[uint64](call).Send(1))
+		cancel()
+
+		line := clientLog.take(t)
+		assert.Equal(t, status.Cancelled.Error(), line[StatusField])
+		assert.EqualValues(t, 1, line[ItemsSentField])
+	})
+
+	t.Run("calls returned without a Context are logged", func(t *testing.T) {
+		log := &logBuffer{}
+		intercept := ClientInterceptor(stdlog.NewStdJSON(log), opts)
+		call, err := intercept(context.Background(), testService, "Sum", nil,
+			func(context.Context, string, string, ...arf.CallOption) (arf.Context, error) {
+				return nil, nil
+			})
+		assert.Nil(t, call)
+		assert.NoError(t, err)
+		assert.Equal(t, "OK", log.take(t)[StatusField])
+	})
+}
@@ -0,0 +1,298 @@
+// Package metrics provides a StatsHandler collecting metrics about
+// connections, frames and calls in process, and exposing them in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"bytes"
+	"github.com/arf-rpc/arf-go"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/arf-rpc/arf-go/wire"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the exposition served by Collector.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds, in seconds, of the buckets of the
+// call duration histogram used unless Options.Buckets is set.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type Options struct {
+	// Namespace prefixes the name of every metric. Defaults to "arf".
+	Namespace string
+	// Buckets are the upper bounds, in seconds, of the buckets of the call
+	// duration histogram, in increasing order.
+	Buckets []float64
+}
+
+type rpcKey struct {
+	side    string
+	service string
+	method  string
+}
+
+type handledKey struct {
+	rpcKey
+	code status.Status
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Collector is an arf.StatsHandler and an http.Handler serving the metrics
+// it collected. Use the same Collector for a server and its clients to
+// expose them together; calls are labeled with the side handling them.
+type Collector struct {
+	namespace string
+	buckets   []float64
+
+	mu               sync.Mutex
+	connsOpened      uint64
+	connsClosed      uint64
+	framesIn         map[wire.FrameKind]uint64
+	framesOut        map[wire.FrameKind]uint64
+	bytesIn          uint64
+	bytesOut         uint64
+	payloadIn        uint64
+	payloadOut       uint64
+	started          map[rpcKey]uint64
+	handled          map[handledKey]uint64
+	messagesSent     map[rpcKey]uint64
+	messagesReceived map[rpcKey]uint64
+	durations        map[rpcKey]*histogram
+}
+
+var _ arf.StatsHandler = (*Collector)(nil)
+
+func New(opts Options) *Collector {
+	if opts.Namespace == "" {
+		opts.Namespace = "arf"
+	}
+	if opts.Buckets == nil {
+		opts.Buckets = DefaultBuckets
+	}
+	return &Collector{
+		namespace:        opts.Namespace,
+		buckets:          opts.Buckets,
+		framesIn:         make(map[wire.FrameKind]uint64),
+		framesOut:        make(map[wire.FrameKind]uint64),
+		started:          make(map[rpcKey]uint64),
+		handled:          make(map[handledKey]uint64),
+		messagesSent:     make(map[rpcKey]uint64),
+		messagesReceived: make(map[rpcKey]uint64),
+		durations:        make(map[rpcKey]*histogram),
+	}
+}
+
+func (c *Collector) ConnBegin(wire.ConnInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connsOpened++
+}
+
+func (c *Collector) ConnEnd(wire.ConnInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connsClosed++
+}
+
+func (c *Collector) FrameIn(info wire.FrameStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.framesIn[info.Kind]++
+	c.bytesIn += uint64(info.Size)
+	c.payloadIn += uint64(info.PayloadSize)
+}
+
+func (c *Collector) FrameOut(info wire.FrameStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.framesOut[info.Kind]++
+	c.bytesOut += uint64(info.Size)
+	c.payloadOut += uint64(info.PayloadSize)
+}
+
+func keyOf(info arf.RPCInfo) rpcKey {
+	side := "server"
+	if info.Client {
+		side = "client"
+	}
+	return rpcKey{side: side, service: info.Service, method: info.Method}
+}
+
+func (c *Collector) RPCBegin(info arf.RPCInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.started[keyOf(info)]++
+}
+
+func (c *Collector) RPCEnd(stats arf.RPCStats) {
+	key := keyOf(stats.RPCInfo)
+	seconds := stats.Duration.Seconds()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handled[handledKey{rpcKey: key, code: stats.Code}]++
+	c.messagesSent[key] += uint64(stats.MessagesSent)
+	c.messagesReceived[key] += uint64(stats.MessagesReceived)
+
+	h, ok := c.durations[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(c.buckets))}
+		c.durations[key] = h
+	}
+	for i, bound := range c.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// ServeHTTP writes the collected metrics in the Prometheus text exposition
+// format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	var buf bytes.Buffer
+	c.write(&buf)
+	w.Header().Set("Content-Type", ContentType)
+	_, _ = w.Write(buf.Bytes())
+}
+
+func (c *Collector) write(buf *bytes.Buffer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := &encoder{buf: buf, namespace: c.namespace}
+	e.family("connections_opened_total", "counter", "Connections established.")
+	e.sample("connections_opened_total", nil, float64(c.connsOpened))
+	e.family("connections_closed_total", "counter", "Connections terminated.")
+	e.sample("connections_closed_total", nil, float64(c.connsClosed))
+	e.family("connections_active", "gauge", "Connections currently established.")
+	e.sample("connections_active", nil, float64(c.connsOpened-c.connsClosed))
+
+	e.frames("frames_received_total", "Frames received, by kind.", c.framesIn)
+	e.frames("frames_sent_total", "Frames sent, by kind.", c.framesOut)
+
+	e.family("received_bytes_total", "counter", "Bytes received, including frame headers.")
+	e.sample("received_bytes_total", nil, float64(c.bytesIn))
+	e.family("sent_bytes_total", "counter", "Bytes sent, including frame headers.")
+	e.sample("sent_bytes_total", nil, float64(c.bytesOut))
+	e.family("received_payload_bytes_total", "counter", "Frame payload bytes received, after decompression.")
+	e.sample("received_payload_bytes_total", nil, float64(c.payloadIn))
+	e.family("sent_payload_bytes_total", "counter", "Frame payload bytes sent, before compression.")
+	e.sample("sent_payload_bytes_total", nil, float64(c.payloadOut))
+
+	e.rpcs("rpc_started_total", "Calls started.", c.started)
+
+	e.family("rpc_handled_total", "counter", "Calls concluded, by status code.")
+	handled := make([]handledKey, 0, len(c.handled))
+	for k := range c.handled {
+		handled = append(handled, k)
+	}
+	sort.Slice(handled, func(i, j int) bool {
+		if handled[i].rpcKey != handled[j].rpcKey {
+			return lessKey(handled[i].rpcKey, handled[j].rpcKey)
+		}
+		return handled[i].code < handled[j].code
+	})
+	for _, k := range handled {
+		e.sample("rpc_handled_total", append(rpcLabels(k.rpcKey), "code", k.code.Error()), float64(c.handled[k]))
+	}
+
+	e.rpcs("rpc_messages_sent_total", "Stream items sent.", c.messagesSent)
+	e.rpcs("rpc_messages_received_total", "Stream items received.", c.messagesReceived)
+
+	e.family("rpc_duration_seconds", "histogram", "Duration of concluded calls.")
+	for _, k := range sortedKeys(c.durations) {
+		h := c.durations[k]
+		labels := rpcLabels(k)
+		for i, bound := range c.buckets {
+			le := strconv.FormatFloat(bound, 'f', -1, 64)
+			e.sample("rpc_duration_seconds_bucket", append(labels, "le", le), float64(h.counts[i]))
+		}
+		e.sample("rpc_duration_seconds_bucket", append(labels, "le", "+Inf"), float64(h.count))
+		e.sample("rpc_duration_seconds_sum", labels, h.sum)
+		e.sample("rpc_duration_seconds_count", labels, float64(h.count))
+	}
+}
+
+func lessKey(a, b rpcKey) bool {
+	if a.side != b.side {
+		return a.side < b.side
+	}
+	if a.service != b.service {
+		return a.service < b.service
+	}
+	return a.method < b.method
+}
+
+func sortedKeys[V any](m map[rpcKey]V) []rpcKey {
+	keys := make([]rpcKey, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return lessKey(keys[i], keys[j]) })
+	return keys
+}
+
+func rpcLabels(k rpcKey) []string {
+	return []string{"side", k.side, "service", k.service, "method", k.method}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type encoder struct {
+	buf       *bytes.Buffer
+	namespace string
+}
+
+func (e *encoder) family(name, kind, help string) {
+	name = e.namespace + "_" + name
+	e.buf.WriteString("# HELP " + name + " " + help + "\n")
+	e.buf.WriteString("# TYPE " + name + " " + kind + "\n")
+}
+
+// sample writes a sample of name. labels holds alternating names and
+// values.
+func (e *encoder) sample(name string, labels []string, value float64) {
+	e.buf.WriteString(e.namespace + "_" + name)
+	if len(labels) > 0 {
+		e.buf.WriteByte('{')
+		for i := 0; i < len(labels); i += 2 {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			e.buf.WriteString(labels[i] + `="` + labelEscaper.Replace(labels[i+1]) + `"`)
+		}
+		e.buf.WriteByte('}')
+	}
+	e.buf.WriteString(" " + strconv.FormatFloat(value, 'f', -1, 64) + "\n")
+}
+
+func (e *encoder) frames(name, help string, counts map[wire.FrameKind]uint64) {
+	e.family(name, "counter", help)
+	kinds := make([]wire.FrameKind, 0, len(counts))
+	for k := range counts {
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	for _, k := range kinds {
+		e.sample(name, []string{"kind", k.String()}, float64(counts[k]))
+	}
+}
+
+func (e *encoder) rpcs(name, help string, counts map[rpcKey]uint64) {
+	e.family(name, "counter", help)
+	for _, k := range sortedKeys(counts) {
+		e.sample(name, rpcLabels(k), float64(counts[k]))
+	}
+}
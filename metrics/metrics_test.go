@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"context"
+	"github.com/arf-rpc/arf-go"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/arf-rpc/arf-go/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func scrape(t *testing.T, c *Collector) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestCollector(t *testing.T) {
+	c := New(Options{Namespace: "test", Buckets: []float64{0.1, 1}})
+	c.ConnBegin(wire.ConnInfo{})
+	c.ConnBegin(wire.ConnInfo{})
+	c.ConnEnd(wire.ConnInfo{}, nil)
+	c.FrameIn(wire.FrameStats{Kind: wire.FrameKindData, Size: 20, PayloadSize: 30, CompressedSize: 10})
+	c.FrameOut(wire.FrameStats{Kind: wire.FrameKindPing, Size: 18, PayloadSize: 8, CompressedSize: 8})
+
+	info := arf.RPCInfo{Service: `svc"1`, Method: "Get"}
+	c.RPCBegin(info)
+	c.RPCEnd(arf.RPCStats{RPCInfo: info, Code: status.NotFound, Duration: 500 * time.Millisecond, MessagesSent: 2})
+
+	assert.Equal(t, `# HELP test_connections_opened_total Connections established.
+# TYPE test_connections_opened_total counter
+test_connections_opened_total 2
+# HELP test_connections_closed_total Connections terminated.
+# TYPE test_connections_closed_total counter
+test_connections_closed_total 1
+# HELP test_connections_active Connections currently established.
+# TYPE test_connections_active gauge
+test_connections_active 1
+# HELP test_frames_received_total Frames received, by kind.
+# TYPE test_frames_received_total counter
+test_frames_received_total{kind="DATA"} 1
+# HELP test_frames_sent_total Frames sent, by kind.
+# TYPE test_frames_sent_total counter
+test_frames_sent_total{kind="PING"} 1
+# HELP test_received_bytes_total Bytes received, including frame headers.
+# TYPE test_received_bytes_total counter
+test_received_bytes_total 20
+# HELP test_sent_bytes_total Bytes sent, including frame headers.
+# TYPE test_sent_bytes_total counter
+test_sent_bytes_total 18
+# HELP test_received_payload_bytes_total Frame payload bytes received, after decompression.
+# TYPE test_received_payload_bytes_total counter
+test_received_payload_bytes_total 30
+# HELP test_sent_payload_bytes_total Frame payload bytes sent, before compression.
+# TYPE test_sent_payload_bytes_total counter
+test_sent_payload_bytes_total 8
+# HELP test_rpc_started_total Calls started.
+# TYPE test_rpc_started_total counter
+test_rpc_started_total{side="server",service="svc\"1",method="Get"} 1
+# HELP test_rpc_handled_total Calls concluded, by status code.
+# TYPE test_rpc_handled_total counter
+test_rpc_handled_total{side="server",service="svc\"1",method="Get",code="Not Found"} 1
+# HELP test_rpc_messages_sent_total Stream items sent.
+# TYPE test_rpc_messages_sent_total counter
+test_rpc_messages_sent_total{side="server",service="svc\"1",method="Get"} 2
+# HELP test_rpc_messages_received_total Stream items received.
+# TYPE test_rpc_messages_received_total counter
+test_rpc_messages_received_total{side="server",service="svc\"1",method="Get"} 0
+# HELP test_rpc_duration_seconds Duration of concluded calls.
+# TYPE test_rpc_duration_seconds histogram
+test_rpc_duration_seconds_bucket{side="server",service="svc\"1",method="Get",le="0.1"} 0
+test_rpc_duration_seconds_bucket{side="server",service="svc\"1",method="Get",le="1"} 1
+test_rpc_duration_seconds_bucket{side="server",service="svc\"1",method="Get",le="+Inf"} 1
+test_rpc_duration_seconds_sum{side="server",service="svc\"1",method="Get"} 0.5
+test_rpc_duration_seconds_count{side="server",service="svc\"1",method="Get"} 1
+`, scrape(t, c))
+}
+
+func TestCollectorIntegration(t *testing.T) {
+	serverStats := New(Options{})
+	clientStats := New(Options{})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server, err := arf.NewServer(l, arf.ServerOptions{StatsHandler: serverStats})
+	require.NoError(t, err)
+	go func() { _ = server.Serve() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+	server.MustRegisterService(arf.ServiceAdapter{
+		ServiceID: "org.example.test/Metrics",
+		Methods: map[string]arf.ServiceExecutor{
+			"Count": func(ctx context.Context, c arf.Context) error {
+				out := arf.MakeOutStream[string](c)
+				for _, v := range []string{"a", "b"} {
+					if err := out.Send(v); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			"Fail": func(ctx context.Context, c arf.Context) error {
+				return status.Error(status.Aborted, "nope")
+			},
+		},
+	})
+
+	client, err := arf.Dial(l.Addr().String(), arf.WithStatsHandler(clientStats))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	call, err := client.Call(context.Background(), "org.example.test/Metrics", "Count")
+	require.NoError(t, err)
+	for {
+		if _, err = call.Recv(); err != nil {
+			break
+		}
+	}
+	_, err = client.Call(context.Background(), "org.example.test/Metrics", "Fail")
+	require.NoError(t, err)
+
+	labels := func(side, method string) string {
+		return `{side="` + side + `",service="org.example.test/Metrics",method="` + method + `"`
+	}
+	assert.Eventually(t, func() bool {
+		out := scrape(t, serverStats)
+		return contains(out,
+			"arf_connections_active 1\n",
+			`arf_rpc_handled_total`+labels("server", "Count")+`,code="OK"} 1`,
+			`arf_rpc_messages_sent_total`+labels("server", "Count")+`} 2`,
+			`arf_rpc_handled_total`+labels("server", "Fail")+`,code="Aborted"} 1`,
+		)
+	}, time.Second, 10*time.Millisecond)
+
+	out := scrape(t, clientStats)
+	assert.True(t, contains(out,
+		"arf_connections_opened_total 1\n",
+		`arf_frames_sent_total{kind="HELLO"} 1`,
+		`arf_rpc_handled_total`+labels("client", "Count")+`,code="OK"} 1`,
+		`arf_rpc_messages_received_total`+labels("client", "Count")+`} 2`,
+		`arf_rpc_handled_total`+labels("client", "Fail")+`,code="Aborted"} 1`,
+	), out)
+}
+
+func contains(s string, substrs ...string) bool {
+	for _, sub := range substrs {
+		if !strings.Contains(s, sub) {
+			return false
+		}
+	}
+	return true
+}
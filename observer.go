@@ -0,0 +1,190 @@
+package arf
+
+import (
+	"context"
+	"github.com/arf-rpc/arf-go/rpc"
+	"github.com/arf-rpc/arf-go/status"
+	"sync"
+)
+
+// CallObserver is notified of the progress of a call wrapped into an
+// ObservedCall.
+type CallObserver interface {
+	// ItemSent and ItemReceived are called for every stream item sent or
+	// received, with the amount of items sent or received so far,
+	// including that one.
+	ItemSent(n int)
+	ItemReceived(n int)
+	// CallEnded is called once a client call concludes, with the error it
+	// concluded with, if any. Calls conclude once their response is
+	// received, once their response stream ends, either normally or by an
+	// error, once closing their request stream fails, or once their context
+	// ends. It is never called for calls handled by a server.
+	CallEnded(call *ObservedCall, err error)
+}
+
+// ObservedCall wraps a Context, notifying a CallObserver of the stream items
+// it exchanges and of its conclusion. Interceptors use it to follow the
+// calls they intercept; see ObserveClientCall and ObserveServerCall.
+type ObservedCall struct {
+	Context
+	observer CallObserver
+	client   bool
+
+	mu         sync.Mutex
+	sent       int
+	received   int
+	responded  bool
+	sentStatus status.Status
+	ended      bool
+	// stop releases the context watched for client calls.
+	stop func() bool
+}
+
+// ObserveClientCall wraps call, as returned to a ClientInterceptor along
+// with ctx, the context of the call. Calls lacking a request stream already
+// received their response, so observer is notified right away in case it
+// concluded the call. Calls whose response is never read conclude once ctx
+// ends.
+func ObserveClientCall(ctx context.Context, call Context, observer CallObserver) *ObservedCall {
+	c := &ObservedCall{Context: call, observer: observer, client: true}
+	c.mu.Lock()
+	c.stop = context.AfterFunc(ctx, func() {
+		c.end(status.Convert(ctx.Err()))
+	})
+	c.mu.Unlock()
+	if !call.Request().Streaming {
+		c.observeResponse(call.Response())
+	}
+	return c
+}
+
+// ObserveServerCall wraps call, as passed to an Interceptor, to be handed to
+// the next interceptor.
+func ObserveServerCall(call Context, observer CallObserver) *ObservedCall {
+	return &ObservedCall{Context: call, observer: observer}
+}
+
+func (c *ObservedCall) Unwrap() Context {
+	return c.Context
+}
+
+// Items returns the amount of stream items sent and received so far.
+func (c *ObservedCall) Items() (sent, received int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sent, c.received
+}
+
+// SentStatus returns the status of the response sent by a handler, if it
+// sent one.
+func (c *ObservedCall) SentStatus() (status.Status, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sentStatus, c.responded
+}
+
+func (c *ObservedCall) Recv() (any, error) {
+	v, err := c.Context.Recv()
+	c.observeRecv(err)
+	return v, err
+}
+
+func (c *ObservedCall) RecvContext(ctx context.Context) (any, error) {
+	v, err := c.Context.RecvContext(ctx)
+	c.observeRecv(err)
+	return v, err
+}
+
+func (c *ObservedCall) Send(v any) error {
+	err := c.Context.Send(v)
+	c.observeSend(err)
+	return err
+}
+
+func (c *ObservedCall) SendContext(ctx context.Context, v any) error {
+	err := c.Context.SendContext(ctx, v)
+	c.observeSend(err)
+	return err
+}
+
+func (c *ObservedCall) EndSend() error {
+	err := c.Context.EndSend()
+	if err != nil && c.client {
+		c.end(err)
+	}
+	return err
+}
+
+func (c *ObservedCall) Response() *rpc.Response {
+	resp := c.Context.Response()
+	if c.client {
+		c.observeResponse(resp)
+	}
+	return resp
+}
+
+func (c *ObservedCall) SendResponse(code status.Status, params []any, streaming bool, metadata rpc.Metadata) error {
+	err := c.Context.SendResponse(code, params, streaming, metadata)
+	if err == nil {
+		c.mu.Lock()
+		c.responded = true
+		c.sentStatus = code
+		c.mu.Unlock()
+	}
+	return err
+}
+
+func (c *ObservedCall) observeSend(err error) {
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	c.sent++
+	n := c.sent
+	c.mu.Unlock()
+	c.observer.ItemSent(n)
+}
+
+func (c *ObservedCall) observeRecv(err error) {
+	switch {
+	case err == nil:
+		c.mu.Lock()
+		c.received++
+		n := c.received
+		c.mu.Unlock()
+		c.observer.ItemReceived(n)
+	case !c.client:
+	case IsStreamEnd(err):
+		c.end(nil)
+	default:
+		c.end(err)
+	}
+}
+
+// observeResponse concludes a client call once its response is received,
+// unless it is followed by a response stream.
+func (c *ObservedCall) observeResponse(resp *rpc.Response) {
+	if resp == nil || resp.Streaming {
+		return
+	}
+	_, err := resp.Result()
+	c.end(err)
+}
+
+// end notifies the observer of the conclusion of a client call, unless it
+// already was.
+func (c *ObservedCall) end(err error) {
+	c.mu.Lock()
+	ended := c.ended
+	c.ended = true
+	stop := c.stop
+	c.mu.Unlock()
+	if ended {
+		return
+	}
+	if stop != nil {
+		stop()
+	}
+	c.observer.CallEnded(c, err)
+}
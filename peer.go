@@ -0,0 +1,48 @@
+package arf
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/arf-rpc/arf-go/wire"
+	"net"
+)
+
+// Peer describes the remote party of a call, as seen by a service handler.
+type Peer struct {
+	// Addr and LocalAddr are the remote and local addresses of the
+	// connection. Both are nil for connections not backed by a net.Conn.
+	Addr      net.Addr
+	LocalAddr net.Addr
+	// ConnID identifies the connection among those accepted by the server.
+	ConnID int
+	// Compression is the compression method negotiated for the connection.
+	Compression wire.CompressionMethod
+	// TLS is the state of the TLS connection, including the certificates
+	// presented by the client, or nil in case the connection is not
+	// encrypted.
+	TLS *tls.ConnectionState
+}
+
+type peerKey struct{}
+
+func peerFromConnInfo(info wire.ConnInfo) *Peer {
+	return &Peer{
+		Addr:        info.RemoteAddr,
+		LocalAddr:   info.LocalAddr,
+		ConnID:      info.ID,
+		Compression: info.Compression,
+		TLS:         info.TLS,
+	}
+}
+
+// NewContextWithPeer returns a copy of ctx carrying p.
+func NewContextWithPeer(ctx context.Context, p *Peer) context.Context {
+	return context.WithValue(ctx, peerKey{}, p)
+}
+
+// PeerFromContext returns the Peer of the call ctx was provided to by the
+// server.
+func PeerFromContext(ctx context.Context) (*Peer, bool) {
+	p, ok := ctx.Value(peerKey{}).(*Peer)
+	return p, ok
+}
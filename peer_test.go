@@ -0,0 +1,70 @@
+package arf
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/arf-rpc/arf-go/internal/testpki"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPeerFromContext(t *testing.T) {
+	pki, err := testpki.New()
+	require.NoError(t, err)
+	serverCert, err := pki.Issue("server", "127.0.0.1")
+	require.NoError(t, err)
+	clientCert, err := pki.Issue("alice")
+	require.NoError(t, err)
+
+	l := listen(t)
+	server, err := NewServer(l, ServerOptions{TLSConfig: &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pki.Pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}})
+	require.NoError(t, err)
+	go func() { _ = server.Serve() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	server.MustRegisterService(ServiceAdapter{
+		ServiceID: nameService,
+		Methods: map[string]ServiceExecutor{
+			"Name": func(ctx context.Context, c Context) error {
+				p, ok := PeerFromContext(ctx)
+				if !ok || p.TLS == nil || len(p.TLS.PeerCertificates) == 0 {
+					return status.Error(status.InternalError, "expected a TLS peer")
+				}
+				name := p.TLS.PeerCertificates[0].Subject.CommonName
+				return c.SendResponse(status.OK, []any{name}, false, nil)
+			},
+		},
+	})
+
+	t.Run("handlers observe client certificates", func(t *testing.T) {
+		c, err := Dial(l.Addr().String(), WithTLSConfig(&tls.Config{
+			RootCAs:      pki.Pool,
+			Certificates: []tls.Certificate{clientCert},
+		}))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = c.Close() })
+		assert.Equal(t, "alice", mustCallName(t, c))
+	})
+
+	t.Run("clients without certificates are rejected", func(t *testing.T) {
+		c, err := Dial(l.Addr().String(), WithTLSConfig(&tls.Config{RootCAs: pki.Pool}), WithoutReconnect())
+		if err == nil {
+			// With TLS 1.3, clients complete the handshake before the
+			// server verifies their certificate.
+			t.Cleanup(func() { _ = c.Close() })
+			_, err = callName(context.Background(), c, "Name")
+		}
+		assert.Error(t, err)
+	})
+
+	t.Run("clients verify the server certificate", func(t *testing.T) {
+		_, err := Dial(l.Addr().String(), WithTLSConfig(&tls.Config{}))
+		assert.Error(t, err)
+	})
+}
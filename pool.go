@@ -0,0 +1,457 @@
+package arf
+
+import (
+	"context"
+	"errors"
+	"github.com/arf-rpc/arf-go/status"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Resolver provides the set of addresses a pool maintains connections to.
+type Resolver interface {
+	Resolve(ctx context.Context) ([]string, error)
+}
+
+// StaticResolver always resolves to the same list of addresses.
+type StaticResolver []string
+
+func (s StaticResolver) Resolve(context.Context) ([]string, error) {
+	return slices.Clone(s), nil
+}
+
+// BalancingPolicy determines which connection of a pool handles each call.
+type BalancingPolicy int
+
+const (
+	// RoundRobin picks ready connections in turn.
+	RoundRobin BalancingPolicy = iota
+	// LeastOutstanding picks the ready connection with the least amount of
+	// active streams.
+	LeastOutstanding
+)
+
+type PoolOptions struct {
+	// Resolver provides the addresses to connect to. Required.
+	Resolver Resolver
+	// Policy determines how connections are picked for each call.
+	Policy BalancingPolicy
+	// ConnsPerAddress is the amount of connections kept to each address.
+	// Defaults to 1.
+	ConnsPerAddress int
+	// ResolveInterval controls how often Resolver is consulted for address
+	// changes. Zero resolves addresses only once.
+	ResolveInterval time.Duration
+	// HealthCheckInterval controls how often connections are pinged. Zero
+	// disables health checks.
+	HealthCheckInterval time.Duration
+	// HealthCheckTimeout is the maximum time to wait for a ping to be
+	// acknowledged before ejecting a connection. Defaults to 5 seconds.
+	HealthCheckTimeout time.Duration
+	// Backoff controls delays between reconnection attempts.
+	Backoff Backoff
+	// ClientOptions are applied to every connection of the pool.
+	ClientOptions []ClientOption
+}
+
+// NoConnectionAvailableErr is returned by a pool's Call when none of its
+// connections are ready.
+var NoConnectionAvailableErr = &status.BadStatus{
+	Code:    status.Unavailable,
+	Message: "no connection available",
+}
+
+type pool struct {
+	opts PoolOptions
+	// template holds the configuration of ClientOptions shared by every
+	// connection, such as retry policies.
+	template *client
+	next     atomic.Uint64
+	state    *connectivity
+
+	mu       sync.Mutex
+	closed   bool
+	subConns []*subConn
+	// ctx is canceled once the pool is closed.
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// subConn maintains a single connection to an address, reconnecting with
+// backoff whenever it is lost.
+type subConn struct {
+	addr string
+	p    *pool
+	// ctx is canceled once the sub-connection is stopped.
+	ctx  context.Context
+	stop context.CancelFunc
+
+	mu     sync.Mutex
+	client *client
+	state  ConnectivityState
+}
+
+// DialPool connects to every address provided by opts.Resolver, and returns
+// a Client distributing calls across them according to opts.Policy.
+// Connections terminated by a GOAWAY, a transport error or a failed health
+// check are ejected and reestablished in the background. DialPool fails
+// only in case no address could be reached.
+func DialPool(opts PoolOptions) (Client, error) {
+	return DialPoolContext(context.Background(), opts)
+}
+
+// DialPoolContext connects to every address like DialPool, dialing them
+// concurrently. ctx bounds resolving addresses and establishing the initial
+// connections; addresses not reached by then are retried in the background.
+func DialPoolContext(ctx context.Context, opts PoolOptions) (Client, error) {
+	if opts.Resolver == nil {
+		return nil, errors.New("arf: PoolOptions.Resolver is required")
+	}
+	if opts.ConnsPerAddress <= 0 {
+		opts.ConnsPerAddress = 1
+	}
+	if opts.HealthCheckTimeout <= 0 {
+		opts.HealthCheckTimeout = 5 * time.Second
+	}
+
+	addrs, err := opts.Resolver.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("arf: resolver returned no addresses")
+	}
+	var unique []string
+	for _, addr := range addrs {
+		if !slices.Contains(unique, addr) {
+			unique = append(unique, addr)
+		}
+	}
+	addrs = unique
+
+	p := &pool{
+		opts:     opts,
+		template: newClient(opts.ClientOptions),
+		state:    newConnectivity(Connecting),
+	}
+	p.ctx, p.stop = context.WithCancel(context.Background())
+
+	type dialed struct {
+		addr string
+		c    *client
+		err  error
+	}
+	results := make([]dialed, len(addrs)*opts.ConnsPerAddress)
+	var wg sync.WaitGroup
+	for i := range results {
+		addr := addrs[i/opts.ConnsPerAddress]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := p.dial(ctx, addr)
+			results[i] = dialed{addr: addr, c: c, err: err}
+		}()
+	}
+	wg.Wait()
+
+	var lastErr error
+	connected := false
+	p.mu.Lock()
+	for _, res := range results {
+		if res.err != nil {
+			lastErr = res.err
+		} else {
+			connected = true
+		}
+		p.startSubConn(res.addr, res.c)
+	}
+	p.updateState()
+	p.mu.Unlock()
+
+	if !connected {
+		_ = p.Close()
+		return nil, lastErr
+	}
+
+	if opts.ResolveInterval > 0 {
+		p.wg.Add(1)
+		go p.resolveLoop()
+	}
+
+	return p, nil
+}
+
+// dial connects to addr. Connections of a pool do not reconnect by
+// themselves, as subConn takes care of it.
+func (p *pool) dial(ctx context.Context, addr string) (*client, error) {
+	c := newClient(append(slices.Clone(p.opts.ClientOptions), WithoutReconnect()))
+	c.addr = addr
+	if err := c.dial(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// startSubConn must be called with p.mu held. c may be nil, in which case
+// the sub-connection starts by dialing addr.
+func (p *pool) startSubConn(addr string, c *client) {
+	sc := &subConn{addr: addr, p: p, client: c, state: Ready}
+	sc.ctx, sc.stop = context.WithCancel(p.ctx)
+	if c == nil {
+		sc.state = Connecting
+	}
+	p.subConns = append(p.subConns, sc)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		sc.run(c)
+	}()
+}
+
+// connectedTo must be called with p.mu held.
+func (p *pool) connectedTo(addr string) bool {
+	return slices.ContainsFunc(p.subConns, func(sc *subConn) bool {
+		return sc.addr == addr
+	})
+}
+
+func (p *pool) resolveLoop() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.opts.ResolveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		addrs, err := p.opts.Resolver.Resolve(p.ctx)
+		if err != nil || len(addrs) == 0 {
+			// Keep current connections until the resolver recovers.
+			continue
+		}
+		p.update(addrs)
+	}
+}
+
+func (p *pool) update(addrs []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+
+	p.subConns = slices.DeleteFunc(p.subConns, func(sc *subConn) bool {
+		if slices.Contains(addrs, sc.addr) {
+			return false
+		}
+		sc.stop()
+		return true
+	})
+
+	for _, addr := range addrs {
+		if p.connectedTo(addr) {
+			continue
+		}
+		for range p.opts.ConnsPerAddress {
+			p.startSubConn(addr, nil)
+		}
+	}
+	p.updateState()
+}
+
+// updateState must be called with p.mu held. The pool is Ready as long as
+// any of its connections is.
+func (p *pool) updateState() {
+	if p.closed {
+		return
+	}
+	state := Idle
+	for _, sc := range p.subConns {
+		sc.mu.Lock()
+		s := sc.state
+		sc.mu.Unlock()
+		switch {
+		case s == Ready:
+			state = Ready
+		case s == Connecting && state != Ready:
+			state = Connecting
+		case s == TransientFailure && state == Idle:
+			state = TransientFailure
+		}
+	}
+	p.state.set(state)
+}
+
+func (p *pool) State() (ConnectivityState, <-chan struct{}) {
+	return p.state.get()
+}
+
+func (p *pool) ready() []*client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var ready []*client
+	for _, sc := range p.subConns {
+		if c := sc.ready(); c != nil {
+			ready = append(ready, c)
+		}
+	}
+	return ready
+}
+
+func (p *pool) pick() (*client, error) {
+	ready := p.ready()
+	if len(ready) == 0 {
+		return nil, NoConnectionAvailableErr
+	}
+
+	start := int(p.next.Add(1) % uint64(len(ready)))
+	if p.opts.Policy == RoundRobin {
+		return ready[start], nil
+	}
+
+	best, bestStreams := ready[start], ready[start].c.ActiveStreams()
+	for i := 1; i < len(ready); i++ {
+		c := ready[(start+i)%len(ready)]
+		if n := c.c.ActiveStreams(); n < bestStreams {
+			best, bestStreams = c, n
+		}
+	}
+	return best, nil
+}
+
+// Call picks a connection for each attempt, so retried and hedged attempts
+// are likely to be handled by a different server.
+func (p *pool) Call(ctx context.Context, serviceIdentifier, serviceMethod string, opts ...CallOption) (Context, error) {
+	return p.template.intercept(ctx, serviceIdentifier, serviceMethod, opts, func(ctx context.Context, serviceIdentifier, serviceMethod string, opts ...CallOption) (Context, error) {
+		attempt := func(ctx context.Context, opts []CallOption) (Context, error) {
+			c, err := p.pick()
+			if err != nil {
+				return nil, err
+			}
+			return c.call(ctx, serviceIdentifier, serviceMethod, opts...)
+		}
+		return p.template.invoke(ctx, serviceIdentifier, serviceMethod, opts, attempt)
+	})
+}
+
+func (p *pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.stop()
+	p.subConns = nil
+	p.mu.Unlock()
+
+	p.state.set(Shutdown)
+	p.wg.Wait()
+	return nil
+}
+
+func (sc *subConn) ready() *client {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.client == nil || sc.client.c.Err() != nil {
+		return nil
+	}
+	return sc.client
+}
+
+func (sc *subConn) setClient(c *client) {
+	sc.mu.Lock()
+	sc.client = c
+	sc.mu.Unlock()
+
+	if c != nil {
+		sc.setState(Ready)
+	} else {
+		sc.setState(Connecting)
+	}
+}
+
+func (sc *subConn) setState(s ConnectivityState) {
+	sc.mu.Lock()
+	sc.state = s
+	sc.mu.Unlock()
+
+	sc.p.mu.Lock()
+	defer sc.p.mu.Unlock()
+	sc.p.updateState()
+}
+
+func (sc *subConn) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-sc.ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// run keeps a connection to sc.addr until sc is stopped. The first attempt
+// after losing a connection is made right away; further failures back off.
+func (sc *subConn) run(c *client) {
+	attempt := 0
+	for {
+		if c == nil {
+			if attempt > 0 && !sc.sleep(sc.p.opts.Backoff.Delay(attempt-1)) {
+				return
+			}
+			var err error
+			if c, err = sc.p.dial(sc.ctx, sc.addr); err != nil {
+				sc.setState(TransientFailure)
+				attempt++
+				continue
+			}
+		}
+
+		attempt = 0
+		sc.setClient(c)
+		stopped := sc.watch(c)
+		sc.setClient(nil)
+		_ = c.Close()
+		c = nil
+		if stopped {
+			return
+		}
+	}
+}
+
+// watch blocks until c must be ejected, either because it was terminated or
+// failed a health check, or because the sub-connection was stopped, in which
+// case it returns true.
+func (sc *subConn) watch(c *client) bool {
+	var tick <-chan time.Time
+	if interval := sc.p.opts.HealthCheckInterval; interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	for {
+		select {
+		case <-sc.ctx.Done():
+			return true
+		case <-c.c.Done():
+			return false
+		case <-tick:
+			ctx, cancel := context.WithTimeout(context.Background(), sc.p.opts.HealthCheckTimeout)
+			err := c.c.Ping(ctx)
+			cancel()
+			if err != nil {
+				return false
+			}
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package arf
+
+import (
+	"context"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const nameService = "org.example.test/Name"
+
+// testBackoff keeps reconnections quick in tests.
+var testBackoff = Backoff{BaseDelay: 10 * time.Millisecond, MaxDelay: 20 * time.Millisecond}
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	return l
+}
+
+// relisten listens on addr once again, after the server previously bound to
+// it was shut down.
+func relisten(t *testing.T, addr string) net.Listener {
+	t.Helper()
+
+	var l net.Listener
+	require.Eventually(t, func() bool {
+		var err error
+		l, err = net.Listen("tcp", addr)
+		return err == nil
+	}, 3*time.Second, 10*time.Millisecond)
+	return l
+}
+
+// serveNamed serves a service on l whose Name method answers name, and
+// whose Hang method answers name then keeps its response stream open until
+// the call is canceled.
+func serveNamed(t *testing.T, l net.Listener, name string) Server {
+	t.Helper()
+
+	server, err := NewServer(l, ServerOptions{})
+	require.NoError(t, err)
+	server.MustRegisterService(ServiceAdapter{
+		ServiceID: nameService,
+		Methods: map[string]ServiceExecutor{
+			"Name": func(ctx context.Context, c Context) error {
+				return c.SendResponse(status.OK, []any{name}, false, nil)
+			},
+			"Hang": func(ctx context.Context, c Context) error {
+				if err := c.SendResponse(status.OK, []any{name}, true, nil); err != nil {
+					return err
+				}
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		},
+	})
+	go func() { _ = server.Serve() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return server
+}
+
+func callName(ctx context.Context, c Client, method string) (string, error) {
+	call, err := c.Call(ctx, nameService, method)
+	if err != nil {
+		return "", err
+	}
+	result, err := call.Response().Result()
+	if err != nil {
+		return "", err
+	}
+	return result[0].(string), nil
+}
+
+func mustCallName(t *testing.T, c Client) string {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	name, err := callName(ctx, c, "Name")
+	require.NoError(t, err)
+	return name
+}
+
+func readyConns(c Client) int {
+	return len(c.(*pool).ready())
+}
+
+// stallingConn silently drops everything written while stalled holds the
+// address it is connected to.
+type stallingConn struct {
+	net.Conn
+	addr    string
+	stalled *atomic.Value
+}
+
+func (s *stallingConn) Write(p []byte) (int, error) {
+	if s.stalled.Load() == s.addr {
+		return len(p), nil
+	}
+	return s.Conn.Write(p)
+}
+
+func TestPool(t *testing.T) {
+	// makePool serves two named servers, returning a pool connected to both
+	// along with the first server.
+	makePool := func(t *testing.T, opts PoolOptions) (Client, Server) {
+		t.Helper()
+
+		la, lb := listen(t), listen(t)
+		a := serveNamed(t, la, "a")
+		serveNamed(t, lb, "b")
+		opts.Resolver = StaticResolver{la.Addr().String(), lb.Addr().String()}
+		opts.Backoff = testBackoff
+		p, err := DialPool(opts)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = p.Close() })
+		require.Equal(t, 2, readyConns(p))
+		return p, a
+	}
+
+	t.Run("round robin alternates between connections", func(t *testing.T) {
+		p, _ := makePool(t, PoolOptions{Policy: RoundRobin})
+		state, _ := p.State()
+		assert.Equal(t, Ready, state)
+
+		var names []string
+		for range 4 {
+			names = append(names, mustCallName(t, p))
+		}
+		assert.ElementsMatch(t, []string{"a", "a", "b", "b"}, names)
+		assert.NotEqual(t, names[0], names[1])
+		assert.Equal(t, names[0], names[2])
+		assert.Equal(t, names[1], names[3])
+	})
+
+	t.Run("least outstanding avoids busy connections", func(t *testing.T) {
+		p, _ := makePool(t, PoolOptions{Policy: LeastOutstanding})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		busy, err := callName(ctx, p, "Hang")
+		require.NoError(t, err)
+
+		activeStreams := func() int {
+			n := 0
+			for _, c := range p.(*pool).ready() {
+				n += c.c.ActiveStreams()
+			}
+			return n
+		}
+		for range 4 {
+			require.Eventually(t, func() bool { return activeStreams() == 1 }, 3*time.Second, 5*time.Millisecond)
+			assert.NotEqual(t, busy, mustCallName(t, p))
+		}
+	})
+
+	t.Run("connections terminated by GOAWAY are ejected and redialed", func(t *testing.T) {
+		p, a := makePool(t, PoolOptions{})
+		addrA := a.(*srv).listener.Addr().String()
+
+		require.NoError(t, a.Shutdown())
+		require.Eventually(t, func() bool { return readyConns(p) == 1 }, 3*time.Second, 5*time.Millisecond)
+		for range 4 {
+			assert.Equal(t, "b", mustCallName(t, p))
+		}
+		state, _ := p.State()
+		assert.Equal(t, Ready, state)
+
+		serveNamed(t, relisten(t, addrA), "a2")
+		require.Eventually(t, func() bool { return readyConns(p) == 2 }, 3*time.Second, 5*time.Millisecond)
+		var names []string
+		for range 2 {
+			names = append(names, mustCallName(t, p))
+		}
+		assert.ElementsMatch(t, []string{"a2", "b"}, names)
+	})
+
+	t.Run("connections failing health checks are ejected", func(t *testing.T) {
+		var stalled atomic.Value
+		p, a := makePool(t, PoolOptions{
+			HealthCheckInterval: 20 * time.Millisecond,
+			HealthCheckTimeout:  50 * time.Millisecond,
+			ClientOptions: []ClientOption{
+				WithDialTimeout(100 * time.Millisecond),
+				WithDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+					conn, err := defaultDialer(ctx, addr)
+					if err != nil {
+						return nil, err
+					}
+					return &stallingConn{Conn: conn, addr: addr, stalled: &stalled}, nil
+				}),
+			},
+		})
+
+		stalled.Store(a.(*srv).listener.Addr().String())
+		require.Eventually(t, func() bool { return readyConns(p) == 1 }, 3*time.Second, 5*time.Millisecond)
+		for range 4 {
+			assert.Equal(t, "b", mustCallName(t, p))
+		}
+
+		stalled.Store("")
+		require.Eventually(t, func() bool { return readyConns(p) == 2 }, 3*time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("calls fail once no connection is ready", func(t *testing.T) {
+		l := listen(t)
+		server := serveNamed(t, l, "a")
+		p, err := DialPool(PoolOptions{Resolver: StaticResolver{l.Addr().String()}, Backoff: testBackoff})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = p.Close() })
+
+		require.NoError(t, server.Shutdown())
+		require.Eventually(t, func() bool {
+			state, _ := p.State()
+			return state != Ready
+		}, 3*time.Second, 5*time.Millisecond)
+		_, err = callName(context.Background(), p, "Name")
+		assert.Equal(t, status.Unavailable, status.Convert(err).Code)
+	})
+
+	t.Run("dialing fails once ctx is done", func(t *testing.T) {
+		l := listen(t)
+		serveNamed(t, l, "a")
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := DialPoolContext(ctx, PoolOptions{Resolver: StaticResolver{l.Addr().String()}, Backoff: testBackoff})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -8,7 +8,7 @@ import (
 
 const arrayEmptyMask = byte(0x01) << 4
 
-func encodeArray(v reflect.Value) ([]byte, error) {
+func encodeArray(v reflect.Value, opts EncodeOptions) ([]byte, error) {
 	if v.Len() == 0 {
 		return []byte{byte(TypeArray) | arrayEmptyMask}, nil
 	}
@@ -16,7 +16,7 @@ func encodeArray(v reflect.Value) ([]byte, error) {
 	var buffer []byte
 	for i := 0; i < v.Len(); i++ {
 		item := v.Index(i).Interface()
-		data, err := Encode(item)
+		data, err := EncodeWithOptions(item, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -39,6 +39,10 @@ func decodeArray(header byte, r io.Reader) ([]any, error) {
 		return nil, err
 	}
 
+	if err = checkLength(r, LimitCollectionLength, arrLen); err != nil {
+		return nil, err
+	}
+
 	arr := make([]any, arrLen)
 	for i := range arrLen {
 		if arr[i], err = DecodeAny(r); err != nil {
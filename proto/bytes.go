@@ -31,8 +31,12 @@ func decodeBytes(header byte, r io.Reader) ([]byte, error) {
 		return nil, err
 	}
 
+	if err = checkLength(r, LimitBytesLength, size); err != nil {
+		return nil, err
+	}
+
 	data := make([]byte, size)
-	if _, err = r.Read(data); err != nil {
+	if _, err = io.ReadFull(r, data); err != nil {
 		return nil, err
 	}
 
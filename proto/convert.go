@@ -0,0 +1,36 @@
+package proto
+
+import (
+	"reflect"
+)
+
+// Convert converts value, as returned by DecodeAny, into T. It applies the
+// same conversions performed when decoding struct fields, such as narrowing
+// the int64 and uint64 values yielded for integers, or dereferencing the
+// pointers yielded for structs. Returns a *ConversionError in case value
+// cannot be represented as T, such as integers overflowing T, or integers and
+// strings converted into one another.
+func Convert[T any](value any) (T, error) {
+	var res T
+	if v, ok := value.(T); ok {
+		return v, nil
+	}
+
+	to := reflect.TypeFor[T]()
+	convErr := &ConversionError{From: reflect.TypeOf(value), To: to}
+	if value == nil {
+		switch to.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+			return res, nil
+		}
+		return res, convErr
+	}
+
+	holder := reflect.New(reflect.StructOf([]reflect.StructField{
+		{Name: "Value", Type: to},
+	})).Elem()
+	if !setValue(holder, holder.Type().Field(0), value) {
+		return res, convErr
+	}
+	return holder.Field(0).Interface().(T), nil
+}
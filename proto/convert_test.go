@@ -0,0 +1,108 @@
+package proto
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func roundTrip(t *testing.T, v any) any {
+	t.Helper()
+	b, err := Encode(v)
+	require.NoError(t, err)
+	decoded, err := DecodeAny(bytes.NewReader(b))
+	require.NoError(t, err)
+	return decoded
+}
+
+func TestConvert(t *testing.T) {
+	resetRegistry()
+	RegisterMessage(SubStruct{})
+
+	t.Run("narrows integers", func(t *testing.T) {
+		v, err := Convert[int32](roundTrip(t, int32(12)))
+		require.NoError(t, err)
+		assert.Equal(t, int32(12), v)
+
+		v, err = Convert[int32](roundTrip(t, int32(-12)))
+		require.NoError(t, err)
+		assert.Equal(t, int32(-12), v)
+	})
+
+	t.Run("dereferences structs", func(t *testing.T) {
+		v, err := Convert[SubStruct](roundTrip(t, SubStruct{A: "a"}))
+		require.NoError(t, err)
+		assert.Equal(t, SubStruct{A: "a"}, v)
+
+		p, err := Convert[*SubStruct](roundTrip(t, SubStruct{A: "a"}))
+		require.NoError(t, err)
+		assert.Equal(t, &SubStruct{A: "a"}, p)
+	})
+
+	t.Run("specializes collections", func(t *testing.T) {
+		s, err := Convert[[]string](roundTrip(t, []string{"a", "b"}))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, s)
+
+		m, err := Convert[map[string]uint32](roundTrip(t, map[string]uint32{"a": 1}))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]uint32{"a": 1}, m)
+	})
+
+	t.Run("accepts nil for nillable types", func(t *testing.T) {
+		v, err := Convert[*SubStruct](nil)
+		require.NoError(t, err)
+		assert.Nil(t, v)
+	})
+
+	t.Run("rejects mismatching values", func(t *testing.T) {
+		_, err := Convert[int32]("hello")
+		var convErr *ConversionError
+		require.ErrorAs(t, err, &convErr)
+		assert.Equal(t, "cannot convert value of type string into int32", err.Error())
+
+		_, err = Convert[SubStruct](roundTrip(t, []string{"a"}))
+		assert.ErrorAs(t, err, &convErr)
+
+		_, err = Convert[string](nil)
+		assert.ErrorAs(t, err, &convErr)
+	})
+
+	t.Run("rejects integers converted from or into strings", func(t *testing.T) {
+		var convErr *ConversionError
+		_, err := Convert[string](uint64(65))
+		assert.ErrorAs(t, err, &convErr)
+
+		_, err = Convert[[]string](roundTrip(t, []uint32{65}))
+		assert.ErrorAs(t, err, &convErr)
+
+		_, err = Convert[uint64]("65")
+		assert.ErrorAs(t, err, &convErr)
+	})
+
+	t.Run("rejects integers overflowing the target type", func(t *testing.T) {
+		var convErr *ConversionError
+		_, err := Convert[int8](uint64(300))
+		assert.ErrorAs(t, err, &convErr)
+
+		_, err = Convert[uint32](int64(-1))
+		assert.ErrorAs(t, err, &convErr)
+
+		_, err = Convert[int64](uint64(1 << 63))
+		assert.ErrorAs(t, err, &convErr)
+
+		_, err = Convert[map[string]uint8](roundTrip(t, map[string]uint32{"a": 256}))
+		assert.ErrorAs(t, err, &convErr)
+
+		v, err := Convert[int8](uint64(127))
+		require.NoError(t, err)
+		assert.Equal(t, int8(127), v)
+	})
+
+	t.Run("rejects slices shorter than the target array", func(t *testing.T) {
+		var convErr *ConversionError
+		_, err := Convert[[4]byte]([]byte{1, 2})
+		assert.ErrorAs(t, err, &convErr)
+	})
+}
@@ -4,8 +4,127 @@ import (
 	"io"
 )
 
-func DecodeAny(r io.Reader) (any, error) {
-	t, b, err := readType(r)
+// DecodeOptions limits the resources a single decoded value may consume.
+// Zero values disable the corresponding limit.
+type DecodeOptions struct {
+	// MaxStringLength is the maximum length, in bytes, of a decoded string.
+	MaxStringLength uint64
+	// MaxBytesLength is the maximum length of a decoded byte slice.
+	MaxBytesLength uint64
+	// MaxCollectionLength is the maximum amount of items in an array, or
+	// pairs in a map.
+	MaxCollectionLength uint64
+	// MaxDepth is the maximum nesting level of arrays, maps and structs.
+	MaxDepth int
+	// MaxMessageSize is the maximum amount of bytes read from the
+	// underlying reader.
+	MaxMessageSize uint64
+}
+
+// Decoder reads values from an underlying reader while enforcing a set of
+// DecodeOptions. A Decoder is itself an io.Reader, and can be handed to
+// functions such as DecodeAny, DecodeString and DecodeBytes, which will then
+// honor its limits.
+type Decoder struct {
+	r     io.Reader
+	opts  DecodeOptions
+	read  uint64
+	depth int
+	// size holds the declared length of the enclosing struct payload for
+	// decoders created through sub, and zero otherwise.
+	size uint64
+}
+
+func NewDecoder(r io.Reader, opts DecodeOptions) *Decoder {
+	return &Decoder{r: r, opts: opts}
+}
+
+func decoderFor(r io.Reader) *Decoder {
+	if d, ok := r.(*Decoder); ok {
+		return d
+	}
+	return NewDecoder(r, DecodeOptions{})
+}
+
+// sub returns a Decoder limited to the next size bytes of d, sharing its
+// options and current depth.
+func (d *Decoder) sub(size uint64) *Decoder {
+	opts := d.opts
+	opts.MaxMessageSize = 0
+	return &Decoder{
+		r:     io.LimitReader(d, int64(size)),
+		opts:  opts,
+		depth: d.depth,
+		size:  size,
+	}
+}
+
+func (d *Decoder) Read(p []byte) (int, error) {
+	if max := d.opts.MaxMessageSize; max > 0 {
+		remaining := max - d.read
+		if remaining == 0 {
+			return 0, &LimitExceededError{Limit: LimitMessageSize, Max: max, Received: d.read + uint64(len(p))}
+		}
+		if uint64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+	}
+	n, err := d.r.Read(p)
+	d.read += uint64(n)
+	return n, err
+}
+
+// BytesRead returns the amount of bytes consumed from the underlying reader.
+func (d *Decoder) BytesRead() uint64 { return d.read }
+
+func (d *Decoder) checkLength(limit Limit, max, length uint64) error {
+	if max > 0 && length > max {
+		return &LimitExceededError{Limit: limit, Max: max, Received: length}
+	}
+	if msgMax := d.opts.MaxMessageSize; msgMax > 0 && length > msgMax-d.read {
+		return &LimitExceededError{Limit: LimitMessageSize, Max: msgMax, Received: d.read + length}
+	}
+	if d.size > 0 && length > d.size-d.read {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func checkLength(r io.Reader, limit Limit, length uint64) error {
+	d, ok := r.(*Decoder)
+	if !ok {
+		return nil
+	}
+	switch limit {
+	case LimitStringLength:
+		return d.checkLength(limit, d.opts.MaxStringLength, length)
+	case LimitBytesLength:
+		return d.checkLength(limit, d.opts.MaxBytesLength, length)
+	case LimitCollectionLength:
+		// Each item takes at least one byte, so the remaining message size
+		// also bounds the collection length.
+		return d.checkLength(limit, d.opts.MaxCollectionLength, length)
+	default:
+		// Length prefixes of maps and structs are only bound by the message
+		// size.
+		return d.checkLength(limit, 0, length)
+	}
+}
+
+func (d *Decoder) enter() error {
+	d.depth++
+	if d.opts.MaxDepth > 0 && d.depth > d.opts.MaxDepth {
+		return &LimitExceededError{Limit: LimitDepth, Max: uint64(d.opts.MaxDepth), Received: uint64(d.depth)}
+	}
+	return nil
+}
+
+func (d *Decoder) leave() { d.depth-- }
+
+// Decode reads a single value from the underlying reader. Integers encoded
+// from signed types are returned as int64, and others as uint64.
+func (d *Decoder) Decode() (any, error) {
+	t, b, err := readType(d)
 	if err != nil {
 		return nil, err
 	}
@@ -14,24 +133,46 @@ func DecodeAny(r io.Reader) (any, error) {
 	case TypeVoid:
 		return nil, nil
 	case TypeScalar:
-		_, _, v, err := decodeScalar(b, r)
-		return v, err
+		signed, negative, v, err := decodeScalar(b, d)
+		switch {
+		case err != nil:
+			return nil, err
+		case negative:
+			return -int64(v), nil
+		case signed:
+			return int64(v), nil
+		}
+		return v, nil
 	case TypeBoolean:
 		return decodeBoolean(b), nil
 	case TypeFloat:
-		_, v, err := decodeFloat(b, r)
+		_, v, err := decodeFloat(b, d)
 		return v, err
 	case TypeString:
-		return decodeString(b, r)
+		return decodeString(b, d)
 	case TypeBytes:
-		return decodeBytes(b, r)
+		return decodeBytes(b, d)
+	}
+
+	if err = d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+
+	switch t {
 	case TypeArray:
-		return decodeArray(b, r)
+		return decodeArray(b, d)
 	case TypeMap:
-		return decodeMap(b, r)
+		return decodeMap(b, d)
 	case TypeStruct:
-		return decodeStruct(r)
+		return decodeStruct(d)
 	default:
 		panic("unreachable")
 	}
 }
+
+// DecodeAny reads a single value from r. In case r is a Decoder, its
+// DecodeOptions are enforced.
+func DecodeAny(r io.Reader) (any, error) {
+	return decoderFor(r).Decode()
+}
@@ -0,0 +1,99 @@
+package proto
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func requireLimit(t *testing.T, err error, limit Limit) {
+	t.Helper()
+	var limitErr *LimitExceededError
+	require.True(t, errors.As(err, &limitErr), "expected LimitExceededError, got %v", err)
+	assert.Equal(t, limit, limitErr.Limit)
+}
+
+func TestDecoder(t *testing.T) {
+	t.Run("no limits", func(t *testing.T) {
+		b, err := Encode([]string{"hello", "world"})
+		require.NoError(t, err)
+		v, err := NewDecoder(bytes.NewReader(b), DecodeOptions{}).Decode()
+		require.NoError(t, err)
+		assert.Equal(t, []any{"hello", "world"}, v)
+	})
+
+	t.Run("string length", func(t *testing.T) {
+		b := EncodeString("hello, arf!")
+		_, err := DecodeAny(NewDecoder(bytes.NewReader(b), DecodeOptions{MaxStringLength: 4}))
+		requireLimit(t, err, LimitStringLength)
+	})
+
+	t.Run("bytes length", func(t *testing.T) {
+		b := EncodeBytes([]byte{0x01, 0x02, 0x03})
+		_, err := DecodeBytes(NewDecoder(bytes.NewReader(b), DecodeOptions{MaxBytesLength: 2}))
+		requireLimit(t, err, LimitBytesLength)
+	})
+
+	t.Run("collection length", func(t *testing.T) {
+		b, err := Encode([]uint16{1, 2, 3})
+		require.NoError(t, err)
+		_, err = NewDecoder(bytes.NewReader(b), DecodeOptions{MaxCollectionLength: 2}).Decode()
+		requireLimit(t, err, LimitCollectionLength)
+
+		b, err = Encode(map[string]string{"a": "b", "c": "d"})
+		require.NoError(t, err)
+		_, err = NewDecoder(bytes.NewReader(b), DecodeOptions{MaxCollectionLength: 1}).Decode()
+		requireLimit(t, err, LimitCollectionLength)
+	})
+
+	t.Run("depth", func(t *testing.T) {
+		b, err := Encode([][][]uint16{{{1}}})
+		require.NoError(t, err)
+		_, err = NewDecoder(bytes.NewReader(b), DecodeOptions{MaxDepth: 3}).Decode()
+		require.NoError(t, err)
+		_, err = NewDecoder(bytes.NewReader(b), DecodeOptions{MaxDepth: 2}).Decode()
+		requireLimit(t, err, LimitDepth)
+	})
+
+	t.Run("depth within structs", func(t *testing.T) {
+		resetRegistry()
+		RegisterMessage(SubStruct{})
+		b, err := Encode([]SubStruct{{A: "hello"}})
+		require.NoError(t, err)
+		_, err = NewDecoder(bytes.NewReader(b), DecodeOptions{MaxDepth: 2}).Decode()
+		require.NoError(t, err)
+		_, err = NewDecoder(bytes.NewReader(b), DecodeOptions{MaxDepth: 1}).Decode()
+		requireLimit(t, err, LimitDepth)
+	})
+
+	t.Run("message size", func(t *testing.T) {
+		b, err := Encode([]string{"hello", "world"})
+		require.NoError(t, err)
+		_, err = NewDecoder(bytes.NewReader(b), DecodeOptions{MaxMessageSize: uint64(len(b))}).Decode()
+		require.NoError(t, err)
+		_, err = NewDecoder(bytes.NewReader(b), DecodeOptions{MaxMessageSize: uint64(len(b) - 1)}).Decode()
+		requireLimit(t, err, LimitMessageSize)
+	})
+
+	t.Run("declared lengths are checked before allocating", func(t *testing.T) {
+		// A string header claiming to hold 2^62 bytes.
+		b := append([]byte{byte(TypeString)}, encodeUint64(1<<62)...)
+		_, err := NewDecoder(bytes.NewReader(b), DecodeOptions{MaxMessageSize: 1024}).Decode()
+		requireLimit(t, err, LimitMessageSize)
+	})
+
+	t.Run("struct fields cannot overrun the struct payload", func(t *testing.T) {
+		resetRegistry()
+		RegisterMessage(SubStruct{})
+		b, err := Encode(SubStruct{A: "hello"})
+		require.NoError(t, err)
+		// Replace the string length with a value larger than the payload.
+		idx := bytes.Index(b, []byte("hello"))
+		b[idx-1] = 0x7f
+		_, err = NewDecoder(bytes.NewReader(b), DecodeOptions{}).Decode()
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+}
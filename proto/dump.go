@@ -0,0 +1,283 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// dumpPreviewLength is the maximum amount of bytes of strings and byte
+// slices included in a dump.
+const dumpPreviewLength = 64
+
+type dumper struct {
+	r   *Decoder
+	out strings.Builder
+}
+
+// Dump walks all values encoded in r and returns an annotated tree
+// describing them. Each line starts with the offset of the item it describes,
+// followed by its primitive type, header byte, flags and lengths. Struct
+// fields are annotated with their names when the struct ID is registered,
+// and unregistered structs are walked regardless. In case the data is
+// malformed, the dump produced so far is returned along with the error.
+func Dump(r io.Reader) (string, error) {
+	d := &dumper{r: NewDecoder(r, DecodeOptions{})}
+	for {
+		offset := d.r.BytesRead()
+		t, b, err := readType(d.r)
+		if err == io.EOF {
+			return d.out.String(), nil
+		} else if err != nil {
+			return d.out.String(), d.fail(offset, err)
+		}
+		if err = d.value(offset, 0, "", t, b); err != nil {
+			return d.out.String(), err
+		}
+	}
+}
+
+func (d *dumper) line(offset uint64, depth int, format string, args ...any) {
+	d.out.WriteString(fmt.Sprintf("%08x  %s", offset, strings.Repeat("  ", depth)))
+	d.out.WriteString(fmt.Sprintf(format, args...))
+	d.out.WriteByte('\n')
+}
+
+func (d *dumper) fail(offset uint64, err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("dump: offset 0x%08x: %w", offset, err)
+}
+
+func (d *dumper) next(depth int, label string) error {
+	offset := d.r.BytesRead()
+	t, b, err := readType(d.r)
+	if err != nil {
+		return d.fail(offset, err)
+	}
+	return d.value(offset, depth, label, t, b)
+}
+
+func flagList(flags ...string) string {
+	var set []string
+	for i := 0; i < len(flags); i += 2 {
+		if flags[i+1] != "" {
+			set = append(set, flags[i])
+		}
+	}
+	if len(set) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(set, ",") + "]"
+}
+
+func isSet(header, mask byte) string {
+	if header&mask == mask {
+		return "y"
+	}
+	return ""
+}
+
+func (d *dumper) varint(depth int, name string) (uint64, error) {
+	offset := d.r.BytesRead()
+	v, err := decodeUint64(d.r)
+	if err != nil {
+		return 0, d.fail(offset, err)
+	}
+	d.line(offset, depth, "%s: %d (varint, %d bytes)", name, v, len(encodeUint64(v)))
+	return v, nil
+}
+
+func (d *dumper) raw(n uint64) ([]byte, error) {
+	offset := d.r.BytesRead()
+	if err := d.r.checkLength(LimitMessageSize, 0, n); err != nil {
+		return nil, d.fail(offset, err)
+	}
+	// n comes from the input, which may be malformed; read through a
+	// buffer instead of allocating n bytes upfront.
+	var buf bytes.Buffer
+	read, err := io.Copy(&buf, io.LimitReader(d.r, int64(min(n, math.MaxInt64))))
+	if err != nil {
+		return nil, d.fail(offset, err)
+	}
+	if uint64(read) < n {
+		return nil, d.fail(offset, io.ErrUnexpectedEOF)
+	}
+	return buf.Bytes(), nil
+}
+
+func preview(data []byte, str bool) string {
+	suffix := ""
+	if len(data) > dumpPreviewLength {
+		data = data[:dumpPreviewLength]
+		suffix = "..."
+	}
+	if str {
+		return strconv.Quote(string(data)) + suffix
+	}
+	return hex.EncodeToString(data) + suffix
+}
+
+func (d *dumper) value(offset uint64, depth int, label string, t PrimitiveType, b byte) error {
+	head := fmt.Sprintf("%s%s (0x%02x)", label, strings.TrimPrefix(t.String(), "Type"), b)
+
+	switch t {
+	case TypeVoid:
+		d.line(offset, depth, "%s", head)
+
+	case TypeBoolean:
+		d.line(offset, depth, "%s value=%t", head, decodeBoolean(b))
+
+	case TypeScalar:
+		flags := flagList("signed", isSet(b, numericSignedMask),
+			"zero", isSet(b, numericZeroMask),
+			"negative", isSet(b, numericNegativeMask))
+		if b&numericZeroMask == numericZeroMask {
+			d.line(offset, depth, "%s%s value=0", head, flags)
+			return nil
+		}
+		v, err := decodeUint64(d.r)
+		if err != nil {
+			return d.fail(offset, err)
+		}
+		sign := ""
+		if b&numericNegativeMask == numericNegativeMask {
+			sign = "-"
+		}
+		d.line(offset, depth, "%s%s value=%s%d (varint, %d bytes)", head, flags, sign, v, len(encodeUint64(v)))
+
+	case TypeFloat:
+		flags := flagList("float64", isSet(b, float64Mask), "empty", isSet(b, floatEmptyMask))
+		bits, v, err := decodeFloat(b, d.r)
+		if err != nil {
+			return d.fail(offset, err)
+		}
+		d.line(offset, depth, "%s%s value=%s", head, flags, strconv.FormatFloat(v, 'g', -1, bits))
+
+	case TypeString, TypeBytes:
+		mask := stringEmptyMask
+		if t == TypeBytes {
+			mask = bytesEmptyMask
+		}
+		flags := flagList("empty", isSet(b, mask))
+		if b&mask == mask {
+			d.line(offset, depth, "%s%s", head, flags)
+			return nil
+		}
+		d.line(offset, depth, "%s%s", head, flags)
+		n, err := d.varint(depth+1, "length")
+		if err != nil {
+			return err
+		}
+		dataOffset := d.r.BytesRead()
+		data, err := d.raw(n)
+		if err != nil {
+			return err
+		}
+		d.line(dataOffset, depth+1, "data: %s", preview(data, t == TypeString))
+
+	case TypeArray:
+		flags := flagList("empty", isSet(b, arrayEmptyMask))
+		d.line(offset, depth, "%s%s", head, flags)
+		if b&arrayEmptyMask == arrayEmptyMask {
+			return nil
+		}
+		n, err := d.varint(depth+1, "items")
+		if err != nil {
+			return err
+		}
+		for i := range n {
+			if err = d.next(depth+1, fmt.Sprintf("[%d] ", i)); err != nil {
+				return err
+			}
+		}
+
+	case TypeMap:
+		flags := flagList("empty", isSet(b, emptyMapMask))
+		d.line(offset, depth, "%s%s", head, flags)
+		if b&emptyMapMask == emptyMapMask {
+			return nil
+		}
+		if _, err := d.varint(depth+1, "size"); err != nil {
+			return err
+		}
+		n, err := d.varint(depth+1, "pairs")
+		if err != nil {
+			return err
+		}
+		for i := range n {
+			if err = d.next(depth+1, fmt.Sprintf("key[%d] ", i)); err != nil {
+				return err
+			}
+		}
+		for i := range n {
+			if err = d.next(depth+1, fmt.Sprintf("value[%d] ", i)); err != nil {
+				return err
+			}
+		}
+
+	case TypeStruct:
+		return d.structValue(offset, depth, head)
+	}
+
+	return nil
+}
+
+func (d *dumper) structValue(offset uint64, depth int, head string) error {
+	idOffset := d.r.BytesRead()
+	t, b, err := readType(d.r)
+	if err != nil {
+		return d.fail(idOffset, err)
+	}
+	if t != TypeString {
+		return d.fail(idOffset, fmt.Errorf("expected struct ID string, found %s", t))
+	}
+	id, err := decodeString(b, d.r)
+	if err != nil {
+		return d.fail(idOffset, err)
+	}
+
+	known, registered := reg.structs[id]
+	note := ""
+	if !registered {
+		note = " (unregistered)"
+	}
+	d.line(offset, depth, "%s id=%q%s", head, id, note)
+
+	size, err := d.varint(depth+1, "payload")
+	if err != nil {
+		return err
+	}
+
+	end := d.r.BytesRead() + size
+	for d.r.BytesRead() < end {
+		fieldOffset := d.r.BytesRead()
+		idx, err := decodeUint64(d.r)
+		if err != nil {
+			return d.fail(fieldOffset, err)
+		}
+		name := ""
+		if registered {
+			if f, ok := known.fields.fieldByIndex(int(idx)); ok {
+				name = " " + f.field.Name
+			} else {
+				name = " (unknown field)"
+			}
+		}
+		d.line(fieldOffset, depth+1, "field %d%s", idx, name)
+		if err = d.next(depth+2, ""); err != nil {
+			return err
+		}
+	}
+
+	if d.r.BytesRead() != end {
+		return d.fail(end, fmt.Errorf("struct %s overran its payload by %d bytes", id, d.r.BytesRead()-end))
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package proto
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	t.Run("primitives", func(t *testing.T) {
+		var buf []byte
+		for _, v := range []any{int8(-10), uint8(0), true, float32(1.5), "arf", []byte{0x01}, nil} {
+			b, err := Encode(v)
+			require.NoError(t, err)
+			buf = append(buf, b...)
+		}
+
+		out, err := Dump(bytes.NewReader(buf))
+		require.NoError(t, err)
+		assert.Equal(t, ""+
+			"00000000  Scalar (0x51) [signed,negative] value=-10 (varint, 1 bytes)\n"+
+			"00000002  Scalar (0x21) [zero] value=0\n"+
+			"00000003  Boolean (0x12) value=true\n"+
+			"00000004  Float (0x03) value=1.5\n"+
+			"00000009  String (0x04)\n"+
+			"0000000a    length: 3 (varint, 1 bytes)\n"+
+			"0000000b    data: \"arf\"\n"+
+			"0000000e  Bytes (0x05)\n"+
+			"0000000f    length: 1 (varint, 1 bytes)\n"+
+			"00000010    data: 01\n"+
+			"00000011  Void (0x00)\n", out)
+	})
+
+	t.Run("collections", func(t *testing.T) {
+		b, err := Encode(map[string][]uint16{"a": {1}})
+		require.NoError(t, err)
+
+		out, err := Dump(bytes.NewReader(b))
+		require.NoError(t, err)
+		assert.Equal(t, ""+
+			"00000000  Map (0x07)\n"+
+			"00000001    size: 8 (varint, 1 bytes)\n"+
+			"00000002    pairs: 1 (varint, 1 bytes)\n"+
+			"00000003    key[0] String (0x04)\n"+
+			"00000004      length: 1 (varint, 1 bytes)\n"+
+			"00000005      data: \"a\"\n"+
+			"00000006    value[0] Array (0x06)\n"+
+			"00000007      items: 1 (varint, 1 bytes)\n"+
+			"00000008      [0] Scalar (0x01) value=1 (varint, 1 bytes)\n", out)
+	})
+
+	t.Run("structs", func(t *testing.T) {
+		resetRegistry()
+		RegisterMessage(SubStruct{})
+		b, err := Encode(SubStruct{A: "x"})
+		require.NoError(t, err)
+
+		out, err := Dump(bytes.NewReader(b))
+		require.NoError(t, err)
+		assert.Contains(t, out, `Struct (0x08) id="org.example.test/SubStruct"`)
+		assert.Contains(t, out, "field 0 A\n")
+
+		// Unregistered structs are still walked.
+		resetRegistry()
+		out, err = Dump(bytes.NewReader(append(b, b...)))
+		require.NoError(t, err)
+		assert.Contains(t, out, `id="org.example.test/SubStruct" (unregistered)`)
+		assert.Equal(t, 2, bytes.Count([]byte(out), []byte("field 0\n")))
+	})
+
+	t.Run("truncated data", func(t *testing.T) {
+		b := EncodeString("hello")
+		out, err := Dump(bytes.NewReader(b[:4]))
+		require.Error(t, err)
+		assert.Contains(t, out, "length: 5")
+	})
+
+	t.Run("oversized lengths", func(t *testing.T) {
+		b := append([]byte{byte(TypeBytes)}, encodeUint64(1<<62)...)
+		out, err := Dump(bytes.NewReader(append(b, 0x01)))
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.Contains(t, out, "length: 4611686018427387904")
+	})
+}
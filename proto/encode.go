@@ -5,7 +5,30 @@ import (
 	"reflect"
 )
 
+// EncodeOptions controls how values are encoded.
+type EncodeOptions struct {
+	// Canonical makes the encoding deterministic: encoding the same value
+	// twice yields the same bytes. See EncodeCanonical.
+	Canonical bool
+}
+
 func Encode(value any) ([]byte, error) {
+	return EncodeWithOptions(value, EncodeOptions{})
+}
+
+// EncodeCanonical encodes value in its canonical form, suitable for
+// content-addressing and signing. The canonical form differs from the
+// default encoding only in map ordering: map entries are sorted by the
+// bytewise order of their encoded keys, with ties (possible for interface
+// keys of distinct types encoding to the same bytes) broken by the encoded
+// values. Structs are canonical regardless of options: every tagged field is
+// always emitted, in ascending field index order, and negative zero floats
+// are encoded as zero.
+func EncodeCanonical(value any) ([]byte, error) {
+	return EncodeWithOptions(value, EncodeOptions{Canonical: true})
+}
+
+func EncodeWithOptions(value any, opts EncodeOptions) ([]byte, error) {
 	if value == nil {
 		return []byte{byte(TypeVoid)}, nil
 	}
@@ -26,7 +49,7 @@ func Encode(value any) ([]byte, error) {
 		if t.Elem().Kind() == reflect.Uint8 {
 			return EncodeBytes(value.([]uint8)), nil
 		}
-		return encodeArray(v)
+		return encodeArray(v, opts)
 	case reflect.String:
 		return EncodeString(value.(string)), nil
 	case reflect.Bool:
@@ -56,9 +79,9 @@ func Encode(value any) ([]byte, error) {
 	case reflect.Float64:
 		return encodeFloat64(v.Float()), nil
 	case reflect.Interface, reflect.Struct:
-		return encodeStruct(v)
+		return encodeStruct(v, opts)
 	case reflect.Map:
-		return encodeMap(v)
+		return encodeMap(v, opts)
 
 	default:
 		return nil, fmt.Errorf("cannot Encode value of type %s", t.Kind())
@@ -0,0 +1,52 @@
+package proto
+
+import (
+	"fmt"
+	"reflect"
+)
+
+type Limit int
+
+const (
+	LimitMessageSize Limit = iota
+	LimitStringLength
+	LimitBytesLength
+	LimitCollectionLength
+	LimitDepth
+)
+
+var limitNames = map[Limit]string{
+	LimitMessageSize:      "message size",
+	LimitStringLength:     "string length",
+	LimitBytesLength:      "bytes length",
+	LimitCollectionLength: "collection length",
+	LimitDepth:            "nesting depth",
+}
+
+func (l Limit) String() string {
+	if v, ok := limitNames[l]; ok {
+		return v
+	}
+	return "unknown limit"
+}
+
+type LimitExceededError struct {
+	Limit    Limit
+	Max      uint64
+	Received uint64
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("decode: %s of %d exceeds limit of %d", e.Limit, e.Received, e.Max)
+}
+
+// ConversionError indicates a decoded value cannot be converted into the
+// type requested through Convert. From is nil for nil values.
+type ConversionError struct {
+	From reflect.Type
+	To   reflect.Type
+}
+
+func (e *ConversionError) Error() string {
+	return fmt.Sprintf("cannot convert value of type %v into %v", e.From, e.To)
+}
@@ -0,0 +1,451 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// jsonTypeKey is the key holding the struct ID of structs rendered as JSON
+// objects.
+const jsonTypeKey = "@type"
+
+// ToJSON renders value as JSON. Structs are rendered as objects keyed by
+// their field names (or the name in a `json` tag, when present), along with
+// an "@type" key holding their struct ID. Byte slices are represented as
+// base64 strings, integers keep their sign and exact digits, and float32
+// values are formatted using 32-bit precision. Maps must be keyed by strings
+// or integers. Values obtained from DecodeAny are also accepted.
+func ToJSON(value any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := writeJSON(buf, reflect.ValueOf(value)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJSON(buf *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		buf.WriteString("null")
+		return nil
+	}
+
+	if v.Type() == reflectedMapValue {
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		m := v.Interface().(*encodedMap)
+		keys := make([]reflect.Value, len(m.keys))
+		values := make([]reflect.Value, len(m.values))
+		for i := range m.keys {
+			keys[i] = reflect.ValueOf(m.keys[i])
+			values[i] = reflect.ValueOf(m.values[i])
+		}
+		return writeJSONObject(buf, keys, values)
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		return writeJSON(buf, v.Elem())
+	case reflect.Bool:
+		buf.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buf.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		buf.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("cannot represent %v as JSON", f)
+		}
+		bits := 64
+		if v.Kind() == reflect.Float32 {
+			bits = 32
+		}
+		buf.WriteString(strconv.FormatFloat(f, 'g', -1, bits))
+	case reflect.String:
+		writeJSONString(buf, v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if v.Kind() == reflect.Slice && v.IsNil() {
+				buf.WriteString("null")
+				return nil
+			}
+			writeJSONString(buf, base64.StdEncoding.EncodeToString(v.Bytes()))
+			return nil
+		}
+		buf.WriteByte('[')
+		for i := range v.Len() {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case reflect.Map:
+		keys := v.MapKeys()
+		values := make([]reflect.Value, len(keys))
+		for i, k := range keys {
+			values[i] = v.MapIndex(k)
+		}
+		return writeJSONObject(buf, keys, values)
+	case reflect.Struct:
+		return writeJSONStruct(buf, v)
+	default:
+		return fmt.Errorf("cannot represent value of type %s as JSON", v.Type())
+	}
+
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	// Marshalling a string never fails.
+	data, _ := json.Marshal(s)
+	buf.Write(data)
+}
+
+func jsonMapKey(k reflect.Value) (string, error) {
+	for k.Kind() == reflect.Interface || k.Kind() == reflect.Pointer {
+		k = k.Elem()
+	}
+	switch k.Kind() {
+	case reflect.String:
+		return k.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	default:
+		return "", fmt.Errorf("cannot use %s as a JSON object key", k.Kind())
+	}
+}
+
+func writeJSONObject(buf *bytes.Buffer, keys, values []reflect.Value) error {
+	type entry struct {
+		key   string
+		value reflect.Value
+	}
+	entries := make([]entry, len(keys))
+	for i, k := range keys {
+		key, err := jsonMapKey(k)
+		if err != nil {
+			return err
+		}
+		entries[i] = entry{key, values[i]}
+	}
+	slices.SortFunc(entries, func(a, b entry) int { return strings.Compare(a.key, b.key) })
+
+	buf.WriteByte('{')
+	for i, e := range entries {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONString(buf, e.key)
+		buf.WriteByte(':')
+		if err := writeJSON(buf, e.value); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func jsonFieldName(f reflect.StructField) string {
+	if tag, ok := f.Tag.Lookup("json"); ok {
+		name, _, _ := strings.Cut(tag, ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+func writeJSONStruct(buf *bytes.Buffer, v reflect.Value) error {
+	fields, err := encodableFieldsFromType(v.Type())
+	if err != nil {
+		return err
+	}
+
+	buf.WriteByte('{')
+	writeJSONString(buf, jsonTypeKey)
+	buf.WriteByte(':')
+	writeJSONString(buf, v.Interface().(Struct).ArfStructID())
+	for _, f := range fields {
+		buf.WriteByte(',')
+		writeJSONString(buf, jsonFieldName(f.field))
+		buf.WriteByte(':')
+		if err = writeJSON(buf, v.FieldByIndex(f.field.Index)); err != nil {
+			return fmt.Errorf("%s.%s: %w", v.Type().Name(), f.field.Name, err)
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// FromJSON parses data into a value. When structID is not empty, data must
+// hold an object which is parsed into a new instance of the registered
+// struct with that ID, returned as a pointer, as done by DecodeAny. Otherwise
+// data is parsed into untyped values: objects holding an "@type" key become
+// instances of the registered struct it names, other objects become
+// map[string]any, and numbers become int64, uint64 or float64 depending on
+// their representation.
+func FromJSON(data []byte, structID string) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var raw any
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+
+	if structID == "" {
+		return jsonToAny(raw)
+	}
+
+	t, ok := reg.structs[structID]
+	if !ok {
+		return nil, fmt.Errorf("unknown message kind %s", structID)
+	}
+	res := reflect.New(t.structType)
+	if err := jsonInto(res.Elem(), raw); err != nil {
+		return nil, err
+	}
+	return res.Interface(), nil
+}
+
+func jsonToAny(raw any) (any, error) {
+	switch v := raw.(type) {
+	case nil, bool, string:
+		return v, nil
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return u, nil
+		}
+		return v.Float64()
+	case []any:
+		arr := make([]any, len(v))
+		for i, item := range v {
+			var err error
+			if arr[i], err = jsonToAny(item); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	case map[string]any:
+		if id, ok := v[jsonTypeKey].(string); ok {
+			t, ok := reg.structs[id]
+			if !ok {
+				return nil, fmt.Errorf("unknown message kind %s", id)
+			}
+			res := reflect.New(t.structType)
+			if err := jsonInto(res.Elem(), v); err != nil {
+				return nil, err
+			}
+			return res.Interface(), nil
+		}
+		m := make(map[string]any, len(v))
+		for k, item := range v {
+			var err error
+			if m[k], err = jsonToAny(item); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unexpected JSON value %v", raw)
+	}
+}
+
+func jsonMismatch(raw any, t reflect.Type) error {
+	return fmt.Errorf("cannot parse JSON value %v into %s", raw, t)
+}
+
+func jsonInto(into reflect.Value, raw any) error {
+	t := into.Type()
+
+	if raw == nil {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+			into.Set(reflect.Zero(t))
+			return nil
+		default:
+			return jsonMismatch(raw, t)
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		ptr := reflect.New(t.Elem())
+		if err := jsonInto(ptr.Elem(), raw); err != nil {
+			return err
+		}
+		into.Set(ptr)
+
+	case reflect.Interface:
+		v, err := jsonToAny(raw)
+		if err != nil {
+			return err
+		}
+		rv := reflect.ValueOf(v)
+		if !rv.Type().AssignableTo(t) {
+			return jsonMismatch(raw, t)
+		}
+		into.Set(rv)
+
+	case reflect.Bool:
+		b, ok := raw.(bool)
+		if !ok {
+			return jsonMismatch(raw, t)
+		}
+		into.SetBool(b)
+
+	case reflect.String:
+		s, ok := raw.(string)
+		if !ok {
+			return jsonMismatch(raw, t)
+		}
+		into.SetString(s)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := raw.(json.Number)
+		if !ok {
+			return jsonMismatch(raw, t)
+		}
+		i, err := strconv.ParseInt(string(n), 10, t.Bits())
+		if err != nil {
+			return jsonMismatch(raw, t)
+		}
+		into.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := raw.(json.Number)
+		if !ok {
+			return jsonMismatch(raw, t)
+		}
+		u, err := strconv.ParseUint(string(n), 10, t.Bits())
+		if err != nil {
+			return jsonMismatch(raw, t)
+		}
+		into.SetUint(u)
+
+	case reflect.Float32, reflect.Float64:
+		n, ok := raw.(json.Number)
+		if !ok {
+			return jsonMismatch(raw, t)
+		}
+		f, err := strconv.ParseFloat(string(n), t.Bits())
+		if err != nil {
+			return jsonMismatch(raw, t)
+		}
+		into.SetFloat(f)
+
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			s, ok := raw.(string)
+			if !ok {
+				return jsonMismatch(raw, t)
+			}
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return fmt.Errorf("cannot parse bytes: %w", err)
+			}
+			into.Set(reflect.ValueOf(b).Convert(t))
+			return nil
+		}
+		arr, ok := raw.([]any)
+		if !ok {
+			return jsonMismatch(raw, t)
+		}
+		slice := reflect.MakeSlice(t, len(arr), len(arr))
+		for i, item := range arr {
+			if err := jsonInto(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		into.Set(slice)
+
+	case reflect.Map:
+		obj, ok := raw.(map[string]any)
+		if !ok {
+			return jsonMismatch(raw, t)
+		}
+		m := reflect.MakeMapWithSize(t, len(obj))
+		for k, item := range obj {
+			key := reflect.New(t.Key()).Elem()
+			if t.Key().Kind() == reflect.String {
+				key.SetString(k)
+			} else if err := jsonInto(key, json.Number(k)); err != nil {
+				return err
+			}
+			value := reflect.New(t.Elem()).Elem()
+			if err := jsonInto(value, item); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, value)
+		}
+		into.Set(m)
+
+	case reflect.Struct:
+		obj, ok := raw.(map[string]any)
+		if !ok {
+			return jsonMismatch(raw, t)
+		}
+		return jsonIntoStruct(into, obj)
+
+	default:
+		return jsonMismatch(raw, t)
+	}
+
+	return nil
+}
+
+func jsonIntoStruct(into reflect.Value, obj map[string]any) error {
+	t := into.Type()
+	fields, err := encodableFieldsFromType(t)
+	if err != nil {
+		return err
+	}
+
+	structID := into.Interface().(Struct).ArfStructID()
+	if id, ok := obj[jsonTypeKey]; ok && id != structID {
+		return fmt.Errorf("cannot parse %v into %s", id, structID)
+	}
+
+	byName := make(map[string]encodableField, len(fields))
+	for _, f := range fields {
+		byName[jsonFieldName(f.field)] = f
+	}
+
+	for k, v := range obj {
+		if k == jsonTypeKey {
+			continue
+		}
+		f, ok := byName[k]
+		if !ok {
+			return fmt.Errorf("unknown field %q for %s", k, structID)
+		}
+		if err = jsonInto(into.FieldByIndex(f.field.Index), v); err != nil {
+			return fmt.Errorf("%s.%s: %w", t.Name(), f.field.Name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package proto
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+type JSONStruct struct {
+	Signed   int8              `arf:"0"`
+	Unsigned uint64            `arf:"1"`
+	Single   float32           `arf:"2"`
+	Double   float64           `arf:"3"`
+	Data     []byte            `arf:"4"`
+	Labels   map[string]string `arf:"5"`
+	Counts   map[uint16]bool   `arf:"6"`
+	Sub      *SubStruct        `arf:"7"`
+	Subs     []SubStruct       `arf:"8"`
+	Renamed  string            `arf:"9" json:"renamed_field"`
+	Opt      *string           `arf:"10"`
+}
+
+func (JSONStruct) ArfStructID() string { return "org.example.test/JSONStruct" }
+
+func TestJSON(t *testing.T) {
+	resetRegistry()
+	RegisterMessage(JSONStruct{})
+	RegisterMessage(SubStruct{})
+
+	value := &JSONStruct{
+		Signed:   -12,
+		Unsigned: math.MaxUint64,
+		Single:   0.1,
+		Double:   0.1,
+		Data:     []byte{0x01, 0x02, 0x03},
+		Labels:   map[string]string{"b": "2", "a": "1"},
+		Counts:   map[uint16]bool{10: true},
+		Sub:      &SubStruct{A: "sub"},
+		Subs:     []SubStruct{{A: "first"}},
+		Renamed:  "renamed",
+	}
+
+	t.Run("ToJSON", func(t *testing.T) {
+		data, err := ToJSON(value)
+		require.NoError(t, err)
+		assert.Equal(t, `{"@type":"org.example.test/JSONStruct","Signed":-12,"Unsigned":18446744073709551615,`+
+			`"Single":0.1,"Double":0.1,"Data":"AQID","Labels":{"a":"1","b":"2"},"Counts":{"10":true},`+
+			`"Sub":{"@type":"org.example.test/SubStruct","A":"sub"},"Subs":[{"@type":"org.example.test/SubStruct","A":"first"}],`+
+			`"renamed_field":"renamed","Opt":null}`, string(data))
+	})
+
+	t.Run("FromJSON", func(t *testing.T) {
+		data, err := ToJSON(value)
+		require.NoError(t, err)
+		parsed, err := FromJSON(data, "org.example.test/JSONStruct")
+		require.NoError(t, err)
+		assert.Equal(t, value, parsed)
+	})
+
+	t.Run("ToJSON decoded values", func(t *testing.T) {
+		b, err := Encode(value)
+		require.NoError(t, err)
+		decoded, err := DecodeAny(bytes.NewReader(b))
+		require.NoError(t, err)
+		data, err := ToJSON([]any{decoded, map[string]any{"k": uint64(1)}})
+		require.NoError(t, err)
+		parsed, err := FromJSON(data, "")
+		require.NoError(t, err)
+		arr := parsed.([]any)
+		require.Len(t, arr, 2)
+		assert.Equal(t, "sub", arr[0].(*JSONStruct).Sub.A)
+		assert.Equal(t, int8(-12), arr[0].(*JSONStruct).Signed)
+		assert.Equal(t, map[string]any{"k": int64(1)}, arr[1])
+	})
+
+	t.Run("FromJSON untyped numbers", func(t *testing.T) {
+		parsed, err := FromJSON([]byte(`[-1, 18446744073709551615, 1.5]`), "")
+		require.NoError(t, err)
+		assert.Equal(t, []any{int64(-1), uint64(math.MaxUint64), 1.5}, parsed)
+	})
+
+	t.Run("FromJSON rejects mismatches", func(t *testing.T) {
+		_, err := FromJSON([]byte(`{"Signed": 200}`), "org.example.test/JSONStruct")
+		assert.Error(t, err)
+		_, err = FromJSON([]byte(`{"Unsigned": -1}`), "org.example.test/JSONStruct")
+		assert.Error(t, err)
+		_, err = FromJSON([]byte(`{"Missing": 1}`), "org.example.test/JSONStruct")
+		assert.Error(t, err)
+		_, err = FromJSON([]byte(`{"@type": "org.example.test/SubStruct"}`), "org.example.test/JSONStruct")
+		assert.Error(t, err)
+		_, err = FromJSON([]byte(`{}`), "org.example.test/Unknown")
+		assert.Error(t, err)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"io"
 	"reflect"
+	"slices"
 )
 
 const emptyMapMask = 0x01 << 4
@@ -14,26 +15,42 @@ type encodedMap struct {
 
 var reflectedMapValue = reflect.TypeOf(&encodedMap{})
 
-func encodeMap(v reflect.Value) ([]byte, error) {
+type encodedPair struct {
+	key, value []byte
+}
+
+func encodeMap(v reflect.Value, opts EncodeOptions) ([]byte, error) {
 	pairsLen := v.Len()
 	if pairsLen == 0 {
 		return []byte{byte(TypeMap) | emptyMapMask}, nil
 	}
 
-	var keys, values []byte
-
+	pairs := make([]encodedPair, 0, pairsLen)
 	for _, k := range v.MapKeys() {
-		key, err := Encode(k.Interface())
+		key, err := EncodeWithOptions(k.Interface(), opts)
 		if err != nil {
 			return nil, err
 		}
-		value, err := Encode(v.MapIndex(k).Interface())
+		value, err := EncodeWithOptions(v.MapIndex(k).Interface(), opts)
 		if err != nil {
 			return nil, err
 		}
+		pairs = append(pairs, encodedPair{key, value})
+	}
+
+	if opts.Canonical {
+		slices.SortFunc(pairs, func(a, b encodedPair) int {
+			if c := bytes.Compare(a.key, b.key); c != 0 {
+				return c
+			}
+			return bytes.Compare(a.value, b.value)
+		})
+	}
 
-		keys = append(keys, key...)
-		values = append(values, value...)
+	var keys, values []byte
+	for _, p := range pairs {
+		keys = append(keys, p.key...)
+		values = append(values, p.value...)
 	}
 
 	encodedPairsLen := encodeUint64(uint64(pairsLen))
@@ -52,14 +69,20 @@ func decodeMap(header byte, r io.Reader) (*encodedMap, error) {
 		return &encodedMap{}, nil
 	}
 
-	_, err := decodeUint64(r)
+	size, err := decodeUint64(r)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkLength(r, LimitMessageSize, size); err != nil {
+		return nil, err
+	}
 	pairsLen, err := decodeUint64(r)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkLength(r, LimitCollectionLength, pairsLen); err != nil {
+		return nil, err
+	}
 
 	keys := make([]any, pairsLen)
 	values := make([]any, pairsLen)
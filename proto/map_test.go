@@ -37,4 +37,35 @@ func TestMap(t *testing.T) {
 		require.NoError(t, err)
 		require.NotNil(t, decoded)
 	})
+	t.Run("Encode canonical", func(t *testing.T) {
+		resetRegistry()
+		RegisterMessage(SubStruct{})
+
+		first, err := EncodeCanonical(theComplexMap)
+		require.NoError(t, err)
+		for range 32 {
+			again, err := EncodeCanonical(theComplexMap)
+			require.NoError(t, err)
+			require.Equal(t, first, again)
+		}
+
+		encoded, err := EncodeCanonical(map[string]uint16{"b": 2, "a": 1})
+		require.NoError(t, err)
+		require.Equal(t, []byte{
+			0x07, 0x0b, 0x02, // map, payload size, pairs
+			0x04, 0x01, 'a', 0x04, 0x01, 'b', // keys
+			0x01, 0x01, 0x01, 0x02, // values
+		}, encoded)
+	})
+
+	t.Run("Encode canonical nested", func(t *testing.T) {
+		value := []map[uint16]string{{3: "c", 1: "a", 2: "b"}}
+		first, err := EncodeCanonical(value)
+		require.NoError(t, err)
+		for range 32 {
+			again, err := EncodeWithOptions(value, EncodeOptions{Canonical: true})
+			require.NoError(t, err)
+			require.Equal(t, first, again)
+		}
+	})
 }
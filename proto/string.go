@@ -34,8 +34,12 @@ func decodeString(header byte, b io.Reader) (string, error) {
 		return "", err
 	}
 
+	if err = checkLength(b, LimitStringLength, v); err != nil {
+		return "", err
+	}
+
 	strBytes := make([]byte, int(v))
-	if _, err := b.Read(strBytes); err != nil {
+	if _, err := io.ReadFull(b, strBytes); err != nil {
 		return "", err
 	}
 
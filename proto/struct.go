@@ -5,6 +5,7 @@ import (
 	"cmp"
 	"fmt"
 	"io"
+	"math"
 	"reflect"
 	"slices"
 	"strconv"
@@ -85,7 +86,7 @@ func fieldsFromUnionStruct(f reflect.Type) (encodableFieldSet, error) {
 
 }
 
-func encodeStruct(v reflect.Value) ([]byte, error) {
+func encodeStruct(v reflect.Value, opts EncodeOptions) ([]byte, error) {
 	if v.Type().Kind() == reflect.Ptr {
 		v = v.Elem()
 	}
@@ -104,7 +105,7 @@ func encodeStruct(v reflect.Value) ([]byte, error) {
 	var data [][]byte
 	for _, f := range fields {
 		data = append(data, encodeUint64(uint64(f.index)))
-		buf, err := Encode(v.FieldByIndex(f.field.Index).Interface())
+		buf, err := EncodeWithOptions(v.FieldByIndex(f.field.Index).Interface(), opts)
 		if err != nil {
 			return nil, err
 		}
@@ -126,24 +127,27 @@ type decodedStruct struct {
 	fields map[int]any
 }
 
-func decodeStruct(r io.Reader) (any, error) {
-	t, b, err := readType(r)
+func decodeStruct(d *Decoder) (any, error) {
+	t, b, err := readType(d)
 	if err != nil {
 		return nil, err
 	}
 	if t != TypeString {
 		return nil, fmt.Errorf("cannot decode struct: expected string, found %s instead", t.String())
 	}
-	id, err := decodeString(b, r)
+	id, err := decodeString(b, d)
 	if err != nil {
 		return nil, err
 	}
-	bytesLen, err := decodeUint64(r)
+	bytesLen, err := decodeUint64(d)
 	if err != nil {
 		return nil, err
 	}
+	if err = d.checkLength(LimitMessageSize, 0, bytesLen); err != nil {
+		return nil, err
+	}
 
-	reader := io.LimitReader(r, int64(bytesLen))
+	reader := d.sub(bytesLen)
 	fields := map[int]any{}
 	for {
 		i, err := decodeUint64(reader)
@@ -196,7 +200,9 @@ func setStructField(into reflect.Value, fd reflect.StructField, value reflect.Va
 	}
 }
 
-func setValue(into reflect.Value, fd reflect.StructField, value interface{}) {
+// setValue sets the field fd of into to value, converting it as needed.
+// Returns false in case value cannot be converted into the field's type.
+func setValue(into reflect.Value, fd reflect.StructField, value interface{}) bool {
 	var rv reflect.Value
 	if v, ok := value.(reflect.Value); ok {
 		rv = v
@@ -243,21 +249,28 @@ func setValue(into reflect.Value, fd reflect.StructField, value interface{}) {
 		//         #YmbdB##EMQGW&N6Nx
 		//          *N&E&WB08NNH#6r6
 		//               ^  ~~""^
+		return true
+
+	case !rv.IsValid():
+		return false
 
 	case fd.Type.Kind() != reflect.Pointer &&
 		rv.Type().Kind() == reflect.Pointer &&
-		rv.Elem().Type().ConvertibleTo(fd.Type):
+		canConvert(rv.Elem(), fd.Type):
 		setStructField(into, fd, rv.Elem().Convert(fd.Type))
+		return true
 
 	case fd.Type.Kind() == reflect.Pointer &&
 		rv.Type().Kind() != reflect.Pointer &&
-		rv.Type().ConvertibleTo(fd.Type.Elem()):
+		canConvert(rv, fd.Type.Elem()):
 		ptrVal := reflect.New(fd.Type.Elem())
 		ptrVal.Elem().Set(rv.Convert(fd.Type.Elem()))
 		setStructField(into, fd, ptrVal)
+		return true
 
-	case rv.Type().ConvertibleTo(fd.Type):
+	case canConvert(rv, fd.Type):
 		setStructField(into, fd, rv.Convert(fd.Type))
+		return true
 
 	case rv.Type().Kind() == reflect.Slice && fd.Type.Kind() == reflect.Slice:
 		// rv is []interface, f is specialised. Check if rv[i] can be
@@ -266,18 +279,18 @@ func setValue(into reflect.Value, fd reflect.StructField, value interface{}) {
 		ft := fd.Type.Elem()
 		mustConvertPtrs := false
 		if rv.Len() > 0 {
-			mustConvertPtrs = rv.Index(0).Elem().Type().Kind() == reflect.Pointer
+			mustConvertPtrs = rv.Index(0).Elem().Kind() == reflect.Pointer
 		}
 		if !mustConvertPtrs {
 			for i := 0; i < rv.Len(); i++ {
-				if !rv.Index(i).Elem().Type().ConvertibleTo(ft) {
-					return
+				if !canConvert(rv.Index(i).Elem(), ft) {
+					return false
 				}
 			}
 		} else {
 			for i := 0; i < rv.Len(); i++ {
-				if !rv.Index(i).Elem().Elem().Type().ConvertibleTo(ft) {
-					return
+				if !canConvert(rv.Index(i).Elem().Elem(), ft) {
+					return false
 				}
 			}
 		}
@@ -297,9 +310,11 @@ func setValue(into reflect.Value, fd reflect.StructField, value interface{}) {
 			}
 		}
 		setStructField(into, fd, slice)
+		return true
 
-	case fd.Type.Kind() == rv.Type().Kind():
+	case fd.Type.Kind() == rv.Type().Kind() && rv.Type().AssignableTo(fd.Type):
 		setStructField(into, fd, rv)
+		return true
 
 	case rv.Type().Kind() == reflect.Pointer &&
 		fd.Type.Kind() == reflect.Map &&
@@ -307,9 +322,10 @@ func setValue(into reflect.Value, fd reflect.StructField, value interface{}) {
 		mv := rv.Interface().(*encodedMap)
 		ok, mi := makeMap(mv, fd.Type)
 		if !ok {
-			return
+			return false
 		}
 		setStructField(into, fd, mi)
+		return true
 
 	case rv.Type().Kind() == reflect.Pointer &&
 		rv.Type().Elem().Kind() == reflect.Struct &&
@@ -317,21 +333,70 @@ func setValue(into reflect.Value, fd reflect.StructField, value interface{}) {
 		fd.Type.Kind() == reflect.Struct:
 		// rv is a pointer to struct coming from Decode, but we want a concrete
 		// value.
-		setValue(into, fd, rv.Elem())
+		return setValue(into, fd, rv.Elem())
 
 	default:
-		return
+		return false
+	}
+}
+
+// canConvert reports whether from can be converted into to without altering
+// its value. Integers must be representable by to, and are never converted
+// from or into strings.
+func canConvert(from reflect.Value, to reflect.Type) bool {
+	if !from.IsValid() || !from.Type().ConvertibleTo(to) {
+		return false
+	}
+
+	switch {
+	case isInteger(from.Kind()) && to.Kind() == reflect.String,
+		from.Kind() == reflect.String && isInteger(to.Kind()):
+		return false
+	case isInteger(from.Kind()) && isInteger(to.Kind()):
+		return fitsInteger(from, to)
+	case from.Kind() == reflect.Slice && to.Kind() == reflect.Array:
+		// Converting a slice shorter than the array panics.
+		return from.Len() >= to.Len()
+	}
+	return true
+}
+
+func isInteger(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+// fitsInteger reports whether the integer v can be represented by the
+// integer type to.
+func fitsInteger(v reflect.Value, to reflect.Type) bool {
+	zero := reflect.Zero(to)
+	if v.CanInt() {
+		n := v.Int()
+		if zero.CanInt() {
+			return !zero.OverflowInt(n)
+		}
+		return n >= 0 && !zero.OverflowUint(uint64(n))
+	}
+
+	n := v.Uint()
+	if zero.CanInt() {
+		return n <= math.MaxInt64 && !zero.OverflowInt(int64(n))
 	}
+	return !zero.OverflowUint(n)
 }
 
-func isConvertible(from, to reflect.Type) bool {
-	if from.ConvertibleTo(to) {
+func isConvertible(from reflect.Value, to reflect.Type) bool {
+	if canConvert(from, to) {
 		return true
 	}
 
-	if from.Kind() == reflect.Ptr && from.Elem().ConvertibleTo(to) {
+	if from.Kind() == reflect.Ptr && canConvert(from.Elem(), to) {
 		return true
-	} else if to.Kind() == reflect.Ptr && from.ConvertibleTo(to.Elem()) {
+	} else if to.Kind() == reflect.Ptr && canConvert(from, to.Elem()) {
 		return true
 	}
 
@@ -356,9 +421,9 @@ func convertTo(from reflect.Value, to reflect.Type) reflect.Value {
 		return from.Convert(to)
 	}
 	if from.Type().Kind() == reflect.Ptr && to.Kind() != reflect.Ptr {
-		return from.Elem()
+		return from.Elem().Convert(to)
 	} else if from.Type().Kind() != reflect.Ptr && to.Kind() == reflect.Ptr {
-		return pointerTo(from)
+		return pointerTo(from.Convert(to.Elem()))
 	}
 	return from
 }
@@ -369,13 +434,13 @@ func makeMap(v *encodedMap, mapType reflect.Type) (bool, reflect.Value) {
 	var mi reflect.Value
 	if v != nil {
 		for _, v := range v.keys {
-			if !isConvertible(reflect.TypeOf(v), mapKeyType) {
+			if !isConvertible(reflect.ValueOf(v), mapKeyType) {
 				return false, reflect.Value{}
 			}
 		}
 
 		for _, v := range v.values {
-			if !isConvertible(reflect.TypeOf(v), mapValueType) {
+			if !isConvertible(reflect.ValueOf(v), mapValueType) {
 				return false, reflect.Value{}
 			}
 		}
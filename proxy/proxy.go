@@ -0,0 +1,246 @@
+// Package proxy implements a transparent arf proxy. Streams accepted from
+// clients are routed to backends by service ID, decoding only the service and
+// method of each request; all remaining data is piped between both parties
+// without being decoded, preserving how it was framed.
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"github.com/arf-rpc/arf-go/rpc"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/arf-rpc/arf-go/wire"
+	"github.com/go-stdlog/stdlog"
+	"io"
+	"net"
+	"sync"
+)
+
+// Director returns the backend that must handle calls to the given service
+// and method. ok must be false in case no backend is able to handle it.
+type Director func(service, method string) (backend wire.Client, ok bool)
+
+type Option func(*Proxy)
+
+// WithDirector replaces the routing table populated through Proxy.Route.
+func WithDirector(d Director) Option {
+	return func(p *Proxy) {
+		p.director = d
+	}
+}
+
+func WithLogger(l stdlog.Logger) Option {
+	return func(p *Proxy) {
+		p.logger = l
+	}
+}
+
+// WithWireOptions sets options applied to connections accepted from clients.
+func WithWireOptions(opts ...wire.Option) Option {
+	return func(p *Proxy) {
+		p.wireOptions = append(p.wireOptions, opts...)
+	}
+}
+
+// Proxy accepts arf connections and forwards each stream to a backend
+// wire.Client. Resets are forwarded in both directions, preserving their
+// error codes. A GOAWAY received from a backend resets its streams with the
+// backend's error code, and a client going away resets its streams on the
+// backends. The backend's GOAWAY itself is not forwarded, as client
+// connections may carry streams handled by other backends.
+type Proxy struct {
+	server      *wire.Server
+	wireOptions []wire.Option
+	logger      stdlog.Logger
+	director    Director
+
+	backendsMu sync.RWMutex
+	backends   map[string]wire.Client
+
+	streamsMu sync.Mutex
+	streams   map[wire.Stream]wire.Stream
+}
+
+func New(l net.Listener, opts ...Option) *Proxy {
+	p := &Proxy{
+		logger:   stdlog.Discard,
+		backends: make(map[string]wire.Client),
+		streams:  make(map[wire.Stream]wire.Stream),
+	}
+	p.director = p.lookup
+	for _, fn := range opts {
+		fn(p)
+	}
+	p.server = wire.NewServer(l, p, p.wireOptions...)
+	return p
+}
+
+// Route directs calls to serviceID to backend. The backend must already be
+// configured. Backends are owned by the caller, and are not closed by
+// Shutdown.
+func (p *Proxy) Route(serviceID string, backend wire.Client) {
+	p.backendsMu.Lock()
+	defer p.backendsMu.Unlock()
+	p.backends[serviceID] = backend
+}
+
+func (p *Proxy) lookup(service, _ string) (wire.Client, bool) {
+	p.backendsMu.RLock()
+	defer p.backendsMu.RUnlock()
+	b, ok := p.backends[service]
+	return b, ok
+}
+
+func (p *Proxy) Serve() error {
+	return p.server.Serve()
+}
+
+// ServeConn services a client connection obtained elsewhere than the
+// proxy's listener.
+func (p *Proxy) ServeConn(conn io.ReadWriteCloser) {
+	p.server.ServeConn(conn)
+}
+
+// Shutdown stops accepting connections and sends a GOAWAY to connected
+// clients. Streams still in flight are reset on their backends.
+func (p *Proxy) Shutdown() error {
+	return p.server.Shutdown()
+}
+
+func (p *Proxy) ServiceStream(in wire.Stream) {
+	request, err := readMessage(in)
+	if err != nil {
+		p.logger.Error(err, "Failed reading request")
+		_ = in.Reset(resetCode(err))
+		return
+	}
+	service, method, err := rpc.RequestHeaderFromReader(bytes.NewReader(request))
+	if err != nil {
+		p.logger.Error(err, "Failed reading request header")
+		_ = in.Reset(resetCode(err))
+		return
+	}
+	log := p.logger.WithFields("service", service, "method", method)
+
+	backend, ok := p.director(service, method)
+	if !ok {
+		log.Info("Rejecting request as no backend handles the requested service")
+		reject(in, status.Unimplemented, "no backend for service "+service)
+		return
+	}
+
+	out, err := backend.NewStream()
+	if err != nil {
+		log.Error(err, "Failed opening stream on backend")
+		reject(in, status.Unavailable, err.Error())
+		return
+	}
+
+	p.streamsMu.Lock()
+	p.streams[in] = out
+	p.streamsMu.Unlock()
+	defer func() {
+		p.streamsMu.Lock()
+		delete(p.streams, in)
+		p.streamsMu.Unlock()
+	}()
+
+	if err = out.Write(request, false); err != nil {
+		log.Error(err, "Failed forwarding request to backend")
+		_ = out.Reset(wire.ErrorCodeCancel)
+		reject(in, status.Unavailable, err.Error())
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pipe(in, out)
+	}()
+	pipe(out, in)
+	<-done
+}
+
+// CancelStream is invoked when a client resets a stream, or goes away,
+// after the proxy may have stopped reading from it.
+func (p *Proxy) CancelStream(in wire.Stream) {
+	p.streamsMu.Lock()
+	out, ok := p.streams[in]
+	p.streamsMu.Unlock()
+	if ok {
+		_ = out.Reset(resetCode(in.Err()))
+	}
+}
+
+// pipe copies data read from src into dst until src is closed, resetting
+// dst in case src is reset, and src in case writing to dst fails. Data is
+// forwarded as it was framed by the sender, preserving message boundaries.
+func pipe(dst, src wire.Stream) {
+	for {
+		data, endData, err := src.ReadBlock()
+		if len(data) > 0 {
+			if wErr := dst.WriteBlock(data, endData); wErr != nil {
+				_ = src.Reset(resetCode(wErr))
+				return
+			}
+		}
+		switch {
+		case err == nil:
+			continue
+		case errors.Is(err, io.EOF), errors.Is(err, wire.ClosedStreamErr):
+			_ = dst.CloseLocal()
+		default:
+			_ = dst.Reset(resetCode(err))
+		}
+		return
+	}
+}
+
+// readMessage reads data from in until a message is concluded.
+func readMessage(in wire.Stream) ([]byte, error) {
+	var msg []byte
+	for {
+		data, endData, err := in.ReadBlock()
+		if err != nil {
+			return nil, err
+		}
+		msg = append(msg, data...)
+		if endData {
+			return msg, nil
+		}
+	}
+}
+
+func resetCode(err error) wire.ErrorCode {
+	var resetErr *wire.StreamResetError
+	var sizeErr *wire.MessageTooLargeError
+	var connErr *wire.ConnectionResetError
+	switch {
+	case errors.As(err, &resetErr):
+		return resetErr.Reason
+	case errors.As(err, &sizeErr):
+		return wire.ErrorCodeEnhanceYourCalm
+	case errors.As(err, &connErr):
+		return connErr.Reason
+	case errors.As(err, new(*rpc.MessageKindMismatchError)):
+		return wire.ErrorCodeProtocolError
+	default:
+		return wire.ErrorCodeCancel
+	}
+}
+
+// reject responds to the client with the given status, as an arf server
+// would for a call it cannot handle.
+func reject(in wire.Stream, code status.Status, msg string) {
+	enc, err := (&rpc.Response{
+		Status:   uint16(code),
+		Metadata: rpc.MetadataFromStringPairs(rpc.StatusDescriptionKey, msg),
+	}).Wrap()
+	if err != nil {
+		_ = in.Reset(wire.ErrorCodeInternalError)
+		return
+	}
+	if err = in.Write(enc, true); err != nil {
+		_ = in.Reset(wire.ErrorCodeInternalError)
+	}
+}
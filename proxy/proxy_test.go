@@ -0,0 +1,199 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"github.com/arf-rpc/arf-go"
+	"github.com/arf-rpc/arf-go/rpc"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/arf-rpc/arf-go/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type backend struct {
+	server   arf.Server
+	client   wire.Client
+	started  chan struct{}
+	canceled chan error
+}
+
+func makeBackend(t *testing.T) *backend {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server, err := arf.NewServer(l, arf.ServerOptions{})
+	require.NoError(t, err)
+	go func() { _ = server.Serve() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	b := &backend{
+		server:   server,
+		started:  make(chan struct{}, 1),
+		canceled: make(chan error, 1),
+	}
+	server.MustRegisterService(arf.ServiceAdapter{
+		ServiceID: "org.example.test/Echo",
+		Methods: map[string]arf.ServiceExecutor{
+			"Echo": func(ctx context.Context, c arf.Context) error {
+				return c.SendResponse(status.OK, c.Request().Params, false, nil)
+			},
+			"Count": func(ctx context.Context, c arf.Context) error {
+				if err := c.SendResponse(status.OK, nil, true, nil); err != nil {
+					return err
+				}
+				for i := range c.Request().Params[0].(uint64) {
+					if err := c.Send(i); err != nil {
+						return err
+					}
+				}
+				return c.EndSend()
+			},
+			"Wait": func(ctx context.Context, c arf.Context) error {
+				b.started <- struct{}{}
+				<-ctx.Done()
+				b.canceled <- context.Cause(ctx)
+				return ctx.Err()
+			},
+		},
+	})
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	b.client = wire.NewClient(conn)
+	require.NoError(t, b.client.Configure(wire.CompressionMethodNone))
+	t.Cleanup(func() { _ = b.client.Close() })
+	return b
+}
+
+func makeProxy(t *testing.T, b *backend) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	p := New(l)
+	p.Route("org.example.test/Echo", b.client)
+	go func() { _ = p.Serve() }()
+	t.Cleanup(func() { _ = p.Shutdown() })
+	return l.Addr().String()
+}
+
+func dialWire(t *testing.T, addr string) wire.Client {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	c := wire.NewClient(conn)
+	require.NoError(t, c.Configure(wire.CompressionMethodNone))
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func waitSignal[T any](t *testing.T, ch chan T) T {
+	t.Helper()
+
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for signal")
+		panic("unreachable")
+	}
+}
+
+func TestProxy(t *testing.T) {
+	b := makeBackend(t)
+	addr := makeProxy(t, b)
+
+	client, err := arf.Dial(addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	t.Run("unary calls are forwarded", func(t *testing.T) {
+		call, err := client.Call(context.Background(), "org.example.test/Echo", "Echo",
+			arf.WithParams("hello", uint64(42)))
+		require.NoError(t, err)
+		result, err := call.Response().Result()
+		require.NoError(t, err)
+		assert.Equal(t, []any{"hello", uint64(42)}, result)
+	})
+
+	t.Run("messages spanning several frames are forwarded", func(t *testing.T) {
+		large := strings.Repeat("a", 200*1024)
+		call, err := client.Call(context.Background(), "org.example.test/Echo", "Echo",
+			arf.WithParams(large))
+		require.NoError(t, err)
+		result, err := call.Response().Result()
+		require.NoError(t, err)
+		assert.Equal(t, []any{large}, result)
+	})
+
+	t.Run("server streams are forwarded", func(t *testing.T) {
+		call, err := client.Call(context.Background(), "org.example.test/Echo", "Count",
+			arf.WithParams(uint64(3)))
+		require.NoError(t, err)
+		require.True(t, call.Response().Streaming)
+
+		var items []any
+		for {
+			v, err := call.Recv()
+			if errors.As(err, new(*rpc.StreamEndError)) {
+				break
+			}
+			require.NoError(t, err)
+			items = append(items, v)
+		}
+		assert.Equal(t, []any{uint64(0), uint64(1), uint64(2)}, items)
+	})
+
+	t.Run("unknown services are rejected", func(t *testing.T) {
+		call, err := client.Call(context.Background(), "org.example.test/Missing", "Echo")
+		require.NoError(t, err)
+		_, err = call.Response().Result()
+		var bad *status.BadStatus
+		require.ErrorAs(t, err, &bad)
+		assert.Equal(t, status.Unimplemented, bad.Code)
+	})
+
+	t.Run("client resets are forwarded to the backend", func(t *testing.T) {
+		c := dialWire(t, addr)
+		str, err := c.NewStream()
+		require.NoError(t, err)
+		req, err := (&rpc.Request{Service: "org.example.test/Echo", Method: "Wait"}).Wrap()
+		require.NoError(t, err)
+		require.NoError(t, str.Write(req, true))
+
+		waitSignal(t, b.started)
+		require.NoError(t, str.Reset(wire.ErrorCodeCancel))
+		assert.ErrorIs(t, waitSignal(t, b.canceled), arf.StreamCanceledErr)
+	})
+
+	t.Run("a backend going away resets its streams", func(t *testing.T) {
+		c := dialWire(t, addr)
+		str, err := c.NewStream()
+		require.NoError(t, err)
+		req, err := (&rpc.Request{Service: "org.example.test/Echo", Method: "Wait"}).Wrap()
+		require.NoError(t, err)
+		require.NoError(t, str.Write(req, true))
+
+		waitSignal(t, b.started)
+		require.NoError(t, b.server.Shutdown())
+
+		_, err = str.Read(make([]byte, 16))
+		var reset *wire.StreamResetError
+		require.ErrorAs(t, err, &reset)
+		assert.Equal(t, wire.ErrorCodeNoError, reset.Reason)
+
+		call, err := client.Call(context.Background(), "org.example.test/Echo", "Echo")
+		require.NoError(t, err)
+		_, err = call.Response().Result()
+		var bad *status.BadStatus
+		require.ErrorAs(t, err, &bad)
+		assert.Equal(t, status.Unavailable, bad.Code)
+	})
+}
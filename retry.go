@@ -0,0 +1,203 @@
+package arf
+
+import (
+	"context"
+	"github.com/arf-rpc/arf-go/rpc"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/arf-rpc/arf-go/wire"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RetryAttemptMetadataKey is set on the metadata of retried requests to the
+// amount of attempts made before them, allowing servers to observe retries.
+const RetryAttemptMetadataKey = "arf-retry-attempt"
+
+// RetryPolicy determines how calls failing with a transient status are
+// retried. Policies should only be configured for idempotent methods, as a
+// request may have been processed by the server even though it failed.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum amount of attempts, including the original
+	// call. Values below 2 disable retries.
+	MaxAttempts int
+	// Backoff controls delays between attempts.
+	Backoff Backoff
+	// RetryableCodes lists the statuses causing a call to be retried.
+	// Defaults to Unavailable.
+	RetryableCodes []status.Status
+}
+
+func (p *RetryPolicy) retryable(code status.Status) bool {
+	if len(p.RetryableCodes) == 0 {
+		return code == status.Unavailable
+	}
+	return slices.Contains(p.RetryableCodes, code)
+}
+
+type retryKey struct {
+	service, method string
+}
+
+// WithRetryPolicy retries calls to method of service according to policy.
+// An empty method applies policy to every method of service lacking a policy
+// of its own. Unary calls are retried when the call fails or the response
+// carries a retryable status; streaming calls are additionally retried when
+// receiving fails before any item was received and none was sent.
+func WithRetryPolicy(service, method string, policy RetryPolicy) ClientOption {
+	return func(c *client) {
+		if c.retryPolicies == nil {
+			c.retryPolicies = map[retryKey]RetryPolicy{}
+		}
+		c.retryPolicies[retryKey{service, method}] = policy
+	}
+}
+
+func (c *client) retryPolicy(service, method string) (RetryPolicy, bool) {
+	p, ok := c.retryPolicies[retryKey{service, method}]
+	if !ok {
+		p, ok = c.retryPolicies[retryKey{service, ""}]
+	}
+	return p, ok && p.MaxAttempts > 1
+}
+
+func withRetryAttempt(attempt int) CallOption {
+	return func(r *rpc.Request, o *callOptions) {
+		meta := slices.Clone(r.Metadata)
+		meta.SetString(RetryAttemptMetadataKey, strconv.Itoa(attempt))
+		r.Metadata = meta
+	}
+}
+
+type attemptFunc func(ctx context.Context, opts []CallOption) (Context, error)
+
+// retryingCtx is the Context of a call subject to a RetryPolicy. It replaces
+// the underlying call with a new attempt whenever it fails before anything
+// was exchanged through its streams.
+type retryingCtx struct {
+	Context
+	context  context.Context
+	policy   RetryPolicy
+	opts     []CallOption
+	attempt  attemptFunc
+	attempts int
+	received bool
+	sent     bool
+}
+
+func callWithRetries(ctx context.Context, policy RetryPolicy, opts []CallOption, attempt attemptFunc) (Context, error) {
+	r := &retryingCtx{
+		context: ctx,
+		policy:  policy,
+		opts:    opts,
+		attempt: attempt,
+	}
+	if err := r.start(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *retryingCtx) shouldRetry(code status.Status) bool {
+	return r.attempts < r.policy.MaxAttempts && r.policy.retryable(code)
+}
+
+func (r *retryingCtx) wait() error {
+	t := time.NewTimer(r.policy.Backoff.Delay(r.attempts - 1))
+	defer t.Stop()
+	select {
+	case <-r.context.Done():
+		return r.context.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// start makes attempts until one succeeds, fails with a status that must not
+// be retried, or attempts are exhausted. A response carrying an error status
+// is only returned once it cannot be retried.
+func (r *retryingCtx) start() error {
+	for {
+		opts := r.opts
+		if r.attempts > 0 {
+			opts = append(slices.Clone(r.opts), withRetryAttempt(r.attempts))
+		}
+		call, err := r.attempt(r.context, opts)
+		r.attempts++
+
+		if err == nil {
+			// The response of streaming requests is only read once the
+			// request stream was sent, so failures surface through Recv.
+			if call.Request().Streaming {
+				r.Context = call
+				return nil
+			}
+			code := status.Status(call.Response().Status)
+			if code == status.OK || !r.shouldRetry(code) {
+				r.Context = call
+				return nil
+			}
+		} else if !r.shouldRetry(status.Convert(err).Code) {
+			return err
+		}
+
+		if r.wait() != nil {
+			if err == nil {
+				r.Context = call
+			}
+			return err
+		}
+		if err == nil {
+			discard(call)
+		}
+	}
+}
+
+func (r *retryingCtx) Recv() (any, error) {
+	return r.RecvContext(r.context)
+}
+
+func (r *retryingCtx) RecvContext(ctx context.Context) (any, error) {
+	for {
+		v, err := r.Context.RecvContext(ctx)
+		if err == nil {
+			r.received = true
+			return v, nil
+		}
+		if r.received || r.sent || ctx.Err() != nil {
+			return nil, err
+		}
+		if !r.shouldRetry(status.Convert(err).Code) {
+			return nil, err
+		}
+		if r.wait() != nil {
+			return nil, err
+		}
+		discard(r.Context)
+		if serr := r.start(); serr != nil {
+			return nil, serr
+		}
+	}
+}
+
+func (r *retryingCtx) Unwrap() Context {
+	return r.Context
+}
+
+// discard releases the stream of a call being replaced by another attempt,
+// reporting the attempt as canceled unless it already concluded.
+func discard(call Context) {
+	if c, ok := call.(*ctx); ok {
+		_ = c.str.Reset(wire.ErrorCodeCancel)
+		c.end(status.Cancelled)
+	}
+}
+
+func (r *retryingCtx) Send(v any) error {
+	return r.SendContext(r.context, v)
+}
+
+func (r *retryingCtx) SendContext(ctx context.Context, v any) error {
+	r.sent = true
+	return r.Context.SendContext(ctx, v)
+}
@@ -0,0 +1,171 @@
+package arf
+
+import (
+	"context"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// makeAttemptServer serves a Do method handled by handler, and returns a
+// client connected to it along with a function listing the value of
+// RetryAttemptMetadataKey received by every attempt.
+func makeAttemptServer(t *testing.T, handler func(ctx context.Context, attempt int, c Context) error, opts ...ClientOption) (Client, func() []string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var seen []string
+	server := makeServer(t, map[string]ServiceExecutor{
+		"Do": func(ctx context.Context, c Context) error {
+			mu.Lock()
+			attempt := len(seen)
+			seen = append(seen, c.Request().Metadata.GetString(RetryAttemptMetadataKey))
+			mu.Unlock()
+			return handler(ctx, attempt, c)
+		},
+	})
+	c, err := server.InProcessClient(opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+
+	return c, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(seen)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	ctx := context.Background()
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: Backoff{BaseDelay: 20 * time.Millisecond}}
+	unavailable := func(context.Context, int, Context) error {
+		return status.Error(status.Unavailable, "try again")
+	}
+
+	t.Run("Unavailable is retried with backoff", func(t *testing.T) {
+		c, seen := makeAttemptServer(t, func(_ context.Context, attempt int, c Context) error {
+			if attempt < 2 {
+				return status.Error(status.Unavailable, "try again")
+			}
+			return c.SendResponse(status.OK, []any{"done"}, false, nil)
+		}, WithRetryPolicy(e2eService, "Do", policy))
+
+		start := time.Now()
+		call, err := c.Call(ctx, e2eService, "Do")
+		require.NoError(t, err)
+		result, err := call.Response().Result()
+		require.NoError(t, err)
+		assert.Equal(t, []any{"done"}, result)
+		assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+		assert.Equal(t, []string{"", "1", "2"}, seen())
+	})
+
+	t.Run("attempts are bounded by MaxAttempts", func(t *testing.T) {
+		c, seen := makeAttemptServer(t, unavailable, WithRetryPolicy(e2eService, "", policy))
+
+		call, err := c.Call(ctx, e2eService, "Do")
+		require.NoError(t, err)
+		_, err = call.Response().Result()
+		assert.Equal(t, status.Unavailable, status.Convert(err).Code)
+		assert.Len(t, seen(), 3)
+	})
+
+	t.Run("non-retryable codes are returned as is", func(t *testing.T) {
+		c, seen := makeAttemptServer(t, func(context.Context, int, Context) error {
+			return status.Error(status.InvalidArgument, "rejected")
+		}, WithRetryPolicy(e2eService, "Do", policy))
+
+		call, err := c.Call(ctx, e2eService, "Do")
+		require.NoError(t, err)
+		_, err = call.Response().Result()
+		assert.Equal(t, &status.BadStatus{Code: status.InvalidArgument, Message: "rejected"}, err)
+		assert.Len(t, seen(), 1)
+	})
+
+	t.Run("RetryableCodes replaces the default codes", func(t *testing.T) {
+		custom := policy
+		custom.RetryableCodes = []status.Status{status.Aborted}
+		c, seen := makeAttemptServer(t, func(_ context.Context, attempt int, c Context) error {
+			if attempt == 0 {
+				return status.Error(status.Aborted, "conflict")
+			}
+			return status.Error(status.Unavailable, "try again")
+		}, WithRetryPolicy(e2eService, "Do", custom))
+
+		call, err := c.Call(ctx, e2eService, "Do")
+		require.NoError(t, err)
+		_, err = call.Response().Result()
+		assert.Equal(t, status.Unavailable, status.Convert(err).Code)
+		assert.Len(t, seen(), 2)
+	})
+
+	t.Run("streams failing before exchanging items are retried", func(t *testing.T) {
+		c, seen := makeAttemptServer(t, func(_ context.Context, attempt int, c Context) error {
+			if attempt == 0 {
+				return status.Error(status.Unavailable, "try again")
+			}
+			return MakeOutStream[string](c).Send("a")
+		}, WithRetryPolicy(e2eService, "Do", policy))
+
+		call, err := c.Call(ctx, e2eService, "Do", WithStream())
+		require.NoError(t, err)
+		items, err := recvAll(MakeInStream[string](call))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a"}, items)
+		assert.Equal(t, []string{"", "1"}, seen())
+	})
+
+	t.Run("streams are not retried once an item was received", func(t *testing.T) {
+		c, seen := makeAttemptServer(t, func(_ context.Context, attempt int, c Context) error {
+			if err := MakeOutStream[string](c).Send("a"); err != nil {
+				return err
+			}
+			return status.Error(status.Unavailable, "try again")
+		}, WithRetryPolicy(e2eService, "Do", policy))
+
+		call, err := c.Call(ctx, e2eService, "Do")
+		require.NoError(t, err)
+		items, err := recvAll(MakeInStream[string](call))
+		assert.Equal(t, []string{"a"}, items)
+		assert.Equal(t, status.Unavailable, status.Convert(err).Code)
+		assert.Len(t, seen(), 1)
+	})
+
+	t.Run("streams are not retried once an item was sent", func(t *testing.T) {
+		c, seen := makeAttemptServer(t, func(_ context.Context, attempt int, c Context) error {
+			if _, err := MakeInStream[string](c).Recv(); err != nil {
+				return err
+			}
+			return status.Error(status.Unavailable, "try again")
+		}, WithRetryPolicy(e2eService, "Do", policy))
+
+		call, err := c.Call(ctx, e2eService, "Do", WithStream())
+		require.NoError(t, err)
+		str := MakeInOutStream[string, string](call)
+		require.NoError(t, str.Send("a"))
+		_, err = str.Recv()
+		assert.Equal(t, status.Unavailable, status.Convert(err).Code)
+		assert.Len(t, seen(), 1)
+	})
+
+	t.Run("a canceled context stops retrying", func(t *testing.T) {
+		slow := policy
+		slow.MaxAttempts = 5
+		slow.Backoff = Backoff{BaseDelay: time.Second}
+		c, seen := makeAttemptServer(t, unavailable, WithRetryPolicy(e2eService, "Do", slow))
+
+		cctx, cancel := context.WithCancel(ctx)
+		time.AfterFunc(50*time.Millisecond, cancel)
+		start := time.Now()
+		call, err := c.Call(cctx, e2eService, "Do")
+		require.NoError(t, err)
+		_, err = call.Response().Result()
+		assert.Equal(t, status.Unavailable, status.Convert(err).Code)
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Len(t, seen(), 1)
+	})
+}
@@ -1,6 +1,9 @@
 package rpc
 
-import "fmt"
+import (
+	"fmt"
+	"github.com/arf-rpc/arf-go/status"
+)
 
 type MessageKindMismatchError struct {
 	Expected MessageKind
@@ -11,6 +14,10 @@ func (e *MessageKindMismatchError) Error() string {
 	return fmt.Sprintf("expected kind %v but got %v", e.Expected, e.Received)
 }
 
+func (e *MessageKindMismatchError) BadStatus() *status.BadStatus {
+	return &status.BadStatus{Code: status.InternalError, Message: e.Error()}
+}
+
 type NoStreamError struct {
 	Recv bool
 }
@@ -23,6 +30,10 @@ func (e *NoStreamError) Error() string {
 	}
 }
 
+func (e *NoStreamError) BadStatus() *status.BadStatus {
+	return &status.BadStatus{Code: status.FailedPrecondition, Message: e.Error()}
+}
+
 type StreamEndError struct{}
 
 func (e *StreamEndError) Error() string {
@@ -36,3 +47,7 @@ type StreamFailure struct {
 func (e *StreamFailure) Error() string {
 	return fmt.Sprintf("stream failed: %s", e.Msg)
 }
+
+func (e *StreamFailure) BadStatus() *status.BadStatus {
+	return &status.BadStatus{Code: status.InternalError, Message: e.Error()}
+}
@@ -0,0 +1,52 @@
+package rpc
+
+import (
+	"bytes"
+	proto2 "github.com/arf-rpc/arf-go/proto"
+	"github.com/arf-rpc/arf-go/status"
+)
+
+const (
+	// StatusDescriptionKey is the metadata key carrying the message of a
+	// status sent by Response and StreamError.
+	StatusDescriptionKey = "arf-status-description"
+	// StatusDetailsKey is the metadata key carrying the details of a status.
+	// Each detail is encoded in its own value.
+	StatusDetailsKey = "arf-status-details"
+)
+
+// StatusMetadata returns the metadata representing bad in a Response or
+// StreamError.
+func StatusMetadata(bad *status.BadStatus) (Metadata, error) {
+	meta := MetadataFromStringPairs(StatusDescriptionKey, bad.Message)
+	for _, detail := range bad.Details {
+		data, err := proto2.Encode(detail)
+		if err != nil {
+			return nil, err
+		}
+		meta.Add(StatusDetailsKey, data)
+	}
+	return meta, nil
+}
+
+// statusFromMetadata reverses StatusMetadata. Details of types not
+// registered in this process are omitted.
+func statusFromMetadata(code status.Status, meta Metadata) *status.BadStatus {
+	msg, ok := meta.LookupString(StatusDescriptionKey)
+	if !ok {
+		msg = code.Error()
+	}
+
+	bad := &status.BadStatus{Code: code, Message: msg}
+	for _, pair := range meta {
+		if pair.Key != StatusDetailsKey {
+			continue
+		}
+		detail, err := proto2.DecodeAny(bytes.NewReader(pair.Value))
+		if err != nil {
+			continue
+		}
+		bad.Details = append(bad.Details, detail)
+	}
+	return bad
+}
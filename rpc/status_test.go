@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"bytes"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"slices"
+	"testing"
+)
+
+func TestStatusDetails(t *testing.T) {
+	bad := status.WithDetails(status.InvalidArgument, "invalid user",
+		&status.BadRequest{FieldViolations: []status.FieldViolation{
+			{Field: "email", Description: "must not be empty"},
+		}},
+		&status.RetryInfo{RetryDelayMillis: 1500},
+	).(*status.BadStatus)
+
+	meta, err := StatusMetadata(bad)
+	require.NoError(t, err)
+
+	t.Run("Response.Result decodes details", func(t *testing.T) {
+		encoded, err := (&Response{Status: uint16(bad.Code), Metadata: meta}).Wrap()
+		require.NoError(t, err)
+		resp, err := MessageTFromReader[*Response](bytes.NewReader(encoded))
+		require.NoError(t, err)
+
+		_, err = resp.Result()
+		got, ok := status.FromError(err)
+		require.True(t, ok)
+		assert.Equal(t, bad.Code, got.Code)
+		assert.Equal(t, bad.Message, got.Message)
+		assert.Equal(t, bad.Details, got.Details)
+	})
+
+	t.Run("StreamError decodes details", func(t *testing.T) {
+		encoded, err := (&StreamError{Status: uint16(bad.Code), Metadata: meta}).Wrap()
+		require.NoError(t, err)
+		msg, err := MessageTFromReader[*StreamError](bytes.NewReader(encoded))
+		require.NoError(t, err)
+
+		got, ok := status.FromError(msg)
+		require.True(t, ok)
+		assert.Equal(t, bad.Code, got.Code)
+		assert.Equal(t, bad.Details, got.Details)
+		assert.ErrorAs(t, got, new(*StreamError))
+	})
+
+	t.Run("undecodable details are omitted", func(t *testing.T) {
+		meta := MetadataFromStringPairs(StatusDescriptionKey, "oops")
+		meta.Add(StatusDetailsKey, []byte{0xff})
+		got := (&StreamError{Status: uint16(status.Aborted), Metadata: meta}).BadStatus()
+		assert.Equal(t, &status.BadStatus{Code: status.Aborted, Message: "oops"}, got)
+	})
+
+	t.Run("Trailer omits status keys", func(t *testing.T) {
+		trailer := MetadataFromStringPairs("foo", "bar")
+		msg := &StreamError{Status: uint16(bad.Code), Metadata: append(slices.Clone(meta), trailer...)}
+		assert.Equal(t, trailer, msg.Trailer())
+	})
+}
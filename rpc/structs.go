@@ -7,6 +7,7 @@ import (
 	"github.com/arf-rpc/arf-go/status"
 	"io"
 	"reflect"
+	"strings"
 )
 
 type Message interface {
@@ -135,6 +136,27 @@ func (r *Request) FromReader(read io.Reader) error {
 	return nil
 }
 
+// RequestHeaderFromReader reads a wrapped Request from r up to its service
+// and method fields, without decoding its metadata or parameters.
+func RequestHeaderFromReader(r io.Reader) (service, method string, err error) {
+	var k MessageKind
+	if k, err = MessageKindFromReader(r); err != nil {
+		return
+	}
+	if k != MessageKindRequest {
+		err = &MessageKindMismatchError{
+			Expected: MessageKindRequest,
+			Received: k,
+		}
+		return
+	}
+	if service, err = proto2.DecodeString(r); err != nil {
+		return
+	}
+	method, err = proto2.DecodeString(r)
+	return
+}
+
 func (r *Request) Kind() MessageKind { return MessageKindRequest }
 
 func (r *Request) Wrap() ([]byte, error) { return wrapMessage(r) }
@@ -213,20 +235,49 @@ func (r *Response) Kind() MessageKind { return MessageKindResponse }
 
 func (r *Response) Wrap() ([]byte, error) { return wrapMessage(r) }
 
+// TrailerKeyPrefix prefixes the keys of trailer metadata carried by a
+// non-streaming Response, which has no EndStream to deliver it.
+const TrailerKeyPrefix = "arf-trailer-"
+
+// TrailerMetadata returns md with TrailerKeyPrefix added to its keys, so it
+// can be carried by a non-streaming Response along with its header.
+func TrailerMetadata(md Metadata) Metadata {
+	trailer := make(Metadata, 0, len(md))
+	for _, pair := range md {
+		trailer = append(trailer, MetadataPair{TrailerKeyPrefix + pair.Key, pair.Value})
+	}
+	return trailer
+}
+
+// Header returns the metadata carried by r, excluding its trailer.
+func (r *Response) Header() Metadata {
+	var header Metadata
+	for _, pair := range r.Metadata {
+		if !strings.HasPrefix(pair.Key, TrailerKeyPrefix) {
+			header = append(header, pair)
+		}
+	}
+	return header
+}
+
+// Trailer returns the trailer metadata carried by r, as added by
+// TrailerMetadata.
+func (r *Response) Trailer() Metadata {
+	var trailer Metadata
+	for _, pair := range r.Metadata {
+		if key, ok := strings.CutPrefix(pair.Key, TrailerKeyPrefix); ok {
+			trailer = append(trailer, MetadataPair{key, pair.Value})
+		}
+	}
+	return trailer
+}
+
 func (r *Response) Result() ([]any, error) {
 	if r.Status == uint16(status.OK) {
 		return r.Params, nil
 	}
 
-	st := status.Status(r.Status)
-	respStatus, ok := r.Metadata.LookupString("arf-status-description")
-	if !ok {
-		respStatus = st.Error()
-	}
-	return nil, &status.BadStatus{
-		Code:    st,
-		Message: respStatus,
-	}
+	return nil, statusFromMetadata(status.Status(r.Status), r.Metadata)
 }
 
 type StartStream struct{}
@@ -277,11 +328,20 @@ func (s *StreamMetadata) Kind() MessageKind { return MessageKindStreamMetadata }
 
 func (s *StreamMetadata) Wrap() ([]byte, error) { return wrapMessage(s) }
 
-type EndStream struct{}
+// EndStream ends a stream, carrying the trailer metadata of its sender.
+type EndStream struct {
+	Metadata Metadata
+}
 
-func (e *EndStream) Encode() ([]byte, error) { return nil, nil }
+func (e *EndStream) Encode() ([]byte, error) { return e.Metadata.Encode(), nil }
 
-func (e *EndStream) FromReader(io.Reader) error { return nil }
+func (e *EndStream) FromReader(r io.Reader) (err error) {
+	// Peers may omit the metadata of an EndStream altogether.
+	if e.Metadata, err = MetadataFromReader(r); err == io.EOF {
+		e.Metadata, err = Metadata{}, nil
+	}
+	return
+}
 
 func (e *EndStream) Kind() MessageKind { return MessageKindEndStream }
 
@@ -321,3 +381,21 @@ func (s *StreamError) Wrap() ([]byte, error) { return wrapMessage(s) }
 func (s *StreamError) Error() string {
 	return fmt.Sprintf("stream error: status=%d", s.Status)
 }
+
+// Trailer returns the metadata carried by s, excluding the keys describing
+// its status.
+func (s *StreamError) Trailer() Metadata {
+	var trailer Metadata
+	for _, pair := range s.Metadata {
+		if pair.Key != StatusDescriptionKey && pair.Key != StatusDetailsKey {
+			trailer = append(trailer, pair)
+		}
+	}
+	return trailer
+}
+
+// BadStatus returns the status the stream was terminated with, including
+// its message and details.
+func (s *StreamError) BadStatus() *status.BadStatus {
+	return statusFromMetadata(status.Status(s.Status), s.Metadata)
+}
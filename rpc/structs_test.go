@@ -27,6 +27,29 @@ func TestRequest(t *testing.T) {
 	assert.Equal(t, req, read)
 }
 
+func TestRequestHeaderFromReader(t *testing.T) {
+	req := &Request{
+		Service:  "org.example.test/FooService",
+		Method:   "Add",
+		Metadata: MetadataFromStringPairs("foo", "bar"),
+		Params:   []any{uint32(1)},
+	}
+	encoded, err := req.Wrap()
+	require.NoError(t, err)
+
+	r := bytes.NewReader(encoded)
+	service, method, err := RequestHeaderFromReader(r)
+	require.NoError(t, err)
+	assert.Equal(t, "org.example.test/FooService", service)
+	assert.Equal(t, "Add", method)
+	assert.NotZero(t, r.Len())
+
+	encoded, err = (&Response{}).Wrap()
+	require.NoError(t, err)
+	_, _, err = RequestHeaderFromReader(bytes.NewReader(encoded))
+	assert.ErrorAs(t, err, new(*MessageKindMismatchError))
+}
+
 func TestResponse(t *testing.T) {
 	res := &Response{
 		Status:    uint16(status.InternalError),
@@ -42,6 +65,15 @@ func TestResponse(t *testing.T) {
 	assert.Equal(t, res, read)
 }
 
+func TestResponseTrailer(t *testing.T) {
+	res := &Response{Metadata: append(
+		MetadataFromStringPairs("foo", "bar"),
+		TrailerMetadata(MetadataFromStringPairs("count", "3"))...,
+	)}
+	assert.Equal(t, MetadataFromStringPairs("foo", "bar"), res.Header())
+	assert.Equal(t, MetadataFromStringPairs("count", "3"), res.Trailer())
+}
+
 func TestStartStream(t *testing.T) {
 	s := &StartStream{}
 	encoded, err := s.Wrap()
@@ -65,13 +97,17 @@ func TestStreamItem(t *testing.T) {
 }
 
 func TestEndStream(t *testing.T) {
-	s := &EndStream{}
+	s := &EndStream{Metadata: MetadataFromStringPairs("foo", "bar")}
 	encoded, err := s.Wrap()
 	require.NoError(t, err)
 	r := bytes.NewReader(encoded)
 	read, err := MessageTFromReader[*EndStream](r)
 	require.NoError(t, err)
 	require.Equal(t, s, read)
+
+	read, err = MessageTFromReader[*EndStream](bytes.NewReader([]byte{byte(MessageKindEndStream)}))
+	require.NoError(t, err)
+	assert.Equal(t, &EndStream{Metadata: Metadata{}}, read)
 }
 
 func TestStreamError(t *testing.T) {
@@ -3,15 +3,20 @@ package arf
 import (
 	"context"
 	"crypto/rand"
+	"crypto/tls"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"github.com/arf-rpc/arf-go/proto"
 	"github.com/arf-rpc/arf-go/rpc"
 	"github.com/arf-rpc/arf-go/status"
 	"github.com/arf-rpc/arf-go/wire"
 	"github.com/go-stdlog/stdlog"
 	"net"
 	"os"
+	"slices"
+	"sync"
+	"time"
 )
 
 var StreamCanceledErr = errors.New("stream canceled")
@@ -24,6 +29,32 @@ type ServerOptions struct {
 	MaxConcurrentStreams uint32 /* TODO */
 	Logger               stdlog.Logger
 	IDGenerator          IDGenerator
+
+	// DecodeOptions limits the resources used when decoding requests and
+	// stream items received from clients.
+	DecodeOptions proto.DecodeOptions
+
+	// MaxRecvMessageSize is the maximum size, in bytes, of a single request
+	// or stream item received from a client. Zero means no limit.
+	MaxRecvMessageSize int
+
+	// MaxSendMessageSize is the maximum size, in bytes, of a single
+	// response or stream item sent to a client. Zero means no limit.
+	MaxSendMessageSize int
+
+	// TLSConfig, when set, makes the server perform a TLS handshake on
+	// every accepted connection. Set ClientAuth to require client
+	// certificates, which handlers can then inspect through PeerFromContext.
+	TLSConfig *tls.Config
+
+	// StatsHandler, when set, is notified of connections accepted by the
+	// server, the frames they exchange, and the calls it handles.
+	StatsHandler StatsHandler
+
+	// FrameTap, when set, observes every frame exchanged through
+	// connections accepted by the server. Use a wire.CaptureWriter to
+	// record them to a capture file.
+	FrameTap wire.FrameTap
 }
 
 type Server interface {
@@ -32,6 +63,13 @@ type Server interface {
 	Serve() error
 	Shutdown() error
 	RegisterInterceptor(interceptor ...Interceptor)
+	InProcessClient(opts ...ClientOption) (Client, error)
+	// Connections describes the connections established with the server,
+	// along with their streams.
+	Connections() []ConnectionStatus
+	// PingConnection measures the round-trip time of the connection
+	// identified by id, as reported by Connections.
+	PingConnection(ctx context.Context, id int) (time.Duration, error)
 }
 
 func pseudoUUIDGen() (func() (string, error), error) {
@@ -67,6 +105,10 @@ func NewServer(l net.Listener, opts ServerOptions) (Server, error) {
 		}
 	}
 
+	if opts.TLSConfig != nil {
+		l = tls.NewListener(l, opts.TLSConfig)
+	}
+
 	server := &srv{
 		listener:      l,
 		services:      make(map[string]Service),
@@ -75,30 +117,44 @@ func NewServer(l net.Listener, opts ServerOptions) (Server, error) {
 		interceptors:  nil,
 		idGenerator:   opts.IDGenerator,
 		logger:        stdlog.Discard,
+		decodeOptions: opts.DecodeOptions,
+		stats:         opts.StatsHandler,
 	}
 
-	server.logger = opts.Logger
+	if opts.Logger != nil {
+		server.logger = opts.Logger
+	}
 
-	srv := wire.NewServer(l, server)
+	srv := wire.NewServer(l, server,
+		wire.WithMaxRecvMessageSize(opts.MaxRecvMessageSize),
+		wire.WithMaxSendMessageSize(opts.MaxSendMessageSize),
+		wire.WithStatsHandler(opts.StatsHandler),
+		wire.WithFrameTap(opts.FrameTap),
+		wire.WithLogger(server.logger))
 	server.wireServer = srv
 
 	return server, nil
 }
 
 type streamContext struct {
-	cancel context.CancelCauseFunc
-	ctx    context.Context
+	cancel  context.CancelCauseFunc
+	ctx     context.Context
+	service string
+	method  string
 }
 
 type srv struct {
 	listener      net.Listener
 	wireServer    *wire.Server
 	services      map[string]Service
+	streamsMu     sync.Mutex
 	streams       map[string]wire.Stream
 	streamContext map[string]*streamContext
 	interceptors  []Interceptor
 	idGenerator   func() (string, error)
 	logger        stdlog.Logger
+	decodeOptions proto.DecodeOptions
+	stats         StatsHandler
 }
 
 func (s *srv) RegisterService(service Service) error {
@@ -123,6 +179,19 @@ func (s *srv) Serve() error {
 	return s.wireServer.Serve()
 }
 
+// InProcessClient returns a Client connected to the server through an
+// in-memory pipe, bypassing the listener.
+func (s *srv) InProcessClient(opts ...ClientOption) (Client, error) {
+	local, remote := net.Pipe()
+	s.wireServer.ServeConn(remote)
+
+	c := newClient(opts)
+	if err := c.start(context.Background(), local); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
 func (s *srv) RegisterInterceptor(interceptor ...Interceptor) {
 	s.interceptors = append(s.interceptors, interceptor...)
 }
@@ -146,7 +215,17 @@ func chainInterceptors(finalHandler handler, interceptors ...Interceptor) handle
 	}
 }
 
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the identifier assigned by the IDGenerator of
+// the server to the call ctx was provided to.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
 func (s *srv) ServiceStream(str wire.Stream) {
+	str = newCountingStream(str)
 	reqID, err := s.idGenerator()
 	if err != nil {
 		s.logger.Error(err, "failed to generate request ID")
@@ -156,65 +235,91 @@ func (s *srv) ServiceStream(str wire.Stream) {
 	str.SetExternalID(reqID)
 	log := s.logger.WithFields("request_id", reqID)
 
-	req, err := rpc.MessageTFromReader[*rpc.Request](str)
-	if err != nil {
+	req, err := rpc.MessageTFromReader[*rpc.Request](proto.NewDecoder(str, s.decodeOptions))
+	var limitErr *proto.LimitExceededError
+	var sizeErr *wire.MessageTooLargeError
+	if errors.As(err, &sizeErr) {
+		log.Info("Rejecting request exceeding maximum message size", "error", err)
+		s.rejectOversizedStream(str, err)
+		return
+	} else if errors.As(err, &limitErr) {
+		log.Info("Rejecting request exceeding decode limits", "error", err)
+		s.rejectInvalidStreamMsg(str, status.ResourceExhausted, err.Error())
+		return
+	} else if err != nil {
 		log.Error(err, "Failed deserializing request payload", "error", err)
 		s.rejectInvalidStreamMsg(str, status.InternalError, "Failed deserializing request payload")
 		return
 	}
 
+	tracker := newRPCTracker(s.stats, req.Service, req.Method, false)
 	svc, ok := s.services[req.Service]
 	if !ok || !svc.RespondsTo(req.Method) {
 		log.Info("Rejecting request as service does not respond to the requested method", "service", req.Streaming, "method", req.Method)
 		s.rejectInvalidStream(str, status.Unimplemented)
+		tracker.end(status.Unimplemented)
 		return
 	}
 
-	cctx, cancel := context.WithCancelCause(context.Background())
+	cctx := context.WithValue(context.Background(), requestIDKey{}, reqID)
+	cctx, cancel := context.WithCancelCause(NewContextWithPeer(cctx, peerFromConnInfo(str.ConnInfo())))
+	s.streamsMu.Lock()
 	s.streams[reqID] = str
 	s.streamContext[reqID] = &streamContext{
-		cancel: cancel,
-		ctx:    cctx,
+		cancel:  cancel,
+		ctx:     cctx,
+		service: req.Service,
+		method:  req.Method,
 	}
+	s.streamsMu.Unlock()
+	defer func() {
+		s.streamsMu.Lock()
+		delete(s.streams, reqID)
+		delete(s.streamContext, reqID)
+		s.streamsMu.Unlock()
+		cancel(nil)
+	}()
 
 	reqCtx := &ctx{
 		str:           str,
 		hasRecvStream: req.Streaming,
 		req:           req,
 		context:       cctx,
+		decodeOptions: s.decodeOptions,
+		server:        true,
+		rpc:           tracker,
 	}
 
 	chain := chainInterceptors(func(ctx context.Context, req Context) error {
-		return s.guardInvoke(ctx, reqCtx, svc)
+		return s.guardInvoke(ctx, reqCtx, req, svc)
 	}, s.interceptors...)
 
 	if err = chain(cctx, reqCtx); err != nil {
-		var badStatusErr *status.BadStatus
-		var statusErr *status.Status
-		switch {
-		case errors.As(err, &badStatusErr):
-			s.emitError(str, badStatusErr, reqCtx)
-		case errors.As(err, &statusErr):
-			s.emitError(str, &status.BadStatus{
-				Code:    *statusErr,
-				Message: statusErr.Error(),
-			}, reqCtx)
-		default:
+		bad, ok := status.FromError(err)
+		if !ok {
 			log.Error(err, "Request handler or interceptor chain returned an error")
-			s.emitError(str, &status.BadStatus{
+			bad = &status.BadStatus{
 				Code:    status.InternalError,
 				Message: err.Error(),
-			}, reqCtx)
+			}
 		}
-
+		s.emitError(str, bad, reqCtx, log)
+		tracker.end(bad.Code)
 		return
 	}
+	tracker.end(reqCtx.sentStatus)
 }
 
-func (s *srv) guardInvoke(ctx context.Context, req *ctx, svc Service) (err error) {
+// guardInvoke invokes the handler with handlerCtx, the Context passed along
+// by interceptors, which is req or wraps it.
+func (s *srv) guardInvoke(ctx context.Context, req *ctx, handlerCtx Context, svc Service) (err error) {
 	defer func() {
-		if err == nil && !req.hasSentResponse {
+		switch {
+		case err != nil:
+		case !req.hasSentResponse:
 			err = req.SendResponse(status.OK, nil, false, nil)
+		case req.hasSendStream && !req.sendStreamEnded:
+			err = req.EndSend()
 		}
 	}()
 	//defer func() {
@@ -227,11 +332,13 @@ func (s *srv) guardInvoke(ctx context.Context, req *ctx, svc Service) (err error
 	//	}
 	//}()
 
-	return svc.InvokeMethod(req.Request().Method, ctx, req)
+	return svc.InvokeMethod(req.Request().Method, ctx, handlerCtx)
 }
 
 func (s *srv) CancelStream(stream wire.Stream) {
+	s.streamsMu.Lock()
 	ctx := s.streamContext[stream.ExternalID()]
+	s.streamsMu.Unlock()
 	if ctx == nil {
 		return
 	}
@@ -243,7 +350,7 @@ func (s *srv) rejectInvalidStreamMsg(str wire.Stream, code status.Status, msg st
 		Status:    uint16(code),
 		Streaming: false,
 		Metadata: rpc.MetadataFromStringPairs(
-			"arf-status-description", msg,
+			rpc.StatusDescriptionKey, msg,
 		),
 		Params: nil,
 	}).Wrap()
@@ -259,29 +366,57 @@ func (s *srv) rejectInvalidStreamMsg(str wire.Stream, code status.Status, msg st
 	}
 }
 
+// rejectOversizedStream informs the client its message exceeded the maximum
+// size, and resets the stream so it stops sending the remainder.
+func (s *srv) rejectOversizedStream(str wire.Stream, cause error) {
+	enc, err := (&rpc.Response{
+		Status:    uint16(status.ResourceExhausted),
+		Streaming: false,
+		Metadata: rpc.MetadataFromStringPairs(
+			rpc.StatusDescriptionKey, cause.Error(),
+		),
+		Params: nil,
+	}).Wrap()
+	if err == nil {
+		_ = str.Write(enc, false)
+	}
+	_ = str.Reset(wire.ErrorCodeEnhanceYourCalm)
+}
+
 func (s *srv) rejectInvalidStream(str wire.Stream, code status.Status) {
 	s.rejectInvalidStreamMsg(str, code, code.Error())
 }
 
-func (s *srv) emitError(str wire.Stream, status *status.BadStatus, resp Context) {
+func (s *srv) emitError(str wire.Stream, status *status.BadStatus, resp Context, log stdlog.Logger) {
 	ctx := resp.(*ctx)
 	var (
 		enc []byte
 		err error
 	)
-	meta := rpc.MetadataFromStringPairs(
-		"arf-status-description", status.Message,
-	)
-	if ctx.sendStreamStarted {
+	meta, err := rpc.StatusMetadata(status)
+	if err != nil {
+		log.Error(err, "Failed encoding status details")
+		meta = rpc.MetadataFromStringPairs(
+			rpc.StatusDescriptionKey, status.Message,
+		)
+	}
+	// Once a streaming response was sent, the error terminates the stream
+	// along with the trailers. Otherwise, it replaces the response, carrying
+	// headers as well.
+	if ctx.hasSentResponse && ctx.hasSendStream {
+		if err = ctx.startSendStream(context.Background()); err != nil {
+			_ = str.Reset(wire.ErrorCodeInternalError)
+			return
+		}
 		enc, err = (&rpc.StreamError{
 			Status:   uint16(status.Code),
-			Metadata: meta,
+			Metadata: append(meta, ctx.trailer...),
 		}).Wrap()
 	} else {
 		enc, err = (&rpc.Response{
 			Status:    uint16(status.Code),
 			Streaming: false,
-			Metadata:  meta,
+			Metadata:  slices.Concat(ctx.header, meta, rpc.TrailerMetadata(ctx.trailer)),
 			Params:    nil,
 		}).Wrap()
 	}
@@ -0,0 +1,105 @@
+package arf
+
+import (
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/arf-rpc/arf-go/wire"
+	"sync/atomic"
+	"time"
+)
+
+// RPCInfo identifies a call reported to a StatsHandler.
+type RPCInfo struct {
+	Service string
+	Method  string
+	// Client is set for calls made by a Client, and unset for calls handled
+	// by a Server.
+	Client    bool
+	BeginTime time.Time
+}
+
+// RPCStats describes a concluded call.
+type RPCStats struct {
+	RPCInfo
+	// Code is the status the call concluded with.
+	Code     status.Status
+	Duration time.Duration
+	// MessagesSent and MessagesReceived count the stream items exchanged
+	// during the call.
+	MessagesSent     int
+	MessagesReceived int
+}
+
+// StatsHandler is notified of connections, frames and calls. Its methods
+// are called synchronously and must not block. Set it through
+// ServerOptions.StatsHandler or WithStatsHandler.
+type StatsHandler interface {
+	wire.StatsHandler
+	// RPCBegin is called once a call starts.
+	RPCBegin(info RPCInfo)
+	// RPCEnd is called once a call concludes. Calls made by a Client
+	// conclude once their response is received, or once their response
+	// stream ends, either normally or by an error.
+	RPCEnd(stats RPCStats)
+}
+
+// WithStatsHandler makes the client report its connections and calls to h.
+func WithStatsHandler(h StatsHandler) ClientOption {
+	return func(c *client) {
+		c.stats = h
+		c.wireOptions = append(c.wireOptions, wire.WithStatsHandler(h))
+	}
+}
+
+// rpcTracker reports a call to a StatsHandler, counting the stream items it
+// exchanges. Items may be sent and received concurrently, so its state is
+// kept in atomics.
+type rpcTracker struct {
+	stats    StatsHandler
+	info     RPCInfo
+	ended    atomic.Bool
+	sent     atomic.Int64
+	received atomic.Int64
+}
+
+func newRPCTracker(stats StatsHandler, service, method string, client bool) *rpcTracker {
+	if stats == nil {
+		return nil
+	}
+	t := &rpcTracker{
+		stats: stats,
+		info: RPCInfo{
+			Service:   service,
+			Method:    method,
+			Client:    client,
+			BeginTime: time.Now(),
+		},
+	}
+	stats.RPCBegin(t.info)
+	return t
+}
+
+func (t *rpcTracker) itemSent() {
+	if t != nil {
+		t.sent.Add(1)
+	}
+}
+
+func (t *rpcTracker) itemReceived() {
+	if t != nil {
+		t.received.Add(1)
+	}
+}
+
+// end reports the call as concluded with code, unless it already was.
+func (t *rpcTracker) end(code status.Status) {
+	if t == nil || !t.ended.CompareAndSwap(false, true) {
+		return
+	}
+	t.stats.RPCEnd(RPCStats{
+		RPCInfo:          t.info,
+		Code:             code,
+		Duration:         time.Since(t.info.BeginTime),
+		MessagesSent:     int(t.sent.Load()),
+		MessagesReceived: int(t.received.Load()),
+	})
+}
@@ -0,0 +1,131 @@
+package arf
+
+import (
+	"context"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/arf-rpc/arf-go/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// statsRecorder records the calls reported to a StatsHandler.
+type statsRecorder struct {
+	mu    sync.Mutex
+	begun int
+	ended []RPCStats
+}
+
+func (r *statsRecorder) RPCBegin(RPCInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.begun++
+}
+
+func (r *statsRecorder) RPCEnd(stats RPCStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ended = append(r.ended, stats)
+}
+
+func (r *statsRecorder) ConnBegin(wire.ConnInfo)      {}
+func (r *statsRecorder) ConnEnd(wire.ConnInfo, error) {}
+func (r *statsRecorder) FrameIn(wire.FrameStats)      {}
+func (r *statsRecorder) FrameOut(wire.FrameStats)     {}
+
+// codes returns the amount of calls begun, and the codes of those ended.
+func (r *statsRecorder) codes() (int, []status.Status) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var codes []status.Status
+	for _, s := range r.ended {
+		codes = append(codes, s.Code)
+	}
+	return r.begun, codes
+}
+
+func TestStatsHandler(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("streams count the items exchanged", func(t *testing.T) {
+		stats := &statsRecorder{}
+		c, _ := makeAttemptServer(t, func(_ context.Context, _ int, c Context) error {
+			str := MakeInOutStream[string, string](c)
+			for {
+				v, err := str.Recv()
+				if IsStreamEnd(err) {
+					return nil
+				} else if err != nil {
+					return err
+				}
+				if err = str.Send(v); err != nil {
+					return err
+				}
+			}
+		}, WithStatsHandler(stats))
+
+		call, err := c.Call(ctx, e2eService, "Do", WithStream())
+		require.NoError(t, err)
+		str := MakeInOutStream[string, string](call)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, v := range []string{"a", "b", "c"} {
+				assert.NoError(t, str.Send(v))
+			}
+			assert.NoError(t, str.Close())
+		}()
+		items, err := recvAll[string](str)
+		require.NoError(t, err)
+		wg.Wait()
+		assert.Equal(t, []string{"a", "b", "c"}, items)
+
+		stats.mu.Lock()
+		defer stats.mu.Unlock()
+		require.Len(t, stats.ended, 1)
+		assert.Equal(t, 3, stats.ended[0].MessagesSent)
+		assert.Equal(t, 3, stats.ended[0].MessagesReceived)
+	})
+
+	t.Run("calls with a canceled context are reported as canceled", func(t *testing.T) {
+		stats := &statsRecorder{}
+		c, _ := makeAttemptServer(t, func(context.Context, int, Context) error {
+			return nil
+		}, WithStatsHandler(stats))
+
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := c.Call(cctx, e2eService, "Do")
+		assert.Equal(t, status.Cancelled, status.Convert(err).Code)
+
+		begun, codes := stats.codes()
+		assert.Equal(t, 1, begun)
+		assert.Equal(t, []status.Status{status.Cancelled}, codes)
+	})
+
+	t.Run("discarded attempts are reported as canceled", func(t *testing.T) {
+		stats := &statsRecorder{}
+		c, _ := makeAttemptServer(t, func(ctx context.Context, attempt int, c Context) error {
+			if attempt == 0 {
+				return respondAfter(ctx, c, 3*time.Second, "first", make(chan struct{}, 1))
+			}
+			return c.SendResponse(status.OK, []any{"second"}, false, nil)
+		}, WithStatsHandler(stats),
+			WithHedgingPolicy(e2eService, "Do", HedgingPolicy{MaxAttempts: 2, Delay: 30 * time.Millisecond}))
+
+		call, err := c.Call(ctx, e2eService, "Do")
+		require.NoError(t, err)
+		_, err = call.Response().Result()
+		require.NoError(t, err)
+
+		assert.Eventually(t, func() bool {
+			begun, codes := stats.codes()
+			slices.Sort(codes)
+			return begun == 2 && slices.Equal([]status.Status{status.OK, status.Cancelled}, codes)
+		}, time.Second, 5*time.Millisecond)
+	})
+}
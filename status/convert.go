@@ -0,0 +1,76 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"github.com/arf-rpc/arf-go/proto"
+	"github.com/arf-rpc/arf-go/wire"
+	"io"
+	"net"
+)
+
+// FromErrorCode returns the status corresponding to a stream being reset
+// with code.
+func FromErrorCode(code wire.ErrorCode) Status {
+	switch code {
+	case wire.ErrorCodeRefusedStream:
+		return Unavailable
+	case wire.ErrorCodeCancel:
+		return Cancelled
+	case wire.ErrorCodeEnhanceYourCalm:
+		return ResourceExhausted
+	case wire.ErrorCodeInadequateSecurity:
+		return PermissionDenied
+	default:
+		return InternalError
+	}
+}
+
+// Convert returns the BadStatus best describing err. Errors already carrying
+// a status are returned as by FromError; otherwise, stream resets are mapped
+// through FromErrorCode, context errors become Cancelled or
+// DeadlineExceeded, lost connections become Unavailable, exceeded limits
+// become ResourceExhausted, values not matching the expected type become
+// InvalidArgument, and other errors become Unknown. Unless err is a
+// *BadStatus itself, it remains reachable through errors.Unwrap, so
+// errors.As keeps matching its original type. Convert returns nil for a nil
+// err.
+func Convert(err error) *BadStatus {
+	if err == nil {
+		return nil
+	}
+	if bad, ok := FromError(err); ok {
+		return bad
+	}
+
+	var resetErr *wire.StreamResetError
+	var canceledErr *wire.StreamCanceledError
+	var connErr *wire.ConnectionResetError
+	var sizeErr *wire.MessageTooLargeError
+	var limitErr *proto.LimitExceededError
+	var convErr *proto.ConversionError
+	var netErr net.Error
+
+	code := Unknown
+	switch {
+	case errors.Is(err, context.Canceled):
+		code = Cancelled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = DeadlineExceeded
+	case errors.As(err, &resetErr):
+		code = FromErrorCode(resetErr.Reason)
+	case errors.As(err, &canceledErr):
+		code = FromErrorCode(canceledErr.Reason)
+	case errors.As(err, &sizeErr), errors.As(err, &limitErr):
+		code = ResourceExhausted
+	case errors.As(err, &convErr):
+		code = InvalidArgument
+	case errors.As(err, &connErr),
+		errors.Is(err, wire.ClosedConnErr),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.As(err, &netErr):
+		code = Unavailable
+	}
+	return &BadStatus{Code: code, Message: err.Error(), cause: err}
+}
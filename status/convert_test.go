@@ -0,0 +1,54 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/arf-rpc/arf-go/proto"
+	"github.com/arf-rpc/arf-go/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	cases := []struct {
+		err  error
+		code Status
+	}{
+		{&wire.StreamResetError{Reason: wire.ErrorCodeRefusedStream}, Unavailable},
+		{&wire.StreamResetError{Reason: wire.ErrorCodeCancel}, Cancelled},
+		{&wire.StreamResetError{Reason: wire.ErrorCodeEnhanceYourCalm}, ResourceExhausted},
+		{&wire.StreamResetError{Reason: wire.ErrorCodeProtocolError}, InternalError},
+		{&wire.ConnectionResetError{Reason: wire.ErrorCodeNoError}, Unavailable},
+		{wire.ClosedConnErr, Unavailable},
+		{&wire.MessageTooLargeError{Size: 2, Max: 1}, ResourceExhausted},
+		{&proto.LimitExceededError{Limit: proto.LimitDepth}, ResourceExhausted},
+		{&proto.ConversionError{From: reflect.TypeFor[string](), To: reflect.TypeFor[int]()}, InvalidArgument},
+		{context.Canceled, Cancelled},
+		{fmt.Errorf("call: %w", context.DeadlineExceeded), DeadlineExceeded},
+		{io.ErrUnexpectedEOF, Unavailable},
+		{NotFound, NotFound},
+		{Error(PermissionDenied, "nope"), PermissionDenied},
+		{errors.New("boom"), Unknown},
+	}
+	for _, c := range cases {
+		t.Run(c.err.Error(), func(t *testing.T) {
+			bad := Convert(c.err)
+			require.NotNil(t, bad)
+			assert.Equal(t, c.code, bad.Code)
+			assert.ErrorIs(t, bad, c.code)
+			assert.ErrorIs(t, bad, c.err)
+		})
+	}
+
+	assert.Nil(t, Convert(nil))
+}
+
+func TestBadStatusIs(t *testing.T) {
+	err := fmt.Errorf("lookup: %w", Error(NotFound, "no such user"))
+	assert.ErrorIs(t, err, NotFound)
+	assert.NotErrorIs(t, err, PermissionDenied)
+}
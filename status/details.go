@@ -0,0 +1,62 @@
+package status
+
+import (
+	"github.com/arf-rpc/arf-go/proto"
+)
+
+// The following types are standard error details, registered with the proto
+// package so they can be carried by a BadStatus. Applications may carry any
+// other registered proto.Struct as well.
+
+// BadRequest describes invalid fields of a request.
+type BadRequest struct {
+	FieldViolations []FieldViolation `arf:"0"`
+}
+
+func (BadRequest) ArfStructID() string { return "arf.status/BadRequest" }
+
+type FieldViolation struct {
+	Field       string `arf:"0"`
+	Description string `arf:"1"`
+}
+
+func (FieldViolation) ArfStructID() string { return "arf.status/FieldViolation" }
+
+// RetryInfo tells the client how long to wait before retrying a call.
+type RetryInfo struct {
+	RetryDelayMillis uint64 `arf:"0"`
+}
+
+func (RetryInfo) ArfStructID() string { return "arf.status/RetryInfo" }
+
+// QuotaFailure describes quotas exhausted by a call.
+type QuotaFailure struct {
+	Violations []QuotaViolation `arf:"0"`
+}
+
+func (QuotaFailure) ArfStructID() string { return "arf.status/QuotaFailure" }
+
+type QuotaViolation struct {
+	Subject     string `arf:"0"`
+	Description string `arf:"1"`
+}
+
+func (QuotaViolation) ArfStructID() string { return "arf.status/QuotaViolation" }
+
+// DebugInfo carries server-side debugging information, such as a stack
+// trace. It should not be sent to untrusted clients.
+type DebugInfo struct {
+	StackEntries []string `arf:"0"`
+	Detail       string   `arf:"1"`
+}
+
+func (DebugInfo) ArfStructID() string { return "arf.status/DebugInfo" }
+
+func init() {
+	proto.RegisterMessage(BadRequest{})
+	proto.RegisterMessage(FieldViolation{})
+	proto.RegisterMessage(RetryInfo{})
+	proto.RegisterMessage(QuotaFailure{})
+	proto.RegisterMessage(QuotaViolation{})
+	proto.RegisterMessage(DebugInfo{})
+}
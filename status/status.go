@@ -1,6 +1,9 @@
 package status
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 type Status int
 
@@ -55,12 +58,61 @@ var statusText = map[Status]string{
 type BadStatus struct {
 	Code    Status
 	Message string
+	// Details holds registered proto.Struct values further describing the
+	// failure, such as *BadRequest or *RetryInfo.
+	Details []any
+
+	// cause is the error converted into this status by Convert.
+	cause error
 }
 
 func (b *BadStatus) Error() string {
 	return fmt.Sprintf("BadStatus: %d (%s): %s", int(b.Code), b.Code.Error(), b.Message)
 }
 
+// Is reports whether target is the Status of b, allowing statuses to be
+// matched through errors.Is(err, status.NotFound).
+func (b *BadStatus) Is(target error) bool {
+	st, ok := target.(Status)
+	return ok && st == b.Code
+}
+
+func (b *BadStatus) Unwrap() error {
+	return b.cause
+}
+
 func Error(code Status, msg string) error {
-	return &BadStatus{code, msg}
+	return &BadStatus{Code: code, Message: msg}
+}
+
+// WithDetails returns a BadStatus with the given code and message, carrying
+// details to the caller. Each detail must be a registered proto.Struct.
+func WithDetails(code Status, msg string, details ...any) error {
+	return &BadStatus{Code: code, Message: msg, Details: details}
+}
+
+// carrier is implemented by errors that can be represented by a BadStatus,
+// such as *rpc.StreamError.
+type carrier interface {
+	BadStatus() *BadStatus
+}
+
+// FromError returns the BadStatus represented by err, including its
+// details. ok is false in case err does not carry a status.
+func FromError(err error) (bad *BadStatus, ok bool) {
+	var st Status
+	var c carrier
+	switch {
+	case err == nil:
+		return nil, false
+	case errors.As(err, &bad):
+		return bad, true
+	case errors.As(err, &c):
+		bad = c.BadStatus()
+		bad.cause = err
+		return bad, true
+	case errors.As(err, &st):
+		return &BadStatus{Code: st, Message: st.Error(), cause: err}, true
+	}
+	return nil, false
 }
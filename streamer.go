@@ -1,11 +1,19 @@
 package arf
 
+import (
+	"context"
+	"github.com/arf-rpc/arf-go/proto"
+	"github.com/arf-rpc/arf-go/status"
+)
+
 type InStreamer[T any] interface {
 	Recv() (T, error)
+	RecvContext(ctx context.Context) (T, error)
 }
 
 type OutStreamer[T any] interface {
 	Send(T) error
+	SendContext(ctx context.Context, v T) error
 	Close() error
 }
 
@@ -14,6 +22,8 @@ type InOutStreamer[I, O any] interface {
 	OutStreamer[O]
 }
 
+// MakeInOutStream wraps the bidirectional stream of c. When used by a
+// handler, it behaves as MakeOutStream does.
 func MakeInOutStream[I, O any](c Context) InOutStreamer[I, O] {
 	return &inOutStream[I, O]{
 		inStream:  MakeInStream[I](c).(*inStream[I]),
@@ -21,10 +31,17 @@ func MakeInOutStream[I, O any](c Context) InOutStreamer[I, O] {
 	}
 }
 
+// MakeInStream wraps the incoming stream of c. Received items are converted
+// into I as struct fields are when decoded; items that cannot be represented
+// as I fail Recv with the InvalidArgument status.
 func MakeInStream[I any](c Context) InStreamer[I] {
 	return &inStream[I]{c: c}
 }
 
+// MakeOutStream wraps the outgoing stream of c. When used by a handler that
+// did not send a response, the first Send or Close sends a streaming
+// response with the OK status. Handlers returning without closing the stream
+// end it implicitly, while returning an error terminates it with that status.
 func MakeOutStream[O any](c Context) OutStreamer[O] {
 	return &outStream[O]{c: c}
 }
@@ -44,17 +61,58 @@ func (i inStream[I]) Recv() (res I, err error) {
 	if err != nil {
 		return
 	}
-	return val.(I), nil
+	return convertItem[I](val)
+}
+
+func (i inStream[I]) RecvContext(ctx context.Context) (res I, err error) {
+	var val any
+	val, err = i.c.RecvContext(ctx)
+	if err != nil {
+		return
+	}
+	return convertItem[I](val)
+}
+
+// convertItem converts a received stream item into I, failing with the
+// InvalidArgument status in case it cannot be represented as such.
+func convertItem[I any](val any) (I, error) {
+	res, err := proto.Convert[I](val)
+	if err != nil {
+		return res, status.Convert(err)
+	}
+	return res, nil
 }
 
 type outStream[O any] struct {
 	c Context
 }
 
+// begin sends the streaming response when used by a handler that did not
+// send a response yet.
+func (o outStream[O]) begin() error {
+	if c, ok := unwrapContext(o.c).(*ctx); ok {
+		return c.beginStream()
+	}
+	return nil
+}
+
 func (o outStream[O]) Send(t O) error {
+	if err := o.begin(); err != nil {
+		return err
+	}
 	return o.c.Send(t)
 }
 
+func (o outStream[O]) SendContext(ctx context.Context, t O) error {
+	if err := o.begin(); err != nil {
+		return err
+	}
+	return o.c.SendContext(ctx, t)
+}
+
 func (o outStream[O]) Close() error {
+	if err := o.begin(); err != nil {
+		return err
+	}
 	return o.c.EndSend()
 }
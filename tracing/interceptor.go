@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"context"
+	"github.com/arf-rpc/arf-go"
+	"github.com/arf-rpc/arf-go/rpc"
+	"github.com/arf-rpc/arf-go/status"
+	"slices"
+)
+
+// Attribute keys set on spans and events created by the interceptors.
+const (
+	SystemKey      = "rpc.system"
+	ServiceKey     = "rpc.service"
+	MethodKey      = "rpc.method"
+	MessageTypeKey = "message.type"
+	MessageIDKey   = "message.id"
+)
+
+// MessageEvent is the name of the events recorded for every stream item
+// sent or received. Their MessageTypeKey attribute is either "SENT" or
+// "RECEIVED", and their MessageIDKey attribute counts items sent or received
+// in the call, starting at 1.
+const MessageEvent = "message"
+
+func startCall(ctx context.Context, tracer Tracer, service, method string, kind SpanKind) (context.Context, Span) {
+	ctx, span := tracer.Start(ctx, service+"/"+method, kind)
+	span.SetAttributes(
+		Attribute{Key: SystemKey, Value: "arf"},
+		Attribute{Key: ServiceKey, Value: service},
+		Attribute{Key: MethodKey, Value: method},
+	)
+	return ctx, span
+}
+
+// ClientInterceptor returns an interceptor tracing calls made by a client.
+// The span of each call is a child of the span carried by the context of
+// the call, and is propagated to the server through the request metadata.
+// It ends once the response is received, once the response stream ends,
+// either normally or by an error, or once the context of the call ends.
+func ClientInterceptor(tracer Tracer) arf.ClientInterceptor {
+	return func(ctx context.Context, service, method string, opts []arf.CallOption, next arf.Invoker) (arf.Context, error) {
+		ctx, span := startCall(ctx, tracer, service, method, SpanKindClient)
+
+		var md rpc.Metadata
+		Inject(&md, span.SpanContext())
+		call, err := next(ctx, service, method, append(slices.Clip(opts), arf.WithAdditionalMetadata(md))...)
+		if err != nil || call == nil {
+			endSpan(span, err)
+			return call, err
+		}
+
+		return arf.ObserveClientCall(ctx, call, spanObserver{span}), nil
+	}
+}
+
+// ServerInterceptor returns an interceptor tracing calls handled by a
+// server, as children of the span propagated by the client, if any. The
+// span is carried by the context passed to the handler, and ends once the
+// handler returns.
+func ServerInterceptor(tracer Tracer) arf.Interceptor {
+	return func(ctx context.Context, req arf.Context, next arf.Interceptor) error {
+		r := req.Request()
+		if sc, ok := Extract(r.Metadata); ok {
+			ctx = ContextWithRemoteSpanContext(ctx, sc)
+		}
+		ctx, span := startCall(ctx, tracer, r.Service, r.Method, SpanKindServer)
+
+		call := arf.ObserveServerCall(req, spanObserver{span})
+		err := next(ctx, call, nil)
+		if code, ok := call.SentStatus(); ok && err == nil {
+			span.SetStatus(code, "")
+			span.End()
+		} else {
+			endSpan(span, err)
+		}
+		return err
+	}
+}
+
+// endSpan records the status represented by err on span, and ends it.
+func endSpan(span Span, err error) {
+	if err == nil {
+		span.SetStatus(status.OK, "")
+	} else {
+		bad := status.Convert(err)
+		span.SetStatus(bad.Code, bad.Message)
+	}
+	span.End()
+}
+
+// spanObserver records events for the stream items of a call on its span,
+// and ends the span once a client call concludes.
+type spanObserver struct {
+	span Span
+}
+
+func (o spanObserver) ItemSent(n int) {
+	o.span.AddEvent(MessageEvent, Attribute{Key: MessageTypeKey, Value: "SENT"}, Attribute{Key: MessageIDKey, Value: n})
+}
+
+func (o spanObserver) ItemReceived(n int) {
+	o.span.AddEvent(MessageEvent, Attribute{Key: MessageTypeKey, Value: "RECEIVED"}, Attribute{Key: MessageIDKey, Value: n})
+}
+
+func (o spanObserver) CallEnded(_ *arf.ObservedCall, err error) {
+	endSpan(o.span, err)
+}
@@ -0,0 +1,179 @@
+package tracing
+
+import (
+	"context"
+	"github.com/arf-rpc/arf-go"
+	"github.com/arf-rpc/arf-go/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+	"time"
+)
+
+const testService = "org.example.test/Tracing"
+
+func makeClient(t *testing.T) (arf.Client, *InMemoryExporter, *InMemoryExporter) {
+	t.Helper()
+
+	serverSpans, clientSpans := &InMemoryExporter{}, &InMemoryExporter{}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server, err := arf.NewServer(l, arf.ServerOptions{})
+	require.NoError(t, err)
+	go func() { _ = server.Serve() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	server.RegisterInterceptor(ServerInterceptor(NewTracer(serverSpans)))
+	server.MustRegisterService(arf.ServiceAdapter{
+		ServiceID: testService,
+		Methods: map[string]arf.ServiceExecutor{
+			"Echo": func(ctx context.Context, c arf.Context) error {
+				if _, ok := SpanFromContext(ctx); !ok {
+					return status.Error(status.InternalError, "missing span")
+				}
+				return c.SendResponse(status.OK, c.Request().Params, false, nil)
+			},
+			"Fail": func(ctx context.Context, c arf.Context) error {
+				return status.Error(status.NotFound, "no such thing")
+			},
+			"Count": func(ctx context.Context, c arf.Context) error {
+				out := arf.MakeOutStream[string](c)
+				for _, v := range []string{"a", "b"} {
+					if err := out.Send(v); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			"Drain": func(ctx context.Context, c arf.Context) error {
+				in := arf.MakeInStream[string](c)
+				for {
+					if _, err := in.Recv(); err != nil {
+						break
+					}
+				}
+				return c.SendResponse(status.Aborted, nil, false, nil)
+			},
+		},
+	})
+
+	c, err := arf.Dial(l.Addr().String(), arf.WithInterceptors(ClientInterceptor(NewTracer(clientSpans))))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	return c, serverSpans, clientSpans
+}
+
+// onlySpan waits for exp to receive a span, and returns it.
+func onlySpan(t *testing.T, exp *InMemoryExporter) SpanData {
+	t.Helper()
+
+	require.Eventually(t, func() bool { return len(exp.Spans()) > 0 }, time.Second, 5*time.Millisecond)
+	spans := exp.Spans()
+	require.Len(t, spans, 1)
+	exp.Reset()
+	return spans[0]
+}
+
+// messages returns the type and identifier of the message events of span.
+func messages(span SpanData) [][]any {
+	var msgs [][]any
+	for _, e := range span.Events {
+		if e.Name == MessageEvent {
+			msgs = append(msgs, []any{e.Attributes[0].Value, e.Attributes[1].Value})
+		}
+	}
+	return msgs
+}
+
+func TestInterceptors(t *testing.T) {
+	c, serverSpans, clientSpans := makeClient(t)
+
+	t.Run("unary", func(t *testing.T) {
+		parentCtx, parent := NewTracer(nil).Start(context.Background(), "parent", SpanKindClient)
+		call, err := c.Call(parentCtx, testService, "Echo", arf.WithParams("hi"))
+		require.NoError(t, err)
+		_, err = call.Response().Result()
+		require.NoError(t, err)
+
+		client := onlySpan(t, clientSpans)
+		assert.Equal(t, testService+"/Echo", client.Name)
+		assert.Equal(t, SpanKindClient, client.Kind)
+		assert.Equal(t, status.OK, client.Code)
+		assert.Equal(t, parent.SpanContext(), client.Parent)
+		assert.Equal(t, parent.SpanContext().TraceID, client.SpanContext.TraceID)
+		assert.Contains(t, client.Attributes, Attribute{Key: MethodKey, Value: "Echo"})
+
+		server := onlySpan(t, serverSpans)
+		assert.Equal(t, SpanKindServer, server.Kind)
+		assert.Equal(t, status.OK, server.Code)
+		assert.Equal(t, client.SpanContext.TraceID, server.SpanContext.TraceID)
+		assert.Equal(t, client.SpanContext.SpanID, server.Parent.SpanID)
+		assert.True(t, server.Parent.Remote)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		_, err := c.Call(context.Background(), testService, "Fail")
+		require.NoError(t, err)
+
+		client := onlySpan(t, clientSpans)
+		assert.Equal(t, status.NotFound, client.Code)
+		assert.Equal(t, "no such thing", client.Message)
+		assert.False(t, client.Parent.IsValid())
+
+		server := onlySpan(t, serverSpans)
+		assert.Equal(t, status.NotFound, server.Code)
+		assert.Equal(t, "no such thing", server.Message)
+	})
+
+	t.Run("server streaming", func(t *testing.T) {
+		call, err := c.Call(context.Background(), testService, "Count")
+		require.NoError(t, err)
+		in := arf.MakeInStream[string](call)
+		for range 2 {
+			_, err = in.Recv()
+			require.NoError(t, err)
+		}
+		assert.Empty(t, clientSpans.Spans())
+		_, err = in.Recv()
+		require.Error(t, err)
+
+		client := onlySpan(t, clientSpans)
+		assert.Equal(t, status.OK, client.Code)
+		assert.Equal(t, [][]any{{"RECEIVED", 1}, {"RECEIVED", 2}}, messages(client))
+
+		server := onlySpan(t, serverSpans)
+		assert.Equal(t, status.OK, server.Code)
+		assert.Equal(t, [][]any{{"SENT", 1}, {"SENT", 2}}, messages(server))
+	})
+
+	t.Run("client streaming", func(t *testing.T) {
+		call, err := c.Call(context.Background(), testService, "Drain", arf.WithStream())
+		require.NoError(t, err)
+		out := arf.MakeOutStream[string](call)
+		require.NoError(t, out.Send("a"))
+		require.NoError(t, out.Close())
+		assert.Equal(t, uint16(status.Aborted), call.Response().Status)
+
+		client := onlySpan(t, clientSpans)
+		assert.Equal(t, status.Aborted, client.Code)
+		assert.Equal(t, [][]any{{"SENT", 1}}, messages(client))
+
+		server := onlySpan(t, serverSpans)
+		assert.Equal(t, status.Aborted, server.Code)
+		assert.Equal(t, [][]any{{"RECEIVED", 1}}, messages(server))
+	})
+
+	t.Run("calls returned without a Context end their span", func(t *testing.T) {
+		spans := &InMemoryExporter{}
+		intercept := ClientInterceptor(NewTracer(spans))
+		call, err := intercept(context.Background(), testService, "Echo", nil,
+			func(context.Context, string, string, ...arf.CallOption) (arf.Context, error) {
+				return nil, nil
+			})
+		assert.Nil(t, call)
+		assert.NoError(t, err)
+		assert.Equal(t, testService+"/Echo", onlySpan(t, spans).Name)
+	})
+}
@@ -0,0 +1,119 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"github.com/arf-rpc/arf-go/rpc"
+	"strings"
+)
+
+const (
+	// TraceparentKey is the metadata key carrying the trace and span
+	// identifiers of the caller, as defined by W3C Trace Context.
+	TraceparentKey = "traceparent"
+	// TracestateKey is the metadata key carrying vendor-specific trace
+	// data, as defined by W3C Trace Context.
+	TracestateKey = "tracestate"
+)
+
+// maxTraceStateMembers is the maximum amount of list members a tracestate
+// may carry.
+const maxTraceStateMembers = 32
+
+// Inject sets the traceparent and tracestate keys of md to represent sc,
+// replacing existing values. Nothing is set in case sc is not valid.
+func Inject(md *rpc.Metadata, sc SpanContext) {
+	if !sc.IsValid() {
+		return
+	}
+	md.SetString(TraceparentKey, formatTraceparent(sc))
+	if sc.TraceState != "" {
+		md.SetString(TracestateKey, sc.TraceState)
+	}
+}
+
+// Extract returns the span context carried by md. Metadata lacking a valid
+// traceparent carries no span context, in which case the tracestate is
+// ignored as well.
+func Extract(md rpc.Metadata) (SpanContext, bool) {
+	value, ok := md.LookupString(TraceparentKey)
+	if !ok {
+		return SpanContext{}, false
+	}
+	sc, ok := parseTraceparent(value)
+	if !ok {
+		return SpanContext{}, false
+	}
+
+	var members []string
+	for _, pair := range md {
+		if pair.Key == TracestateKey {
+			members = append(members, strings.Split(string(pair.Value), ",")...)
+		}
+	}
+	sc.TraceState = joinTraceState(members)
+	sc.Remote = true
+	return sc, true
+}
+
+func formatTraceparent(sc SpanContext) string {
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + hex.EncodeToString([]byte{sc.Flags})
+}
+
+// parseTraceparent parses value as a traceparent. Versions newer than 00
+// are parsed as 00, ignoring fields they append.
+func parseTraceparent(value string) (sc SpanContext, ok bool) {
+	value = strings.TrimSpace(value)
+	if len(value) < 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return sc, false
+	}
+
+	var version [1]byte
+	if !decodeHex(version[:], value[:2]) || version[0] == 0xff {
+		return sc, false
+	}
+	if version[0] == 0 && len(value) != 55 {
+		return sc, false
+	}
+	if len(value) > 55 && value[55] != '-' {
+		return sc, false
+	}
+
+	var flags [1]byte
+	if !decodeHex(sc.TraceID[:], value[3:35]) ||
+		!decodeHex(sc.SpanID[:], value[36:52]) ||
+		!decodeHex(flags[:], value[53:55]) {
+		return sc, false
+	}
+	sc.Flags = flags[0]
+	return sc, sc.IsValid()
+}
+
+// decodeHex decodes s into dst, accepting lowercase hexadecimal digits
+// only.
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+// joinTraceState joins the non-empty list members of a tracestate, keeping
+// the first occurrence of each key and at most maxTraceStateMembers.
+func joinTraceState(members []string) string {
+	seen := make(map[string]bool)
+	kept := make([]string, 0, len(members))
+	for _, m := range members {
+		m = strings.TrimSpace(m)
+		key, _, found := strings.Cut(m, "=")
+		if !found || key == "" || seen[key] {
+			continue
+		}
+		if len(kept) == maxTraceStateMembers {
+			break
+		}
+		seen[key] = true
+		kept = append(kept, m)
+	}
+	return strings.Join(kept, ",")
+}
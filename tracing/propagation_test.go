@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"github.com/arf-rpc/arf-go/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+const sampleTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestExtract(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		md := rpc.MetadataFromStringPairs(
+			TraceparentKey, sampleTraceparent,
+			TracestateKey, "congo=t61rcWkgMzE, rojo=00f067aa0ba902b7",
+			TracestateKey, "congo=ignored,other=1",
+		)
+		sc, ok := Extract(md)
+		require.True(t, ok)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+		assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+		assert.True(t, sc.Sampled())
+		assert.True(t, sc.Remote)
+		assert.Equal(t, "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7,other=1", sc.TraceState)
+	})
+
+	t.Run("future version", func(t *testing.T) {
+		sc, ok := Extract(rpc.MetadataFromStringPairs(TraceparentKey, "cc"+sampleTraceparent[2:]+"-what-the-future-holds"))
+		require.True(t, ok)
+		assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	})
+
+	invalid := map[string]string{
+		"version ff":     "ff" + sampleTraceparent[2:],
+		"trailing data":  sampleTraceparent + "-00",
+		"uppercase":      strings.ToUpper(sampleTraceparent),
+		"zero trace id":  "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"zero span id":   "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"bad separators": "00_4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7_01",
+		"short":          "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"not hex":        "00-4bf92f3577b34da6a3ce929d0e0e473g-00f067aa0ba902b7-01",
+	}
+	for name, value := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, ok := Extract(rpc.MetadataFromStringPairs(TraceparentKey, value, TracestateKey, "a=b"))
+			assert.False(t, ok)
+		})
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		_, ok := Extract(rpc.MetadataFromStringPairs(TracestateKey, "a=b"))
+		assert.False(t, ok)
+	})
+}
+
+func TestInject(t *testing.T) {
+	sc, ok := Extract(rpc.MetadataFromStringPairs(TraceparentKey, sampleTraceparent, TracestateKey, "a=b"))
+	require.True(t, ok)
+
+	md := rpc.MetadataFromStringPairs(TraceparentKey, "stale", "other", "kept")
+	Inject(&md, sc)
+	assert.Equal(t, sampleTraceparent, md.GetString(TraceparentKey))
+	assert.Equal(t, []string{sampleTraceparent}, md.GetAllString(TraceparentKey))
+	assert.Equal(t, "a=b", md.GetString(TracestateKey))
+	assert.Equal(t, "kept", md.GetString("other"))
+
+	var empty rpc.Metadata
+	Inject(&empty, SpanContext{})
+	assert.Empty(t, empty)
+}
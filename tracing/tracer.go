@@ -0,0 +1,169 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"github.com/arf-rpc/arf-go/status"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Event is an annotation recorded at a point in time during a span.
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes []Attribute
+}
+
+// SpanData is a snapshot of a span ended by a Tracer returned by NewTracer.
+type SpanData struct {
+	Name        string
+	Kind        SpanKind
+	SpanContext SpanContext
+	// Parent is the context of the parent span, and is not valid for root
+	// spans.
+	Parent     SpanContext
+	StartTime  time.Time
+	EndTime    time.Time
+	Attributes []Attribute
+	Events     []Event
+	Code       status.Status
+	Message    string
+}
+
+// Exporter receives spans once they end. ExportSpan is called synchronously
+// by End, and must not block.
+type Exporter interface {
+	ExportSpan(span SpanData)
+}
+
+// NewTracer returns a Tracer handing sampled spans to exporter once they
+// end. Spans inherit the trace flags and tracestate of their parent, and
+// root spans are sampled.
+func NewTracer(exporter Exporter) Tracer {
+	return &tracer{exporter: exporter}
+}
+
+type tracer struct {
+	exporter Exporter
+}
+
+func (t *tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, Span) {
+	parent := SpanContextFromContext(ctx)
+	sc := SpanContext{Flags: FlagSampled}
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.Flags = parent.Flags
+		sc.TraceState = parent.TraceState
+	} else {
+		sc.TraceID = newTraceID()
+	}
+	sc.SpanID = newSpanID()
+
+	s := &span{
+		exporter: t.exporter,
+		data: SpanData{
+			Name:        name,
+			Kind:        kind,
+			SpanContext: sc,
+			Parent:      parent,
+			StartTime:   time.Now(),
+		},
+	}
+	return ContextWithSpan(ctx, s), s
+}
+
+func newTraceID() (id TraceID) {
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return
+}
+
+func newSpanID() (id SpanID) {
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return
+}
+
+type span struct {
+	exporter Exporter
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+func (s *span) SpanContext() SpanContext {
+	return s.data.SpanContext
+}
+
+func (s *span) SetAttributes(attrs ...Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.data.Attributes = append(s.data.Attributes, attrs...)
+	}
+}
+
+func (s *span) AddEvent(name string, attrs ...Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.data.Events = append(s.data.Events, Event{Name: name, Time: time.Now(), Attributes: attrs})
+	}
+}
+
+func (s *span) SetStatus(code status.Status, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.data.Code = code
+		s.data.Message = message
+	}
+}
+
+func (s *span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if data.SpanContext.Sampled() && s.exporter != nil {
+		s.exporter.ExportSpan(data)
+	}
+}
+
+// InMemoryExporter keeps the spans it receives, allowing them to be
+// inspected by tests or debugging tools. The zero value is ready to use.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (e *InMemoryExporter) ExportSpan(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans returns the spans received so far, in the order they ended.
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Clone(e.spans)
+}
+
+// Reset discards the spans received so far.
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
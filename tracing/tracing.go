@@ -0,0 +1,121 @@
+// Package tracing provides client and server interceptors creating spans
+// around calls, and propagating them through the W3C Trace Context
+// traceparent and tracestate metadata. Spans are created by a Tracer, which
+// may adapt OpenTelemetry or any other tracing library; NewTracer provides
+// one handing finished spans to an Exporter.
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"github.com/arf-rpc/arf-go/status"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// FlagSampled is the trace flag marking a trace as being recorded.
+const FlagSampled byte = 0x01
+
+// SpanContext is the portion of a span propagated to other processes.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   byte
+	// TraceState holds the vendor-specific tracestate list, as propagated.
+	TraceState string
+	// Remote is set for span contexts extracted from a request.
+	Remote bool
+}
+
+// IsValid reports whether s has both a trace and a span identifier.
+func (s SpanContext) IsValid() bool {
+	return s.TraceID.IsValid() && s.SpanID.IsValid()
+}
+
+func (s SpanContext) Sampled() bool {
+	return s.Flags&FlagSampled != 0
+}
+
+type SpanKind int
+
+const (
+	SpanKindClient SpanKind = iota
+	SpanKindServer
+)
+
+func (k SpanKind) String() string {
+	if k == SpanKindServer {
+		return "server"
+	}
+	return "client"
+}
+
+// Attribute annotates a span or an event.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// Span is an operation being traced. Its methods may be called concurrently.
+type Span interface {
+	SpanContext() SpanContext
+	SetAttributes(attrs ...Attribute)
+	AddEvent(name string, attrs ...Attribute)
+	// SetStatus records the status the operation concluded with.
+	SetStatus(code status.Status, message string)
+	// End concludes the span. Calls made after the first one are ignored.
+	End()
+}
+
+// Tracer creates spans.
+type Tracer interface {
+	// Start creates a span named name, child of the span returned by
+	// SpanContextFromContext for ctx, if any. It returns a copy of ctx
+	// carrying the new span, to be used by operations it encloses.
+	Start(ctx context.Context, name string, kind SpanKind) (context.Context, Span)
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying span.
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span carried by ctx.
+func SpanFromContext(ctx context.Context) (Span, bool) {
+	span, ok := ctx.Value(spanKey{}).(Span)
+	return span, ok
+}
+
+// ContextWithRemoteSpanContext returns a copy of ctx carrying sc, the span
+// context of the caller, as extracted by the server interceptor.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanContextFromContext returns the context of the span carried by ctx, or
+// the remote span context it carries otherwise. The result is not valid if
+// ctx carries neither.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span, ok := SpanFromContext(ctx); ok {
+		return span.SpanContext()
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
@@ -3,19 +3,32 @@ package wire
 import (
 	"io"
 	"sync"
+	"sync/atomic"
 )
 
+// block is a payload enqueued into a BlockReader, along with whether it
+// concludes a message.
+type block struct {
+	data    []byte
+	endData bool
+}
+
 type BlockReader struct {
-	blocks chan []byte
+	blocks chan block
 	buf    []byte
+	bufEnd bool
 
 	closedMu sync.Mutex
 	closed   bool
+	err      error
+
+	// buffered counts bytes enqueued and not yet read.
+	buffered atomic.Int64
 }
 
 func NewBlockReader() *BlockReader {
 	return &BlockReader{
-		blocks: make(chan []byte, 128),
+		blocks: make(chan block, 128),
 	}
 }
 
@@ -29,7 +42,34 @@ func (r *BlockReader) internalClose() {
 	close(r.blocks)
 }
 
+// fail closes the reader, causing further reads to return err instead of
+// io.EOF.
+func (r *BlockReader) fail(err error) {
+	r.closedMu.Lock()
+	defer r.closedMu.Unlock()
+	if r.closed {
+		return
+	}
+	r.err = err
+	r.closed = true
+	close(r.blocks)
+}
+
+func (r *BlockReader) closedErr() error {
+	if r.err != nil {
+		return r.err
+	}
+	return io.EOF
+}
+
+// Enqueue appends data to the reader as a complete message.
 func (r *BlockReader) Enqueue(data []byte) {
+	r.enqueue(data, true)
+}
+
+// enqueue appends data to the reader. endData indicates whether data
+// concludes a message.
+func (r *BlockReader) enqueue(data []byte, endData bool) {
 	if len(data) == 0 {
 		return
 	}
@@ -39,7 +79,13 @@ func (r *BlockReader) Enqueue(data []byte) {
 	if r.closed {
 		return
 	}
-	r.blocks <- data
+	r.buffered.Add(int64(len(data)))
+	r.blocks <- block{data: data, endData: endData}
+}
+
+// Buffered returns the amount of bytes enqueued and not yet read.
+func (r *BlockReader) Buffered() int {
+	return int(r.buffered.Load())
 }
 
 func (r *BlockReader) Close() error {
@@ -53,66 +99,88 @@ func (r *BlockReader) clearBuffer() {
 	}
 }
 
-func (r *BlockReader) TryRead(into []byte) (bool, int, error) {
-	defer r.clearBuffer()
-	if r.buf == nil {
-		r.closedMu.Lock()
-		if r.closed {
-			r.closedMu.Unlock()
-			return true, 0, io.EOF
-		}
+// load ensures a block is available to be read. In case wait is false and
+// no block is enqueued, returns false.
+func (r *BlockReader) load(wait bool) (bool, error) {
+	if r.buf != nil {
+		return true, nil
+	}
+	r.closedMu.Lock()
+	if r.closed {
 		r.closedMu.Unlock()
+		return true, r.closedErr()
+	}
+	r.closedMu.Unlock()
+
+	var b block
+	var ok bool
+	if wait {
+		b, ok = <-r.blocks
+	} else {
 		select {
-		case r.buf = <-r.blocks:
-			if r.buf == nil {
-				return true, 0, io.EOF
-			}
+		case b, ok = <-r.blocks:
 		default:
-			return false, 0, nil
+			return false, nil
 		}
 	}
-	intoLen := len(into)
-
-	if len(r.buf) >= intoLen {
-		copy(into, r.buf[:intoLen])
-		r.buf = r.buf[intoLen:]
-		return true, intoLen, nil
+	if !ok {
+		return true, r.closedErr()
 	}
+	r.buf, r.bufEnd = b.data, b.endData
+	return true, nil
+}
+
+// take consumes up to len(into) bytes of the current block.
+func (r *BlockReader) take(into []byte) int {
+	n := copy(into, r.buf)
+	r.buf = r.buf[n:]
+	r.buffered.Add(-int64(n))
+	return n
+}
+
+// takeBlock consumes the remainder of the current block.
+func (r *BlockReader) takeBlock() ([]byte, bool) {
+	data := r.buf
+	r.buf = r.buf[len(data):]
+	r.buffered.Add(-int64(len(data)))
+	return data, r.bufEnd
+}
 
-	// buf length is smaller than `into`. Copy what we have, and return a
-	// partial read.
-	l := len(r.buf)
-	copy(into, r.buf[:l])
-	r.buf = r.buf[l:]
-	return true, l, nil
+func (r *BlockReader) TryRead(into []byte) (bool, int, error) {
+	defer r.clearBuffer()
+	if ok, err := r.load(false); !ok || err != nil {
+		return ok, 0, err
+	}
+	return true, r.take(into), nil
 }
 
 func (r *BlockReader) Read(into []byte) (int, error) {
 	defer r.clearBuffer()
-	if r.buf == nil {
-		r.closedMu.Lock()
-		if r.closed {
-			r.closedMu.Unlock()
-			return 0, io.EOF
-		}
-		r.closedMu.Unlock()
-		r.buf = <-r.blocks
-		if r.buf == nil {
-			return 0, io.EOF
-		}
+	if _, err := r.load(true); err != nil {
+		return 0, err
 	}
-	intoLen := len(into)
+	return r.take(into), nil
+}
 
-	if len(r.buf) >= intoLen {
-		copy(into, r.buf[:intoLen])
-		r.buf = r.buf[intoLen:]
-		return intoLen, nil
+// TryReadBlock returns the unread remainder of the next block as ReadBlock
+// does, without waiting for one to be enqueued. Returns false in case none
+// is available.
+func (r *BlockReader) TryReadBlock() (ok bool, data []byte, endData bool, err error) {
+	defer r.clearBuffer()
+	if ok, err = r.load(false); !ok || err != nil {
+		return ok, nil, false, err
 	}
+	data, endData = r.takeBlock()
+	return true, data, endData, nil
+}
 
-	// buf length is smaller than `into`. Copy what we have, and return a
-	// partial read.
-	l := len(r.buf)
-	copy(into, r.buf[:l])
-	r.buf = r.buf[l:]
-	return l, nil
+// ReadBlock returns the unread remainder of the next block, and whether it
+// concludes a message.
+func (r *BlockReader) ReadBlock() (data []byte, endData bool, err error) {
+	defer r.clearBuffer()
+	if _, err = r.load(true); err != nil {
+		return nil, false, err
+	}
+	data, endData = r.takeBlock()
+	return data, endData, nil
 }
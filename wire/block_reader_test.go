@@ -15,16 +15,19 @@ func TestBlockReader(t *testing.T) {
 	r.Enqueue(a)
 	r.Enqueue(b)
 	r.Enqueue(c)
+	assert.Equal(t, 24, r.Buffered())
 
 	firstRead := make([]byte, 16)
 	n, err := io.ReadFull(r, firstRead)
 	assert.Equal(t, 16, n)
 	assert.Nil(t, err)
 	assert.Equal(t, bytes.Join([][]byte{a, b}, nil), firstRead)
+	assert.Equal(t, 8, r.Buffered())
 
 	secondRead := make([]byte, 16)
 	n, err = r.Read(secondRead)
 	assert.Equal(t, 8, n)
 	assert.Nil(t, err)
 	assert.Equal(t, c, secondRead[:n])
+	assert.Zero(t, r.Buffered())
 }
@@ -0,0 +1,186 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Direction tells whether a frame was received or sent.
+type Direction uint8
+
+const (
+	DirectionIn Direction = iota
+	DirectionOut
+)
+
+func (d Direction) String() string {
+	if d == DirectionOut {
+		return "out"
+	}
+	return "in"
+}
+
+// FrameTap observes every frame exchanged through a connection. Inbound
+// frames are observed once decompressed, and outbound frames once written,
+// before being compressed. TapFrame is called synchronously by the
+// goroutines servicing the connection; it must not block, nor retain fr
+// after returning. connID is the ID reported by the ConnInfo of the
+// connection.
+type FrameTap interface {
+	TapFrame(connID int, dir Direction, fr *Frame)
+}
+
+// tapOut reports an outbound frame whose payload was payload before being
+// compressed.
+func tapOut(tap FrameTap, connID int, fr *Frame, payload []byte) {
+	tap.TapFrame(connID, DirectionOut, &Frame{
+		StreamID:  fr.StreamID,
+		FrameKind: fr.FrameKind,
+		Flags:     fr.Flags,
+		Length:    uint16(len(payload)),
+		Payload:   payload,
+	})
+}
+
+// captureMagic starts every capture file, followed by captureVersion.
+var captureMagic = []byte("arfcap")
+
+const captureVersion = 1
+
+// captureRecordHeaderLen is the size of the fixed portion of a record:
+// timestamp (8), connection ID (4), direction (1), stream ID (4), kind (1),
+// flags (1) and payload length (4).
+const captureRecordHeaderLen = 23
+
+// CapturedFrame is a frame read from a capture.
+type CapturedFrame struct {
+	Time      time.Time
+	ConnID    int
+	Direction Direction
+	Frame     *Frame
+}
+
+// InvalidCaptureError is returned by NewCaptureReader for data lacking the
+// capture header, or using an unsupported version.
+type InvalidCaptureError struct {
+	Reason string
+}
+
+func (e *InvalidCaptureError) Error() string {
+	return "invalid capture: " + e.Reason
+}
+
+// CaptureWriter is a FrameTap recording frames to an io.Writer, in a format
+// read by CaptureReader. Each frame is recorded through a single Write
+// call; wrap the destination in a bufio.Writer to reduce their amount, and
+// flush it once done capturing. A single CaptureWriter may be shared by
+// several connections.
+type CaptureWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	buf []byte
+	err error
+}
+
+// NewCaptureWriter writes the capture header to w, returning a
+// CaptureWriter recording frames after it.
+func NewCaptureWriter(w io.Writer) (*CaptureWriter, error) {
+	header := append(bytes.Clone(captureMagic), captureVersion)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &CaptureWriter{w: w}, nil
+}
+
+func (c *CaptureWriter) TapFrame(connID int, dir Direction, fr *Frame) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+
+	buf := c.buf[:0]
+	buf = binary.BigEndian.AppendUint64(buf, uint64(time.Now().UnixNano()))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(connID))
+	buf = append(buf, byte(dir))
+	buf = binary.BigEndian.AppendUint32(buf, fr.StreamID)
+	buf = append(buf, byte(fr.FrameKind), fr.Flags)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(fr.Payload)))
+	buf = append(buf, fr.Payload...)
+	c.buf = buf
+
+	_, c.err = c.w.Write(buf)
+}
+
+// Err returns the first error returned by the underlying writer, after
+// which frames are no longer recorded.
+func (c *CaptureWriter) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// CaptureReader reads frames recorded by a CaptureWriter. Their payloads
+// are uncompressed, so frames can be replayed by encoding them with
+// CompressionMethodNone.
+type CaptureReader struct {
+	r io.Reader
+}
+
+// NewCaptureReader reads the capture header from r, returning a
+// CaptureReader for the frames following it.
+func NewCaptureReader(r io.Reader) (*CaptureReader, error) {
+	header := make([]byte, len(captureMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, &InvalidCaptureError{Reason: "missing header"}
+		}
+		return nil, err
+	}
+	if !bytes.Equal(header[:len(captureMagic)], captureMagic) {
+		return nil, &InvalidCaptureError{Reason: "magic number mismatch"}
+	}
+	if v := header[len(captureMagic)]; v != captureVersion {
+		return nil, &InvalidCaptureError{Reason: fmt.Sprintf("unsupported version %d", v)}
+	}
+	return &CaptureReader{r: r}, nil
+}
+
+// Next returns the next frame of the capture, or io.EOF once all were read.
+// Captures truncated within a record fail with io.ErrUnexpectedEOF.
+func (c *CaptureReader) Next() (*CapturedFrame, error) {
+	var header [captureRecordHeaderLen]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[19:23])
+	if length > maxPayload {
+		return nil, &InvalidCaptureError{Reason: fmt.Sprintf("record length %d exceeds the maximum frame payload", length)}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return &CapturedFrame{
+		Time:      time.Unix(0, int64(binary.BigEndian.Uint64(header[0:8]))),
+		ConnID:    int(binary.BigEndian.Uint32(header[8:12])),
+		Direction: Direction(header[12]),
+		Frame: &Frame{
+			StreamID:  binary.BigEndian.Uint32(header[13:17]),
+			FrameKind: FrameKind(header[17]),
+			Flags:     header[18],
+			Length:    uint16(len(payload)),
+			Payload:   payload,
+		},
+	}, nil
+}
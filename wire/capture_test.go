@@ -0,0 +1,161 @@
+package wire
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type tappedFrame struct {
+	connID int
+	dir    Direction
+	frame  Frame
+}
+
+type recordingTap struct {
+	mu     sync.Mutex
+	frames []tappedFrame
+}
+
+func (r *recordingTap) TapFrame(connID int, dir Direction, fr *Frame) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := *fr
+	cp.Payload = bytes.Clone(fr.Payload)
+	r.frames = append(r.frames, tappedFrame{connID: connID, dir: dir, frame: cp})
+}
+
+func (r *recordingTap) find(dir Direction, kind FrameKind) (tappedFrame, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.frames {
+		if f.dir == dir && f.frame.FrameKind == kind {
+			return f, true
+		}
+	}
+	return tappedFrame{}, false
+}
+
+func TestCapture(t *testing.T) {
+	t.Run("frames are read back as written", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewCaptureWriter(&buf)
+		require.NoError(t, err)
+
+		before := time.Now()
+		w.TapFrame(3, DirectionOut, (&DataFrame{StreamID: 5, EndData: true, Payload: []byte("hello")}).IntoFrame())
+		w.TapFrame(3, DirectionIn, (&PingFrame{Ack: true, Payload: []byte{1, 2, 3, 4, 5, 6, 7, 8}}).IntoFrame())
+		require.NoError(t, w.Err())
+
+		r, err := NewCaptureReader(&buf)
+		require.NoError(t, err)
+
+		c, err := r.Next()
+		require.NoError(t, err)
+		assert.False(t, c.Time.Before(before.Truncate(time.Nanosecond)))
+		assert.Equal(t, 3, c.ConnID)
+		assert.Equal(t, DirectionOut, c.Direction)
+		assert.Equal(t, &Frame{StreamID: 5, FrameKind: FrameKindData, Flags: 0x02, Length: 5, Payload: []byte("hello")}, c.Frame)
+
+		c, err = r.Next()
+		require.NoError(t, err)
+		assert.Equal(t, DirectionIn, c.Direction)
+		ping := &PingFrame{}
+		require.NoError(t, ping.FromFrame(c.Frame))
+		assert.True(t, ping.Ack)
+
+		_, err = r.Next()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("truncated records are reported", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewCaptureWriter(&buf)
+		require.NoError(t, err)
+		w.TapFrame(1, DirectionIn, (&DataFrame{StreamID: 1, Payload: []byte("hello")}).IntoFrame())
+
+		r, err := NewCaptureReader(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+		require.NoError(t, err)
+		_, err = r.Next()
+		assert.Equal(t, io.ErrUnexpectedEOF, err)
+	})
+
+	t.Run("records longer than a frame payload are rejected", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewCaptureWriter(&buf)
+		require.NoError(t, err)
+		w.TapFrame(1, DirectionIn, (&DataFrame{StreamID: 1, Payload: []byte("hello")}).IntoFrame())
+
+		data := buf.Bytes()
+		copy(data[len(data)-len("hello")-4:], []byte{0xff, 0xff, 0xff, 0xff})
+		r, err := NewCaptureReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		_, err = r.Next()
+		assert.Equal(t, &InvalidCaptureError{Reason: "record length 4294967295 exceeds the maximum frame payload"}, err)
+	})
+
+	t.Run("data without a capture header is rejected", func(t *testing.T) {
+		_, err := NewCaptureReader(bytes.NewReader([]byte("arf")))
+		assert.Equal(t, &InvalidCaptureError{Reason: "missing header"}, err)
+
+		_, err = NewCaptureReader(bytes.NewReader([]byte("arfcat\x01")))
+		assert.Equal(t, &InvalidCaptureError{Reason: "magic number mismatch"}, err)
+
+		_, err = NewCaptureReader(bytes.NewReader([]byte("arfcap\x02")))
+		assert.Equal(t, &InvalidCaptureError{Reason: "unsupported version 2"}, err)
+	})
+
+	t.Run("taps observe uncompressed frames in both directions", func(t *testing.T) {
+		clientTap, connTap := &recordingTap{}, &recordingTap{}
+		local, remote := net.Pipe()
+		cli := NewClient(local, WithFrameTap(clientTap))
+		conn := NewConn(nil, 7, remote, WithFrameTap(connTap))
+
+		require.NoError(t, cli.Configure(CompressionMethodGzip))
+		str, err := cli.NewStream()
+		require.NoError(t, err)
+		require.NoError(t, str.Write([]byte("hello"), false))
+
+		waitFor(t, "DATA to be received", 3*time.Second, func() bool {
+			_, ok := connTap.find(DirectionIn, FrameKindData)
+			return ok
+		})
+
+		out, ok := clientTap.find(DirectionOut, FrameKindData)
+		require.True(t, ok)
+		assert.Equal(t, cli.(*client).id, out.connID)
+		assert.Equal(t, []byte("hello"), out.frame.Payload)
+		assert.Equal(t, uint16(5), out.frame.Length)
+
+		in, _ := connTap.find(DirectionIn, FrameKindData)
+		assert.Equal(t, 7, in.connID)
+		assert.Equal(t, out.frame, in.frame)
+
+		_, ok = connTap.find(DirectionOut, FrameKindHello)
+		assert.True(t, ok)
+		_, ok = clientTap.find(DirectionIn, FrameKindHello)
+		assert.True(t, ok)
+
+		require.NoError(t, cli.Terminate(ErrorCodeNoError))
+		waitFor(t, "connection to terminate", 3*time.Second, func() bool {
+			_, ok := connTap.find(DirectionIn, FrameKindGoAway)
+			return ok
+		})
+		_ = conn
+	})
+
+	t.Run("client connections are told apart", func(t *testing.T) {
+		a, _ := net.Pipe()
+		b, _ := net.Pipe()
+		first, second := NewClient(a), NewClient(b)
+		t.Cleanup(func() { _ = a.Close(); _ = b.Close() })
+
+		assert.Positive(t, first.(*client).Info().ID)
+		assert.Greater(t, second.(*client).Info().ID, first.(*client).Info().ID)
+	})
+}
@@ -2,47 +2,82 @@ package wire
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
+	"github.com/go-stdlog/stdlog"
 	"io"
 	"sync"
+	"sync/atomic"
 )
 
+// clientConnIDs numbers the client connections of the process, starting at
+// 1.
+var clientConnIDs atomic.Int64
+
 type Client interface {
 	Configure(compression CompressionMethod) error
+	// ConfigureContext performs the HELLO exchange like Configure, failing
+	// in case ctx is done or the connection is terminated before the server
+	// acknowledges it.
+	ConfigureContext(ctx context.Context, compression CompressionMethod) error
 	Close() error
 	Write(*Frame) error
 	NewStream() (Stream, error)
 	Terminate(reason ErrorCode) error
+
+	// Ping sends a PING frame and waits for the server to acknowledge it.
+	Ping(ctx context.Context) error
+	// Done returns a channel closed once the connection is terminated,
+	// either locally, by a GOAWAY frame, or by a transport error.
+	Done() <-chan struct{}
+	// Err returns the error that terminated the connection, or nil in case
+	// it is still usable.
+	Err() error
+	// ActiveStreams returns the amount of streams not yet closed.
+	ActiveStreams() int
 }
 
 type client struct {
+	id            int
 	writeMu       *FairMutex
 	io            io.ReadWriteCloser
 	toWrite       chan *outboundFrame
+	writerDone    chan struct{}
 	signalHelloOK func()
 	helloOK       chan struct{}
 	drop          chan struct{}
 	reader        *FrameReader
 
-	compression CompressionMethod
+	compression atomicCompression
 	err         error
 
-	streamsMu    sync.Mutex
-	streams      map[uint32]Stream
-	lastStreamID uint32
+	streamsMu     sync.Mutex
+	streams       map[uint32]Stream
+	lastStreamID  uint32
+	activeStreams atomic.Int64
 
 	runningMu sync.Mutex
 	running   bool
 
 	setup                bool
 	maxConcurrentStreams uint32
+
+	pingMu  sync.Mutex
+	pingSeq uint64
+	pings   map[uint64]chan struct{}
+
+	opts Options
+	log  stdlog.Logger
 }
 
-func NewClient(conn io.ReadWriteCloser) Client {
+func NewClient(conn io.ReadWriteCloser, opts ...Option) Client {
 	helloOk := make(chan struct{})
 	c := &client{
-		writeMu: NewFairMutex(),
-		io:      conn,
-		toWrite: make(chan *outboundFrame, 128),
+		id:         int(clientConnIDs.Add(1)),
+		writeMu:    NewFairMutex(),
+		io:         conn,
+		toWrite:    make(chan *outboundFrame, 128),
+		writerDone: make(chan struct{}),
 		signalHelloOK: sync.OnceFunc(func() {
 			close(helloOk)
 		}),
@@ -50,28 +85,61 @@ func NewClient(conn io.ReadWriteCloser) Client {
 		drop:    make(chan struct{}),
 		reader:  NewFrameReader(conn),
 		streams: make(map[uint32]Stream),
+		pings:   make(map[uint64]chan struct{}),
+		opts:    makeOptions(opts),
+	}
+	c.log = c.opts.logger()
+	if h := c.opts.StatsHandler; h != nil {
+		h.ConnBegin(c.Info())
 	}
 	go func() {
 		err := c.service()
+		c.runningMu.Lock()
 		if c.err == nil {
 			c.err = err
 		}
+		err = c.err
+		c.runningMu.Unlock()
+		if h := c.opts.StatsHandler; h != nil {
+			h.ConnEnd(c.Info(), err)
+		}
 	}()
 
 	return c
 }
 
 func (c *client) Configure(compression CompressionMethod) error {
-	err := c.Write((&HelloFrame{
+	return c.ConfigureContext(context.Background(), compression)
+}
+
+func (c *client) ConfigureContext(ctx context.Context, compression CompressionMethod) error {
+	err := c.WriteContext(ctx, (&HelloFrame{
 		CompressionGZip:      compression == CompressionMethodGzip,
 		Ack:                  false,
 		MaxConcurrentStreams: 0,
 	}).IntoFrame())
 	if err != nil {
+		if ctx.Err() != nil {
+			// The peer may not be reading at all; closing the connection
+			// releases the writer.
+			_ = c.Close()
+		}
 		return err
 	}
-	c.waitForHello()
-	return nil
+
+	select {
+	case <-c.helloOK:
+		if !c.setup {
+			// Closed before the server acknowledged the HELLO frame.
+			return ClosedConnErr
+		}
+		return nil
+	case <-c.drop:
+		return c.Err()
+	case <-ctx.Done():
+		_ = c.Close()
+		return ctx.Err()
+	}
 }
 
 func (c *client) Close() error {
@@ -81,21 +149,19 @@ func (c *client) Close() error {
 }
 
 func (c *client) Write(frame *Frame) error {
-	if c.err != nil {
-		return c.err
-	}
-	out := outboundFramePool.Get().(*outboundFrame)
-	defer outboundFramePool.Put(out)
+	return c.WriteContext(context.Background(), frame)
+}
 
-	out.frame = frame
-	out.result = make(chan error, 1)
-	c.toWrite <- out
-	return <-out.result
+func (c *client) WriteContext(ctx context.Context, frame *Frame) error {
+	if err := c.loadErr(); err != nil {
+		return err
+	}
+	return writeFrame(ctx, c.toWrite, c.writerDone, frame)
 }
 
 func (c *client) NewStream() (Stream, error) {
-	if c.err != nil {
-		return nil, c.err
+	if err := c.loadErr(); err != nil {
+		return nil, err
 	}
 
 	c.streamsMu.Lock()
@@ -107,7 +173,9 @@ func (c *client) NewStream() (Stream, error) {
 		return nil, err
 	}
 
-	str := NewStream(id, c)
+	str := newStream(id, c, c.opts)
+	c.activeStreams.Add(1)
+	str.state.onClosed = func() { c.activeStreams.Add(-1) }
 
 	c.streamsMu.Lock()
 	c.streams[id] = str
@@ -117,8 +185,8 @@ func (c *client) NewStream() (Stream, error) {
 }
 
 func (c *client) Terminate(reason ErrorCode) error {
-	if c.err != nil {
-		return c.err
+	if err := c.loadErr(); err != nil {
+		return err
 	}
 
 	c.streamsMu.Lock()
@@ -139,8 +207,22 @@ func (c *client) terminate() {
 	}
 
 	c.running = false
-	close(c.toWrite)
 	close(c.drop)
+	c.cancelStreams(ErrorCodeCancel)
+}
+
+// setErr records err as the reason the connection stopped.
+func (c *client) setErr(err error) {
+	c.runningMu.Lock()
+	defer c.runningMu.Unlock()
+	c.err = err
+}
+
+// loadErr returns the error recorded by setErr, if any.
+func (c *client) loadErr() error {
+	c.runningMu.Lock()
+	defer c.runningMu.Unlock()
+	return c.err
 }
 
 func (c *client) service() error {
@@ -165,13 +247,23 @@ func (c *client) service() error {
 
 func (c *client) serviceWrites() {
 loop:
-	for out := range c.toWrite {
+	for {
+		var out *outboundFrame
+		select {
+		case <-c.drop:
+			drainWrites(c.toWrite)
+			close(c.writerDone)
+			return
+		case out = <-c.toWrite:
+		}
+
 		c.writeMu.Lock()
-		buf := bytes.NewReader(out.frame.Bytes(c.compression))
-		_, err := io.Copy(c.io, buf)
+		payload := out.frame.Payload
+		data := out.frame.Bytes(c.compression.Load())
+		_, err := io.Copy(c.io, bytes.NewReader(data))
 		if err != nil {
-			if c.err != nil {
-				out.result <- c.err
+			if err := c.loadErr(); err != nil {
+				out.result <- err
 				c.writeMu.Unlock()
 				continue loop
 			}
@@ -179,7 +271,8 @@ loop:
 			if !c.running {
 				c.runningMu.Unlock()
 				c.writeMu.Unlock()
-				return
+				out.result <- ClosedConnErr
+				continue loop
 			}
 			c.runningMu.Unlock()
 
@@ -188,6 +281,12 @@ loop:
 			continue loop
 		}
 		c.writeMu.Unlock()
+		if h := c.opts.StatsHandler; h != nil {
+			h.FrameOut(outStats(out.frame, len(payload), data))
+		}
+		if t := c.opts.FrameTap; t != nil {
+			tapOut(t, c.id, out.frame, payload)
+		}
 		out.result <- nil
 	}
 }
@@ -220,6 +319,16 @@ func (c *client) serviceReads(errCh chan error) {
 
 func (c *client) dispatch(fr *Frame) error {
 	var err error
+	compressedSize := len(fr.Payload)
+	if err = fr.Decompress(c.compression.Load()); err != nil {
+		return err
+	}
+	if h := c.opts.StatsHandler; h != nil {
+		h.FrameIn(inStats(fr, compressedSize))
+	}
+	if t := c.opts.FrameTap; t != nil {
+		t.TapFrame(c.id, DirectionIn, fr)
+	}
 	if fr.FrameKind != FrameKindHello && fr.FrameKind != FrameKindPing && fr.FrameKind != FrameKindResetStream && fr.FrameKind != FrameKindGoAway && !c.setup {
 		c.reset(ErrorCodeProtocolError, "Expected a HELLO frame, received "+fr.FrameKind.String()+" instead")
 		return nil
@@ -277,8 +386,8 @@ func (c *client) dispatch(fr *Frame) error {
 }
 
 func (c *client) reset(reason ErrorCode, details string) {
-	c.err = &ConnectionResetError{Reason: reason, Details: details}
-	if c.err != nil {
+	c.setErr(&ConnectionResetError{Reason: reason, Details: details})
+	if c.loadErr() != nil {
 		return
 	}
 
@@ -290,15 +399,80 @@ func (c *client) reset(reason ErrorCode, details string) {
 
 	c.writeMu.Lock()
 	defer c.writeMu.Unlock()
-	r := bytes.NewReader(fr.IntoFrame().Bytes(c.compression))
+	out := fr.IntoFrame()
+	payload := out.Payload
+	r := bytes.NewReader(out.Bytes(c.compression.Load()))
 	_, err := io.Copy(c.io, r)
 	if err != nil {
-		// TODO: Log
+		c.log.Error(err, "Failed sending GOAWAY", "code", reason)
+	} else if t := c.opts.FrameTap; t != nil {
+		tapOut(t, c.id, out, payload)
+	}
+}
+
+func (c *client) Ping(ctx context.Context) error {
+	c.pingMu.Lock()
+	c.pingSeq++
+	id := c.pingSeq
+	ack := make(chan struct{})
+	c.pings[id] = ack
+	c.pingMu.Unlock()
+
+	defer func() {
+		c.pingMu.Lock()
+		delete(c.pings, id)
+		c.pingMu.Unlock()
+	}()
+
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint64(payload, id)
+	if err := c.Write((&PingFrame{Payload: payload}).IntoFrame()); err != nil {
+		return err
+	}
+
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.drop:
+		return c.Err()
 	}
 }
 
+func (c *client) Info() ConnInfo {
+	return connInfo(c.id, c.io, c.compression.Load())
+}
+
+func (c *client) Done() <-chan struct{} { return c.drop }
+
+func (c *client) Err() error {
+	if err := c.loadErr(); err != nil {
+		return err
+	}
+	select {
+	case <-c.drop:
+		return ClosedConnErr
+	default:
+		return nil
+	}
+}
+
+func (c *client) ActiveStreams() int {
+	return int(c.activeStreams.Load())
+}
+
 func (c *client) handlePing(fr *PingFrame) error {
 	if fr.Ack {
+		if len(fr.Payload) == 8 {
+			c.pingMu.Lock()
+			ack, ok := c.pings[binary.BigEndian.Uint64(fr.Payload)]
+			delete(c.pings, binary.BigEndian.Uint64(fr.Payload))
+			c.pingMu.Unlock()
+			if ok {
+				close(ack)
+			}
+		}
 		return nil
 	}
 
@@ -309,32 +483,54 @@ func (c *client) handlePing(fr *PingFrame) error {
 
 	c.writeMu.Lock()
 	defer c.writeMu.Unlock()
-	r := bytes.NewReader(pong.IntoFrame().Bytes(c.compression))
+	out := pong.IntoFrame()
+	payload := out.Payload
+	r := bytes.NewReader(out.Bytes(c.compression.Load()))
 	_, err := io.Copy(c.io, r)
+	if err == nil {
+		if t := c.opts.FrameTap; t != nil {
+			tapOut(t, c.id, out, payload)
+		}
+	}
 	return err
 }
 
 func (c *client) handleGoAway(fr *GoAwayFrame) error {
-	if !c.running {
+	c.runningMu.Lock()
+	running := c.running
+	c.runningMu.Unlock()
+	if !running {
 		return nil
 	}
-	c.running = false
 
-	c.err = &ConnectionResetError{
+	c.setErr(&ConnectionResetError{
 		Reason:  fr.ErrorCode,
 		Details: "Server closed connection with status " + fr.ErrorCode.String(),
-	}
+	})
+	c.cancelStreams(fr.ErrorCode)
 	c.terminate()
 	return nil
 }
 
+// cancelStreams terminates all open streams as if the server had reset each
+// of them with errCode, unblocking their readers.
+func (c *client) cancelStreams(errCode ErrorCode) {
+	c.streamsMu.Lock()
+	defer c.streamsMu.Unlock()
+	for _, s := range c.streams {
+		if !s.cancel(errCode) {
+			continue
+		}
+	}
+}
+
 func (c *client) handleHello(fr *HelloFrame) error {
 	if !fr.Ack {
 		c.reset(ErrorCodeProtocolError, "Server emitted a non-ack HELLO frame")
 	}
 
 	if fr.CompressionGZip {
-		c.compression = CompressionMethodGzip
+		c.compression.Store(CompressionMethodGzip)
 	}
 	c.maxConcurrentStreams = fr.MaxConcurrentStreams
 	c.setup = true
@@ -377,10 +573,6 @@ func (c *client) handleResetStream(fr *ResetStreamFrame) error {
 	return nil
 }
 
-func (c *client) waitForHello() {
-	<-c.helloOK
-}
-
 func (c *client) handleStream(*Stream) { /* noop */ }
 
 func (c *client) cancelStream(*Stream) { /* noop */ }
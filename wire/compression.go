@@ -5,6 +5,7 @@ import (
 	"compress/flate"
 	"fmt"
 	"io"
+	"sync/atomic"
 )
 
 //go:generate stringer -type=CompressionMethod -output=compression_string.go
@@ -16,6 +17,21 @@ const (
 	CompressionMethodGzip
 )
 
+// atomicCompression holds the CompressionMethod negotiated for a
+// connection, which is set by its reader while its writer may be using it.
+// The zero value holds CompressionMethodNone.
+type atomicCompression struct {
+	v atomic.Int32
+}
+
+func (a *atomicCompression) Load() CompressionMethod {
+	return CompressionMethod(a.v.Load())
+}
+
+func (a *atomicCompression) Store(c CompressionMethod) {
+	a.v.Store(int32(c))
+}
+
 var allCompressionMethods = []CompressionMethod{
 	CompressionMethodNone,
 	CompressionMethodGzip,
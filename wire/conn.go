@@ -2,10 +2,13 @@ package wire
 
 import (
 	"bytes"
-	"errors"
-	"fmt"
+	"context"
+	"encoding/binary"
+	"github.com/go-stdlog/stdlog"
 	"io"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type outboundFrame struct {
@@ -17,15 +20,67 @@ var outboundFramePool = sync.Pool{
 	New: func() interface{} { return &outboundFrame{} },
 }
 
+// writeFrame hands fr to the writer goroutine servicing queue and waits for
+// the result. writerDone must be closed once the writer stops servicing
+// queue, in which case ClosedConnErr is returned for frames it did not pick.
+// In case ctx ends first, ctx.Err() is returned, although the frame may
+// still be written later in case the writer already picked it.
+func writeFrame(ctx context.Context, queue chan<- *outboundFrame, writerDone <-chan struct{}, fr *Frame) error {
+	out := outboundFramePool.Get().(*outboundFrame)
+	result := make(chan error, 1)
+	out.frame = fr
+	out.result = result
+
+	select {
+	case queue <- out:
+	case <-writerDone:
+		outboundFramePool.Put(out)
+		return ClosedConnErr
+	case <-ctx.Done():
+		outboundFramePool.Put(out)
+		return ctx.Err()
+	}
+
+	var err error
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		// The writer may still be holding out, so it is not returned to
+		// the pool.
+		return ctx.Err()
+	case <-writerDone:
+		select {
+		case err = <-result:
+		default:
+			err = ClosedConnErr
+		}
+	}
+	outboundFramePool.Put(out)
+	return err
+}
+
+// drainWrites fails all frames pending in queue with ClosedConnErr.
+func drainWrites(queue <-chan *outboundFrame) {
+	for {
+		select {
+		case out := <-queue:
+			out.result <- ClosedConnErr
+		default:
+			return
+		}
+	}
+}
+
 type conn interface {
-	Write(fr *Frame) error
+	WriteContext(ctx context.Context, fr *Frame) error
+	Info() ConnInfo
 }
 
 // Conn represents a single active connection to a server
 type Conn struct {
 	io          io.ReadWriteCloser
 	id          int
-	compression CompressionMethod
+	compression atomicCompression
 	err         error
 	reader      *FrameReader
 
@@ -33,29 +88,47 @@ type Conn struct {
 	streams      map[uint32]Stream
 	lastStreamID uint32
 
-	toWrite chan *outboundFrame
-	drop    chan struct{}
+	toWrite    chan *outboundFrame
+	writerDone chan struct{}
+	drop       chan struct{}
 
 	maxConcurrentStreams uint32
 	runningMu            sync.Mutex
 	running              bool
 	configured           bool
-	terminateAfter       *Frame
+	terminateAfter       atomic.Pointer[Frame]
 	parent               server
+	opts                 Options
+	log                  stdlog.Logger
+
+	establishedAt  time.Time
+	streamsStarted int
+	pingMu         sync.Mutex
+	pingSeq        uint64
+	pings          map[uint64]chan struct{}
+	lastPingRTT    atomic.Int64
 }
 
-func NewConn(s server, id int, io io.ReadWriteCloser) *Conn {
+func NewConn(s server, id int, io io.ReadWriteCloser, opts ...Option) *Conn {
 	c := &Conn{
 		io:           io,
 		id:           id,
-		compression:  CompressionMethodNone,
 		streams:      make(map[uint32]Stream),
 		lastStreamID: 0,
 		toWrite:      make(chan *outboundFrame, 128),
+		writerDone:   make(chan struct{}),
 		drop:         make(chan struct{}),
 		running:      true,
 		reader:       NewFrameReader(io),
 		parent:       s,
+		opts:         makeOptions(opts),
+
+		establishedAt: time.Now(),
+		pings:         make(map[uint64]chan struct{}),
+	}
+	c.log = c.opts.logger().WithFields("conn_id", id)
+	if h := c.opts.StatsHandler; h != nil {
+		h.ConnBegin(c.Info())
 	}
 
 	go c.serviceWrites()
@@ -65,46 +138,85 @@ func NewConn(s server, id int, io io.ReadWriteCloser) *Conn {
 }
 
 func (c *Conn) terminate() {
+	c.runningMu.Lock()
 	if !c.running {
+		c.runningMu.Unlock()
 		return
 	}
 	c.running = false
-
 	close(c.drop)
+	c.runningMu.Unlock()
+
 	_ = c.io.Close()
-	close(c.toWrite)
+	c.cancelStreams(ErrorCodeCancel)
+	if h := c.opts.StatsHandler; h != nil {
+		h.ConnEnd(c.Info(), c.loadErr())
+	}
 	if c.parent != nil {
 		c.parent.connectionClosed(c.id)
 	}
 }
 
+// fail records err as the reason the connection stopped, and terminates it.
+func (c *Conn) fail(err error) {
+	c.runningMu.Lock()
+	c.err = err
+	c.runningMu.Unlock()
+	c.terminate()
+}
+
+// loadErr returns the error recorded by fail, if any.
+func (c *Conn) loadErr() error {
+	c.runningMu.Lock()
+	defer c.runningMu.Unlock()
+	return c.err
+}
+
 func (c *Conn) serviceWrites() {
-	for out := range c.toWrite {
-		if c.err != nil {
-			out.result <- c.err
+	for {
+		var out *outboundFrame
+		select {
+		case <-c.drop:
+			drainWrites(c.toWrite)
+			close(c.writerDone)
+			return
+		case out = <-c.toWrite:
+		}
+
+		if err := c.loadErr(); err != nil {
+			out.result <- err
 			continue
 		}
 
-		data := out.frame.Bytes(c.compression)
+		// out returns to the pool once its result is delivered, so the
+		// frame is kept aside for the checks that follow.
+		fr := out.frame
+		payload := fr.Payload
+		data := fr.Bytes(c.compression.Load())
 		_, err := io.Copy(c.io, bytes.NewReader(data))
 		if err != nil {
 			out.result <- err
 			continue
 		}
+		if h := c.opts.StatsHandler; h != nil {
+			h.FrameOut(outStats(fr, len(payload), data))
+		}
+		if t := c.opts.FrameTap; t != nil {
+			tapOut(t, c.id, fr, payload)
+		}
 		out.result <- nil
 
-		if out.frame == c.terminateAfter {
+		if fr == c.terminateAfter.Load() {
 			c.terminate()
 		}
 	}
 }
 
 func (c *Conn) serviceReads() {
-	for c.running {
+	for {
 		fr, err := c.reader.Read()
 		if err != nil {
-			c.err = err
-			c.terminate()
+			c.fail(err)
 			break
 		}
 		c.dispatchFrame(fr)
@@ -112,6 +224,18 @@ func (c *Conn) serviceReads() {
 }
 
 func (c *Conn) dispatchFrame(fr *Frame) {
+	compressedSize := len(fr.Payload)
+	if err := fr.Decompress(c.compression.Load()); err != nil {
+		c.goAway(ErrorCodeCompressionError, nil, true)
+		return
+	}
+	if h := c.opts.StatsHandler; h != nil {
+		h.FrameIn(inStats(fr, compressedSize))
+	}
+	if t := c.opts.FrameTap; t != nil {
+		t.TapFrame(c.id, DirectionIn, fr)
+	}
+
 	switch fr.FrameKind {
 	case FrameKindHello:
 		hello := &HelloFrame{}
@@ -173,21 +297,22 @@ func (c *Conn) handleHello(conf *HelloFrame) {
 		return
 	}
 
-	gzip := false
-	if conf.CompressionGZip {
-		gzip = true
-		c.compression = CompressionMethodGzip
-	}
-
 	c.configured = true
 	err := c.Write((&HelloFrame{
-		CompressionGZip:      gzip,
+		CompressionGZip:      conf.CompressionGZip,
 		Ack:                  true,
 		MaxConcurrentStreams: 0, // TODO
 	}).IntoFrame())
 	if err != nil {
-		// TODO: Log
+		c.log.Error(err, "Failed acknowledging HELLO")
 		c.terminate()
+		return
+	}
+
+	// The acknowledgement itself is not compressed; every frame exchanged
+	// after it is.
+	if conf.CompressionGZip {
+		c.compression.Store(CompressionMethodGzip)
 	}
 }
 
@@ -198,7 +323,17 @@ func (c *Conn) handlePing(ping *PingFrame) {
 	}
 
 	if ping.Ack {
-		return // TODO
+		if len(ping.Payload) == 8 {
+			id := binary.BigEndian.Uint64(ping.Payload)
+			c.pingMu.Lock()
+			ack, ok := c.pings[id]
+			delete(c.pings, id)
+			c.pingMu.Unlock()
+			if ok {
+				close(ack)
+			}
+		}
+		return
 	}
 
 	err := c.Write((&PingFrame{
@@ -206,9 +341,8 @@ func (c *Conn) handlePing(ping *PingFrame) {
 		Payload: ping.Payload,
 	}).IntoFrame())
 	if err != nil {
-		c.err = err
-		// TODO: log
-		c.terminate()
+		c.log.Error(err, "Failed acknowledging PING")
+		c.fail(err)
 	}
 }
 
@@ -224,16 +358,17 @@ func (c *Conn) handleGoAway(msg *GoAwayFrame) {
 	return
 }
 
+// cancelStreams terminates all streams as if the client had reset each of
+// them with errCode, and notifies the parent.
 func (c *Conn) cancelStreams(errCode ErrorCode) {
 	c.streamsMu.Lock()
 	defer c.streamsMu.Unlock()
 	for _, s := range c.streams {
-		if err := s.Reset(errCode); err != nil {
-			if errors.Is(err, ClosedStreamErr) {
-				continue
-			} else {
-				// TODO: Log
-			}
+		if !s.cancel(errCode) {
+			continue
+		}
+		if c.parent != nil {
+			c.parent.CancelStream(s)
 		}
 	}
 }
@@ -251,7 +386,7 @@ func (c *Conn) resetStream(id uint32, code ErrorCode) {
 		ErrorCode: code,
 	}
 	if err := c.Write(r.IntoFrame()); err != nil {
-		// TODO: Log
+		c.log.Error(err, "Failed resetting stream", "stream_id", id, "code", code)
 	}
 }
 
@@ -264,14 +399,15 @@ func (c *Conn) handleMakeStream(req *MakeStreamFrame) {
 	id := req.StreamID
 	_, ok := c.fetchStream(id)
 	if ok {
-		// TODO: Log
+		c.log.Warning("Peer opened a stream that already exists", "stream_id", id)
 		c.goAway(ErrorCodeProtocolError, nil, true)
 		return
 	}
 
 	c.streamsMu.Lock()
 	defer c.streamsMu.Unlock()
-	c.streams[id] = NewStream(id, c)
+	c.streamsStarted++
+	c.streams[id] = newStream(id, c, c.opts)
 	if c.parent != nil {
 		c.parent.ServiceStream(c.streams[id])
 	}
@@ -285,11 +421,14 @@ func (c *Conn) handleResetFrame(rs *ResetStreamFrame) {
 
 	s, ok := c.fetchStream(rs.StreamID)
 	if !ok {
-		// TODO: Log
+		c.log.Warning("Received RESET_STREAM for an unknown stream", "stream_id", rs.StreamID)
 		c.resetStream(rs.StreamID, ErrorCodeProtocolError)
 		return
 	}
 	s.handleResetStream(rs)
+	if c.parent != nil {
+		c.parent.CancelStream(s)
+	}
 }
 
 func (c *Conn) handleData(data *DataFrame) {
@@ -300,7 +439,7 @@ func (c *Conn) handleData(data *DataFrame) {
 
 	s, ok := c.fetchStream(data.StreamID)
 	if !ok {
-		// TODO: Log
+		c.log.Warning("Received DATA for an unknown stream", "stream_id", data.StreamID)
 		c.resetStream(data.StreamID, ErrorCodeProtocolError)
 		return
 	}
@@ -308,14 +447,18 @@ func (c *Conn) handleData(data *DataFrame) {
 	s.handleData(data)
 }
 
+// Info describes the connection.
+func (c *Conn) Info() ConnInfo {
+	return connInfo(c.id, c.io, c.compression.Load())
+}
+
 func (c *Conn) Write(fr *Frame) error {
-	out := outboundFramePool.Get().(*outboundFrame)
-	defer outboundFramePool.Put(out)
-	err := make(chan error, 1)
-	out.frame = fr
-	out.result = err
-	c.toWrite <- out
-	return <-err
+	return c.WriteContext(context.Background(), fr)
+}
+
+// WriteContext writes fr as Write does, giving up once ctx ends.
+func (c *Conn) WriteContext(ctx context.Context, fr *Frame) error {
+	return writeFrame(ctx, c.toWrite, c.writerDone, fr)
 }
 
 func (c *Conn) goAway(code ErrorCode, extraData []byte, terminate bool) {
@@ -327,11 +470,10 @@ func (c *Conn) goAway(code ErrorCode, extraData []byte, terminate bool) {
 
 	frame := fr.IntoFrame()
 	if terminate {
-		c.terminateAfter = frame
+		c.terminateAfter.Store(frame)
 	}
 
 	if err := c.Write(frame); err != nil {
-		fmt.Printf("conn.goAway: Write error: %s\n", err)
-		// TODO: Log
+		c.log.Error(err, "Failed sending GOAWAY", "code", code)
 	}
 }
@@ -0,0 +1,35 @@
+package wire
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+)
+
+// ConnInfo describes the connection a stream belongs to.
+type ConnInfo struct {
+	// ID identifies the connection among those accepted by a Server, or,
+	// for client connections, among the client connections of the process.
+	ID int
+	// LocalAddr and RemoteAddr are nil unless the connection is a net.Conn.
+	LocalAddr  net.Addr
+	RemoteAddr net.Addr
+	// Compression is the method negotiated during the HELLO exchange.
+	Compression CompressionMethod
+	// TLS is the state of the TLS connection, or nil in case the connection
+	// is not encrypted.
+	TLS *tls.ConnectionState
+}
+
+func connInfo(id int, rw io.ReadWriteCloser, compression CompressionMethod) ConnInfo {
+	info := ConnInfo{ID: id, Compression: compression}
+	if conn, ok := rw.(net.Conn); ok {
+		info.LocalAddr = conn.LocalAddr()
+		info.RemoteAddr = conn.RemoteAddr()
+	}
+	if conn, ok := rw.(*tls.Conn); ok {
+		state := conn.ConnectionState()
+		info.TLS = &state
+	}
+	return info
+}
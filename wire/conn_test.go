@@ -2,6 +2,7 @@ package wire
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -53,14 +54,20 @@ func waitForConnectionReset(t *testing.T, cli Client) {
 	t.Helper()
 
 	waitFor(t, "connection to be reset", 3*time.Second, func() bool {
-		return cli.(*client).err != nil
+		return cli.(*client).loadErr() != nil
 	})
-	err := cli.(*client).err
+	err := cli.(*client).loadErr()
 	assert.IsType(t, &ConnectionResetError{}, err)
 	var reset *ConnectionResetError
 	assert.True(t, errors.As(err, &reset))
 }
 
+func isRunning(c *Conn) bool {
+	c.runningMu.Lock()
+	defer c.runningMu.Unlock()
+	return c.running
+}
+
 func waitConnectionShutdown(t *testing.T, conn conn, errch chan error) {
 	t.Helper()
 
@@ -73,7 +80,7 @@ func waitConnectionShutdown(t *testing.T, conn conn, errch chan error) {
 		defer close(done)
 		for loop {
 			time.Sleep(10 * time.Millisecond)
-			if !conn.(*Conn).running {
+			if !isRunning(conn.(*Conn)) {
 				return
 			}
 		}
@@ -201,6 +208,143 @@ func TestConn(t *testing.T) {
 		waitConnectionShutdown(t, conn, errch)
 	})
 
+	t.Run("Ping waits for the server acknowledgement", func(t *testing.T) {
+		cli, conn, errch := makeConnection()
+
+		err := cli.Configure(CompressionMethodNone)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		require.NoError(t, cli.Ping(ctx))
+		require.NoError(t, cli.Ping(ctx))
+
+		err = cli.Terminate(ErrorCodeNoError)
+		require.NoError(t, err)
+		waitConnectionShutdown(t, conn, errch)
+	})
+
+	t.Run("ConfigureContext gives up once its context is done", func(t *testing.T) {
+		local, remote := net.Pipe()
+		defer remote.Close()
+		go func() { _, _ = io.Copy(io.Discard, remote) }()
+		cli := NewClient(local)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err := cli.ConfigureContext(ctx, CompressionMethodNone)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		select {
+		case <-cli.Done():
+		case <-time.After(3 * time.Second):
+			t.Fatal("timed out waiting for client termination")
+		}
+	})
+
+	t.Run("ConfigureContext gives up writing HELLO once its context is done", func(t *testing.T) {
+		local, remote := net.Pipe()
+		defer remote.Close()
+		cli := NewClient(local)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err := cli.ConfigureContext(ctx, CompressionMethodNone)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		select {
+		case <-cli.Done():
+		case <-time.After(3 * time.Second):
+			t.Fatal("timed out waiting for client termination")
+		}
+	})
+
+	t.Run("Configure fails in case the connection is lost before HELLO is acknowledged", func(t *testing.T) {
+		local, remote := net.Pipe()
+		cli := NewClient(local)
+		go func() {
+			_, _ = remote.Read(make([]byte, 64))
+			_ = remote.Close()
+		}()
+
+		require.Error(t, cli.Configure(CompressionMethodNone))
+	})
+
+	t.Run("Info describes the connection", func(t *testing.T) {
+		cli, conn, errch := makeConnection()
+
+		err := cli.Configure(CompressionMethodGzip)
+		require.NoError(t, err)
+		// The server is done handling HELLO once it acknowledges a PING.
+		require.NoError(t, cli.Ping(context.Background()))
+
+		info := conn.(*Conn).Info()
+		assert.Equal(t, 1, info.ID)
+		assert.Equal(t, "pipe", info.RemoteAddr.Network())
+		assert.Equal(t, CompressionMethodGzip, info.Compression)
+		assert.Nil(t, info.TLS)
+
+		err = cli.Terminate(ErrorCodeNoError)
+		require.NoError(t, err)
+		waitConnectionShutdown(t, conn, errch)
+	})
+
+	t.Run("Status describes the connection and its streams", func(t *testing.T) {
+		cli, conn, errch := makeConnection()
+		c := conn.(*Conn)
+
+		require.NoError(t, cli.Configure(CompressionMethodNone))
+		str, err := cli.NewStream()
+		require.NoError(t, err)
+		require.NoError(t, str.Write([]byte("hello"), false))
+		closed, err := cli.NewStream()
+		require.NoError(t, err)
+		require.NoError(t, closed.Reset(ErrorCodeCancel))
+
+		// Closed streams are omitted.
+		waitFor(t, "streams to be updated", time.Second, func() bool {
+			st, streams := c.Status()
+			return st.StreamsStarted == 2 && len(streams) == 1 && streams[0].Buffered == 5
+		})
+		st, streams := c.Status()
+		assert.Equal(t, 1, st.ID)
+		assert.Equal(t, 2, st.StreamsStarted)
+		assert.Equal(t, 1, st.StreamsActive)
+		assert.Zero(t, st.LastPingRTT)
+		assert.WithinDuration(t, time.Now(), st.EstablishedAt, time.Second)
+		assert.Equal(t, StreamStatus{ID: str.ID(), State: "open", Buffered: 5}, streams[0])
+
+		rtt, err := c.Ping(context.Background())
+		require.NoError(t, err)
+		assert.Positive(t, rtt)
+		st, _ = c.Status()
+		assert.Equal(t, rtt, st.LastPingRTT)
+
+		require.NoError(t, cli.Terminate(ErrorCodeNoError))
+		waitConnectionShutdown(t, conn, errch)
+	})
+
+	t.Run("a terminated client reports Done and Err", func(t *testing.T) {
+		cli, conn, errch := makeConnection()
+
+		err := cli.Configure(CompressionMethodNone)
+		require.NoError(t, err)
+		_, err = cli.NewStream()
+		require.NoError(t, err)
+		assert.Equal(t, 1, cli.ActiveStreams())
+		assert.NoError(t, cli.Err())
+
+		conn.(*Conn).goAway(ErrorCodeNoError, nil, true)
+		select {
+		case <-cli.Done():
+		case <-time.After(3 * time.Second):
+			t.Fatal("timed out waiting for client termination")
+		}
+		assert.IsType(t, &ConnectionResetError{}, cli.Err())
+		assert.Equal(t, 0, cli.ActiveStreams())
+		waitConnectionShutdown(t, conn, errch)
+	})
+
 	t.Run("sending MAKE_STREAM initializes a stream", func(t *testing.T) {
 		cli, conn, errch := makeConnection()
 
@@ -212,7 +356,7 @@ func TestConn(t *testing.T) {
 		}).IntoFrame())
 
 		waitFor(t, "stream to be registered", 3*time.Second, func() bool {
-			_, ok := conn.(*Conn).streams[1]
+			_, ok := conn.(*Conn).fetchStream(1)
 			return ok
 		})
 
@@ -232,7 +376,7 @@ func TestConn(t *testing.T) {
 		require.NoError(t, err)
 
 		waitFor(t, "stream to be registered", 3*time.Second, func() bool {
-			_, ok := conn.(*Conn).streams[1]
+			_, ok := conn.(*Conn).fetchStream(1)
 			return ok
 		})
 
@@ -240,7 +384,8 @@ func TestConn(t *testing.T) {
 		require.NoError(t, err)
 
 		buf := make([]byte, 3)
-		n, err := conn.(*Conn).streams[1].Read(buf)
+		remote, _ := conn.(*Conn).fetchStream(1)
+		n, err := remote.Read(buf)
 		require.NoError(t, err)
 		assert.Equal(t, 3, n)
 		assert.Equal(t, []byte{0x01, 0x02, 0x03}, buf)
@@ -251,6 +396,38 @@ func TestConn(t *testing.T) {
 		waitConnectionShutdown(t, conn, errch)
 	})
 
+	t.Run("compressed connections exchange DATA in both directions", func(t *testing.T) {
+		cli, conn, errch := makeConnection()
+
+		err := cli.Configure(CompressionMethodGzip)
+		require.NoError(t, err)
+
+		str, err := cli.NewStream()
+		require.NoError(t, err)
+
+		waitFor(t, "stream to be registered", 3*time.Second, func() bool {
+			_, ok := conn.(*Conn).fetchStream(1)
+			return ok
+		})
+		remote, _ := conn.(*Conn).fetchStream(1)
+
+		require.NoError(t, str.Write([]byte("hello"), false))
+		buf := make([]byte, 5)
+		_, err = io.ReadFull(remote, buf)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("hello"), buf)
+
+		require.NoError(t, remote.Write([]byte("world"), false))
+		_, err = io.ReadFull(str, buf)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("world"), buf)
+
+		err = cli.Terminate(ErrorCodeNoError)
+		require.NoError(t, err)
+
+		waitConnectionShutdown(t, conn, errch)
+	})
+
 	t.Run("sending RESET_STREAM to an initialized stream resets it", func(t *testing.T) {
 		cli, conn, errch := makeConnection()
 
@@ -261,15 +438,17 @@ func TestConn(t *testing.T) {
 		require.NoError(t, err)
 
 		waitFor(t, "stream to be registered", 3*time.Second, func() bool {
-			_, ok := conn.(*Conn).streams[1]
+			_, ok := conn.(*Conn).fetchStream(1)
 			return ok
 		})
 
 		err = str.Reset(ErrorCodeNoError)
 		require.NoError(t, err)
+		assert.Equal(t, 0, cli.ActiveStreams())
 
 		waitFor(t, "stream to be reset", 3*time.Second, func() bool {
-			return conn.(*Conn).streams[1].(*stream).state.code == streamStateClosed
+			remote, _ := conn.(*Conn).fetchStream(1)
+			return remote.(*stream).state.Code() == streamStateClosed
 		})
 
 		err = cli.Terminate(ErrorCodeNoError)
@@ -296,10 +475,10 @@ func TestConn(t *testing.T) {
 		require.NoError(t, err)
 
 		waitFor(t, "connection to be dropped", 3*time.Second, func() bool {
-			return !conn.(*Conn).running
+			return !isRunning(conn.(*Conn))
 		})
 		waitFor(t, "client to be stopped", 3*time.Second, func() bool {
-			return !cli.(*client).running
+			return cli.(*client).Err() != nil
 		})
 
 		waitConnectionShutdown(t, conn, errch)
@@ -309,3 +488,32 @@ func TestConn(t *testing.T) {
 
 	})
 }
+
+func TestWriteFrame(t *testing.T) {
+	fr := (&PingFrame{}).IntoFrame()
+
+	t.Run("gives up waiting for the writer once ctx is done", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := writeFrame(ctx, make(chan *outboundFrame), make(chan struct{}), fr)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("gives up waiting for the result once ctx is done", func(t *testing.T) {
+		queue := make(chan *outboundFrame, 1)
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-queue
+			cancel()
+		}()
+		err := writeFrame(ctx, queue, make(chan struct{}), fr)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("reports closed connections", func(t *testing.T) {
+		done := make(chan struct{})
+		close(done)
+		err := writeFrame(context.Background(), make(chan *outboundFrame), done, fr)
+		assert.ErrorIs(t, err, ClosedConnErr)
+	})
+}
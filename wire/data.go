@@ -1,12 +1,18 @@
 package wire
 
 func DataFramesFromBuffer(streamID uint32, endStream bool, buffer []byte) []Framer {
+	return dataFrames(streamID, true, endStream, buffer)
+}
+
+// dataFrames splits buffer into DATA frames, setting EndData on the last one
+// only in case endData is set.
+func dataFrames(streamID uint32, endData, endStream bool, buffer []byte) []Framer {
 	bufLen := len(buffer)
 	if bufLen <= maxPayload {
 		return []Framer{
 			&DataFrame{
 				StreamID:  streamID,
-				EndData:   true,
+				EndData:   endData,
 				EndStream: endStream,
 				Payload:   buffer,
 			},
@@ -17,22 +23,22 @@ func DataFramesFromBuffer(streamID uint32, endStream bool, buffer []byte) []Fram
 	frames = append(frames, &DataFrame{
 		StreamID:  streamID,
 		EndData:   false,
-		EndStream: endStream,
+		EndStream: false,
 		Payload:   buffer[0:maxPayload],
 	})
 	written := maxPayload
 
 	for {
 		toWrite := min(bufLen-written, maxPayload)
-		endData := bufLen-written-toWrite == 0
+		last := bufLen-written-toWrite == 0
 		frames = append(frames, &DataFrame{
 			StreamID:  streamID,
-			EndData:   endData,
-			EndStream: endStream,
+			EndData:   endData && last,
+			EndStream: endStream && last,
 			Payload:   buffer[written : written+toWrite],
 		})
 		written += toWrite
-		if endData {
+		if last {
 			break
 		}
 	}
@@ -44,6 +44,8 @@ func (i *InvalidFrameError) Error() string {
 
 var ClosedStreamErr = fmt.Errorf("stream is closed")
 
+var ClosedConnErr = fmt.Errorf("connection is closed")
+
 type StreamResetError struct {
 	Reason ErrorCode
 }
@@ -81,3 +83,18 @@ func (c *ConnectionResetError) Error() string {
 	}
 	return fmt.Sprintf("connection reset: %s", c.Reason)
 }
+
+// MessageTooLargeError indicates a message exceeded either the maximum
+// receive or send message size configured through Options.
+type MessageTooLargeError struct {
+	Size int
+	Max  int
+	Recv bool
+}
+
+func (m *MessageTooLargeError) Error() string {
+	if m.Recv {
+		return fmt.Sprintf("received message larger than max (%d vs. %d)", m.Size, m.Max)
+	}
+	return fmt.Sprintf("trying to send message larger than max (%d vs. %d)", m.Size, m.Max)
+}
@@ -0,0 +1,130 @@
+package wire
+
+import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"time"
+)
+
+// ConnStatus describes a connection at the time it was inspected.
+type ConnStatus struct {
+	ConnInfo
+	EstablishedAt time.Time
+	// LastPingRTT is the round-trip time measured by the last successful
+	// Ping, or zero.
+	LastPingRTT time.Duration
+	// StreamsStarted counts streams opened by the peer, while StreamsActive
+	// counts those not yet closed.
+	StreamsStarted int
+	StreamsActive  int
+}
+
+// StreamStatus describes a stream at the time it was inspected.
+type StreamStatus struct {
+	ID uint32
+	// State is either "open", "half-closed (local)" or "half-closed
+	// (remote)".
+	State string
+	// Buffered is the amount of bytes received and not yet read.
+	Buffered   int
+	ExternalID string
+}
+
+// UnknownConnErr is returned by Server.Ping for connections not established
+// with the server.
+var UnknownConnErr = errors.New("unknown connection")
+
+func (s *stream) status() StreamStatus {
+	return StreamStatus{
+		ID:         s.id,
+		State:      s.state.Code().String(),
+		Buffered:   s.reader.Buffered(),
+		ExternalID: s.ExternalID(),
+	}
+}
+
+// Status describes c and its streams not yet closed, ordered by ID.
+func (c *Conn) Status() (ConnStatus, []StreamStatus) {
+	c.streamsMu.RLock()
+	streams := make([]StreamStatus, 0, len(c.streams))
+	for _, s := range c.streams {
+		if str, ok := s.(*stream); ok && !str.closed() {
+			streams = append(streams, str.status())
+		}
+	}
+	started := c.streamsStarted
+	c.streamsMu.RUnlock()
+
+	slices.SortFunc(streams, func(a, b StreamStatus) int { return cmp.Compare(a.ID, b.ID) })
+	st := ConnStatus{
+		ConnInfo:       c.Info(),
+		EstablishedAt:  c.establishedAt,
+		LastPingRTT:    time.Duration(c.lastPingRTT.Load()),
+		StreamsStarted: started,
+		StreamsActive:  len(streams),
+	}
+	return st, streams
+}
+
+// Ping sends a PING frame to the peer, returning the time it took to be
+// acknowledged. The result is reported by Status as LastPingRTT.
+func (c *Conn) Ping(ctx context.Context) (time.Duration, error) {
+	c.pingMu.Lock()
+	c.pingSeq++
+	id := c.pingSeq
+	ack := make(chan struct{})
+	c.pings[id] = ack
+	c.pingMu.Unlock()
+
+	defer func() {
+		c.pingMu.Lock()
+		delete(c.pings, id)
+		c.pingMu.Unlock()
+	}()
+
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint64(payload, id)
+	begin := time.Now()
+	if err := c.WriteContext(ctx, (&PingFrame{Payload: payload}).IntoFrame()); err != nil {
+		return 0, err
+	}
+
+	select {
+	case <-ack:
+		rtt := time.Since(begin)
+		c.lastPingRTT.Store(int64(rtt))
+		return rtt, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-c.drop:
+		return 0, ClosedConnErr
+	}
+}
+
+// Connections returns the connections established with the server, ordered
+// by ID.
+func (s *Server) Connections() []*Conn {
+	s.connectionsMu.Lock()
+	conns := make([]*Conn, 0, len(s.connections))
+	for _, c := range s.connections {
+		conns = append(conns, c)
+	}
+	s.connectionsMu.Unlock()
+
+	slices.SortFunc(conns, func(a, b *Conn) int { return cmp.Compare(a.id, b.id) })
+	return conns
+}
+
+// Ping pings the connection identified by id, as Conn.Ping does.
+func (s *Server) Ping(ctx context.Context, id int) (time.Duration, error) {
+	s.connectionsMu.Lock()
+	c, ok := s.connections[id]
+	s.connectionsMu.Unlock()
+	if !ok {
+		return 0, UnknownConnErr
+	}
+	return c.Ping(ctx)
+}
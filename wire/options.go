@@ -0,0 +1,75 @@
+package wire
+
+import "github.com/go-stdlog/stdlog"
+
+// Options holds settings shared by connections and the streams they create.
+type Options struct {
+	// MaxRecvMessageSize is the maximum size, in bytes, of a single message
+	// received by a stream, counted across DATA frames until END_DATA. Zero
+	// means no limit.
+	MaxRecvMessageSize int
+
+	// MaxSendMessageSize is the maximum size, in bytes, of a single message
+	// written to a stream. Zero means no limit.
+	MaxSendMessageSize int
+
+	// StatsHandler, when set, is notified of connections being established
+	// and terminated, and of frames being exchanged.
+	StatsHandler StatsHandler
+
+	// FrameTap, when set, observes every frame exchanged through
+	// connections, as CaptureWriter does to record them.
+	FrameTap FrameTap
+
+	// Logger receives diagnostics about connections and streams, such as
+	// protocol violations committed by the peer. Defaults to
+	// stdlog.Discard.
+	Logger stdlog.Logger
+}
+
+type Option func(*Options)
+
+func WithMaxRecvMessageSize(n int) Option {
+	return func(o *Options) {
+		o.MaxRecvMessageSize = n
+	}
+}
+
+func WithMaxSendMessageSize(n int) Option {
+	return func(o *Options) {
+		o.MaxSendMessageSize = n
+	}
+}
+
+func WithStatsHandler(h StatsHandler) Option {
+	return func(o *Options) {
+		o.StatsHandler = h
+	}
+}
+
+func WithFrameTap(t FrameTap) Option {
+	return func(o *Options) {
+		o.FrameTap = t
+	}
+}
+
+func WithLogger(l stdlog.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
+
+func makeOptions(opts []Option) Options {
+	o := Options{}
+	for _, fn := range opts {
+		fn(&o)
+	}
+	return o
+}
+
+func (o Options) logger() stdlog.Logger {
+	if o.Logger == nil {
+		return stdlog.Discard
+	}
+	return o.Logger
+}
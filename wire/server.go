@@ -1,6 +1,7 @@
 package wire
 
 import (
+	"io"
 	"net"
 	"sync"
 )
@@ -22,13 +23,15 @@ type Server struct {
 	connectionsMu sync.Mutex
 	connections   map[int]*Conn
 	connID        int
+	opts          []Option
 }
 
-func NewServer(l net.Listener, handler StreamHandler) *Server {
+func NewServer(l net.Listener, handler StreamHandler, opts ...Option) *Server {
 	return &Server{
 		listener:      l,
 		streamHandler: handler,
 		connections:   make(map[int]*Conn),
+		opts:          opts,
 	}
 }
 
@@ -44,14 +47,20 @@ func (s *Server) Serve() error {
 		if err != nil {
 			return err
 		}
-		s.connectionsMu.Lock()
-		c := NewConn(s, s.connID, conn)
-		s.connID++
-		s.connections[s.connID] = c
-		s.connectionsMu.Unlock()
+		s.ServeConn(conn)
 	}
 }
 
+// ServeConn services a connection obtained elsewhere than the server's
+// listener, such as one end of an in-process pipe.
+func (s *Server) ServeConn(conn io.ReadWriteCloser) {
+	s.connectionsMu.Lock()
+	defer s.connectionsMu.Unlock()
+	id := s.connID
+	s.connID++
+	s.connections[id] = NewConn(s, id, conn, s.opts...)
+}
+
 func (s *Server) ServiceStream(stream Stream) {
 	go s.streamHandler.ServiceStream(stream)
 }
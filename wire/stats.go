@@ -0,0 +1,59 @@
+package wire
+
+// frameHeaderLen is the size of the header preceding the payload of every
+// frame.
+var frameHeaderLen = payloadOffset
+
+// FrameStats describes a frame sent or received through a connection.
+type FrameStats struct {
+	Kind     FrameKind
+	StreamID uint32
+	// Size is the amount of bytes the frame used on the connection,
+	// including its header.
+	Size int
+	// PayloadSize is the size of the payload before compression, or after
+	// decompression.
+	PayloadSize int
+	// CompressedSize is the size of the payload as transmitted. It equals
+	// PayloadSize on connections not using compression.
+	CompressedSize int
+}
+
+// StatsHandler is notified of events occurring on connections. Its methods
+// are called synchronously by the goroutines servicing connections, and
+// must not block.
+type StatsHandler interface {
+	// ConnBegin is called once a connection is established, before the
+	// HELLO exchange.
+	ConnBegin(info ConnInfo)
+	// ConnEnd is called once a connection is terminated, with the error
+	// that caused it, if any.
+	ConnEnd(info ConnInfo, err error)
+	// FrameIn is called for every frame received.
+	FrameIn(info FrameStats)
+	// FrameOut is called for every frame successfully written.
+	FrameOut(info FrameStats)
+}
+
+// outStats returns the stats of fr, whose payload had payloadSize bytes
+// before being compressed into data.
+func outStats(fr *Frame, payloadSize int, data []byte) FrameStats {
+	return FrameStats{
+		Kind:           fr.FrameKind,
+		StreamID:       fr.StreamID,
+		Size:           len(data),
+		PayloadSize:    payloadSize,
+		CompressedSize: len(data) - frameHeaderLen,
+	}
+}
+
+// inStats returns the stats of fr, decompressed from compressedSize bytes.
+func inStats(fr *Frame, compressedSize int) FrameStats {
+	return FrameStats{
+		Kind:           fr.FrameKind,
+		StreamID:       fr.StreamID,
+		Size:           frameHeaderLen + compressedSize,
+		PayloadSize:    len(fr.Payload),
+		CompressedSize: compressedSize,
+	}
+}
@@ -1,9 +1,11 @@
 package wire
 
 import (
-	"fmt"
+	"context"
+	"github.com/go-stdlog/stdlog"
 	"io"
 	"sync"
+	"sync/atomic"
 )
 
 type Stream interface {
@@ -11,20 +13,47 @@ type Stream interface {
 
 	handleResetStream(rs *ResetStreamFrame)
 	handleData(data *DataFrame)
+	cancel(code ErrorCode) bool
+	closed() bool
 
 	Write(data []byte, endStream bool) error
+	// WriteContext writes data as Write does, giving up once ctx ends. As
+	// part of the message may have been written by then, the stream should
+	// be reset in that case.
+	WriteContext(ctx context.Context, data []byte, endStream bool) error
+	// ReadBlock returns the payload of the next DATA frame received, or its
+	// unread remainder, along with whether it concludes a message. It
+	// allows data to be forwarded without losing message boundaries.
+	ReadBlock() (data []byte, endData bool, err error)
+	// WriteBlock writes data without necessarily concluding a message,
+	// setting the EndData flag only in case endData is set. Unlike Write,
+	// the MaxSendMessageSize option is not enforced, as data may be part of
+	// a larger message.
+	WriteBlock(data []byte, endData bool) error
 	Reset(code ErrorCode) error
 	CloseLocal() error
+	// Err returns the error that caused the stream to be terminated by the
+	// remote party, such as a *StreamResetError, or nil.
+	Err() error
 	ID() uint32
+	// ConnInfo describes the connection the stream belongs to.
+	ConnInfo() ConnInfo
 	SetExternalID(string)
 	ExternalID() string
 }
 
 func NewStream(id uint32, c conn) Stream {
+	return newStream(id, c, Options{})
+}
+
+func newStream(id uint32, c conn, opts Options) *stream {
 	return &stream{
-		c:      c,
-		id:     id,
-		reader: NewBlockReader(),
+		c:                  c,
+		id:                 id,
+		reader:             NewBlockReader(),
+		maxRecvMessageSize: opts.MaxRecvMessageSize,
+		maxSendMessageSize: opts.MaxSendMessageSize,
+		logger:             opts.logger().WithFields("stream_id", id),
 	}
 }
 
@@ -34,12 +63,20 @@ type stream struct {
 	state      streamState
 	reader     *BlockReader
 	writeMu    sync.Mutex
-	externalID string
+	externalID atomic.Value
+
+	maxRecvMessageSize int
+	maxSendMessageSize int
+	recvMessageSize    int
+	logger             stdlog.Logger
 }
 
 func (s *stream) ID() uint32                      { return s.id }
-func (s *stream) SetExternalID(externalID string) { s.externalID = externalID }
-func (s *stream) ExternalID() string              { return s.externalID }
+func (s *stream) ConnInfo() ConnInfo              { return s.c.Info() }
+func (s *stream) SetExternalID(externalID string) { s.externalID.Store(externalID) }
+func (s *stream) ExternalID() string              { id, _ := s.externalID.Load().(string); return id }
+func (s *stream) Err() error                      { return s.state.Error() }
+func (s *stream) closed() bool                    { return s.state.Code() == streamStateClosed }
 
 func (s *stream) handleResetStream(rs *ResetStreamFrame) {
 	if s.state.RecvResetStream() != nil {
@@ -47,44 +84,106 @@ func (s *stream) handleResetStream(rs *ResetStreamFrame) {
 		//       value may be something other than ErrorStreamClosed, as
 		//       RecvResetStream will return any previous error captured by the
 		//       state. Same applies to handleData.
-		err := s.reset(ErrorCodeStreamClosed)
-		fmt.Printf("Error sending reset during recv reset: %s\n", err)
+		s.logger.Debug("Received RESET_STREAM on a closed stream")
+		if err := s.reset(ErrorCodeStreamClosed); err != nil {
+			s.logger.Error(err, "Failed resetting stream after receiving RESET_STREAM")
+		}
 		return
 	}
+	err := &StreamResetError{Reason: rs.ErrorCode}
 	s.state.Close()
-	s.state.SetError(&StreamResetError{
-		Reason: rs.ErrorCode,
-	})
-	s.reader.internalClose()
+	s.state.SetError(err)
+	s.reader.fail(err)
+}
+
+// cancel terminates the stream as if the remote party had reset it with
+// code, without emitting any frame. Returns false in case the stream was
+// already closed.
+func (s *stream) cancel(code ErrorCode) bool {
+	if s.state.RecvResetStream() != nil {
+		return false
+	}
+	err := &StreamResetError{Reason: code}
+	s.state.Close()
+	s.state.SetError(err)
+	s.reader.fail(err)
+	return true
 }
 
 func (s *stream) handleData(data *DataFrame) {
 	if err := s.state.RecvData(); err != nil {
-		err = s.reset(ErrorCodeStreamClosed)
-		fmt.Printf("Error sending reset during recv reset: %s\n", err)
+		s.logger.Debug("Received DATA on a closed stream")
+		if err = s.reset(ErrorCodeStreamClosed); err != nil {
+			s.logger.Error(err, "Failed resetting stream after receiving DATA")
+		}
 		return
 	}
-	s.reader.Enqueue(data.Payload)
+	if s.maxRecvMessageSize > 0 {
+		s.recvMessageSize += len(data.Payload)
+		if s.recvMessageSize > s.maxRecvMessageSize {
+			// Stop buffering the remainder of the message; the reader will
+			// report the error so upper layers can reject the stream.
+			s.reader.fail(&MessageTooLargeError{
+				Size: s.recvMessageSize,
+				Max:  s.maxRecvMessageSize,
+				Recv: true,
+			})
+		}
+		if data.EndData {
+			s.recvMessageSize = 0
+		}
+	}
+	s.reader.enqueue(data.Payload, data.EndData)
 	if data.EndStream {
 		s.state.CloseRemote()
 	}
 }
 
 func (s *stream) write(msg Framer) error {
+	return s.writeContext(context.Background(), msg)
+}
+
+func (s *stream) writeContext(ctx context.Context, msg Framer) error {
 	fr := msg.IntoFrame()
 	fr.StreamID = s.id
-	return s.c.Write(fr)
+	return s.c.WriteContext(ctx, fr)
 }
 
 func (s *stream) Write(data []byte, endStream bool) error {
+	return s.WriteContext(context.Background(), data, endStream)
+}
+
+func (s *stream) WriteContext(ctx context.Context, data []byte, endStream bool) error {
+	if err := s.state.SendData(); err != nil {
+		return err
+	}
+	if s.maxSendMessageSize > 0 && len(data) > s.maxSendMessageSize {
+		return &MessageTooLargeError{
+			Size: len(data),
+			Max:  s.maxSendMessageSize,
+		}
+	}
+	return s.writeFrames(ctx, dataFrames(s.id, true, endStream, data), endStream)
+}
+
+func (s *stream) WriteBlock(data []byte, endData bool) error {
 	if err := s.state.SendData(); err != nil {
 		return err
 	}
+	return s.writeFrames(context.Background(), dataFrames(s.id, endData, false, data), false)
+}
+
+func (s *stream) writeFrames(ctx context.Context, frames []Framer, endStream bool) error {
 	s.writeMu.Lock()
 	defer s.writeMu.Unlock()
 
-	for _, fr := range DataFramesFromBuffer(s.id, endStream, data) {
-		if err := s.write(fr); err != nil {
+	for _, fr := range frames {
+		// The stream may be reset by the peer while a large message is
+		// being written; stop sending as soon as that happens.
+		if err := s.state.SendData(); err != nil {
+			return err
+		}
+		if err := s.writeContext(ctx, fr); err != nil {
 			return err
 		}
 	}
@@ -116,8 +215,8 @@ func (s *stream) Reset(code ErrorCode) error {
 }
 
 func (s *stream) Read(into []byte) (int, error) {
-	if s.state.err != nil {
-		return 0, s.state.err
+	if err := s.state.Error(); err != nil {
+		return 0, err
 	}
 	ok, n, err := s.reader.TryRead(into)
 	if ok {
@@ -125,12 +224,36 @@ func (s *stream) Read(into []byte) (int, error) {
 	}
 
 	if err := s.state.RecvData(); err != nil {
+		// The last block may have been enqueued right before the remote
+		// party closed the stream.
+		if ok, n, rErr := s.reader.TryRead(into); ok {
+			return n, rErr
+		}
 		return 0, err
 	}
 
 	return s.reader.Read(into)
 }
 
+func (s *stream) ReadBlock() ([]byte, bool, error) {
+	if err := s.state.Error(); err != nil {
+		return nil, false, err
+	}
+	ok, data, endData, err := s.reader.TryReadBlock()
+	if ok {
+		return data, endData, err
+	}
+
+	if err := s.state.RecvData(); err != nil {
+		if ok, data, endData, rErr := s.reader.TryReadBlock(); ok {
+			return data, endData, rErr
+		}
+		return nil, false, err
+	}
+
+	return s.reader.ReadBlock()
+}
+
 func (s *stream) CloseLocal() error {
 	if err := s.state.SendData(); err != nil {
 		return err
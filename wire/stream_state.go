@@ -1,5 +1,7 @@
 package wire
 
+import "sync"
+
 type streamStateCode int
 
 const (
@@ -25,49 +27,79 @@ func (s streamStateCode) String() string {
 }
 
 type streamState struct {
+	mu   sync.Mutex
 	code streamStateCode
 	err  error
+
+	// onClosed, when set, is invoked once the stream transitions to the
+	// closed state. It is called with mu held, and must not block.
+	onClosed func()
+}
+
+func (s *streamState) set(code streamStateCode) {
+	if code == streamStateClosed && s.code != streamStateClosed && s.onClosed != nil {
+		s.onClosed()
+	}
+	s.code = code
+}
+
+func (s *streamState) Code() streamStateCode {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.code
 }
 
 func (s *streamState) Error() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.err
 }
 
 func (s *streamState) Close() {
-	s.code = streamStateClosed
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(streamStateClosed)
 }
 
 func (s *streamState) CloseLocal() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	switch s.code {
 	case streamStateOpen:
-		s.code = streamStateHalfClosedLocal
+		s.set(streamStateHalfClosedLocal)
 	case streamStateHalfClosedLocal:
-		s.code = streamStateHalfClosedLocal
+		s.set(streamStateHalfClosedLocal)
 	case streamStateHalfClosedRemote:
-		s.code = streamStateClosed
+		s.set(streamStateClosed)
 	case streamStateClosed:
-		s.code = streamStateClosed
+		s.set(streamStateClosed)
 	}
 }
 
 func (s *streamState) CloseRemote() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	switch s.code {
 	case streamStateOpen:
-		s.code = streamStateHalfClosedRemote
+		s.set(streamStateHalfClosedRemote)
 	case streamStateHalfClosedLocal:
-		s.code = streamStateClosed
+		s.set(streamStateClosed)
 	case streamStateHalfClosedRemote:
-		s.code = streamStateHalfClosedRemote
+		s.set(streamStateHalfClosedRemote)
 	case streamStateClosed:
-		s.code = streamStateClosed
+		s.set(streamStateClosed)
 	}
 }
 
 func (s *streamState) SetError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.err = err
 }
 
 func (s *streamState) RecvResetStream() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	switch {
 	case s.err != nil:
 		return s.err
@@ -79,6 +111,8 @@ func (s *streamState) RecvResetStream() error {
 }
 
 func (s *streamState) RecvData() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	switch {
 	case s.err != nil:
 		return s.err
@@ -92,19 +126,21 @@ func (s *streamState) RecvData() error {
 }
 
 func (s *streamState) SendResetStream() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	switch {
 	case s.err != nil:
 		return s.err
 	case s.code == streamStateClosed:
 		return ClosedStreamErr
-	case s.code == streamStateHalfClosedLocal:
-		return ClosedStreamErr
 	default:
 		return nil
 	}
 }
 
 func (s *streamState) SendData() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	switch {
 	case s.err != nil:
 		return s.err
@@ -2,6 +2,7 @@ package wire
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -51,11 +52,13 @@ func NextAs[T Framer](t *testing.T, d *dummyConn) T {
 	return v
 }
 
-func (d *dummyConn) Write(fr *Frame) error {
+func (d *dummyConn) WriteContext(_ context.Context, fr *Frame) error {
 	d.messages = append(d.messages, fr)
 	return nil
 }
 
+func (d *dummyConn) Info() ConnInfo { return ConnInfo{} }
+
 func makeStream() (*dummyConn, Stream) {
 	dummy := &dummyConn{}
 	return dummy, NewStream(1, dummy)
@@ -164,6 +167,36 @@ func TestStream(t *testing.T) {
 			assert.False(t, data.EndStream)
 		})
 
+		t.Run("calling ReadBlock preserves message boundaries", func(t *testing.T) {
+			_, s := makeStream()
+			s.handleData(&DataFrame{StreamID: 1, Payload: []byte("hel")})
+			s.handleData(&DataFrame{StreamID: 1, EndData: true, Payload: []byte("lo")})
+
+			data, endData, err := s.ReadBlock()
+			require.NoError(t, err)
+			assert.Equal(t, []byte("hel"), data)
+			assert.False(t, endData)
+
+			data, endData, err = s.ReadBlock()
+			require.NoError(t, err)
+			assert.Equal(t, []byte("lo"), data)
+			assert.True(t, endData)
+		})
+
+		t.Run("calling WriteBlock sets END_DATA as requested", func(t *testing.T) {
+			d, s := makeStream()
+			require.NoError(t, s.WriteBlock([]byte("hel"), false))
+			require.NoError(t, s.WriteBlock([]byte("lo"), true))
+
+			d1 := NextAs[*DataFrame](t, d)
+			d2 := NextAs[*DataFrame](t, d)
+			assert.Equal(t, []byte("hel"), d1.Payload)
+			assert.False(t, d1.EndData)
+			assert.Equal(t, []byte("lo"), d2.Payload)
+			assert.True(t, d2.EndData)
+			assert.False(t, d2.EndStream)
+		})
+
 		t.Run("calling Write with END_STREAM causes a local half-close", func(t *testing.T) {
 			_, s := makeStream()
 			err := s.Write([]byte("hello"), true)
@@ -231,6 +264,63 @@ func TestStream(t *testing.T) {
 			assert.True(t, d2.EndData)
 			assert.Equal(t, len(random)-maxPayload, len(d2.Payload))
 		})
+
+		t.Run("large data only sets END_STREAM on the last frame", func(t *testing.T) {
+			d, s := makeStream()
+
+			err := s.Write(random, true)
+			require.NoError(t, err)
+
+			d1 := NextAs[*DataFrame](t, d)
+			d2 := NextAs[*DataFrame](t, d)
+
+			assert.False(t, d1.EndStream)
+			assert.True(t, d2.EndStream)
+			assert.True(t, d2.EndData)
+		})
+	})
+
+	t.Run("message size limits", func(t *testing.T) {
+		t.Run("writing a message larger than the limit fails", func(t *testing.T) {
+			d := &dummyConn{}
+			s := newStream(1, d, Options{MaxSendMessageSize: 4})
+
+			err := s.Write([]byte("hello"), false)
+			var sizeErr *MessageTooLargeError
+			require.ErrorAs(t, err, &sizeErr)
+			assert.False(t, sizeErr.Recv)
+			assert.Nil(t, d.Next())
+
+			require.NoError(t, s.Write([]byte("arf!"), false))
+			assert.Equal(t, streamStateOpen, s.state.code)
+		})
+
+		t.Run("receiving a message larger than the limit fails the reader", func(t *testing.T) {
+			d := &dummyConn{}
+			s := newStream(1, d, Options{MaxRecvMessageSize: 4})
+
+			s.handleData(&DataFrame{StreamID: 1, Payload: []byte{0x01, 0x02, 0x03}})
+			s.handleData(&DataFrame{StreamID: 1, EndData: true, Payload: []byte{0x04, 0x05}})
+
+			_, err := io.ReadAll(s)
+			var sizeErr *MessageTooLargeError
+			require.ErrorAs(t, err, &sizeErr)
+			assert.True(t, sizeErr.Recv)
+			assert.Equal(t, 5, sizeErr.Size)
+		})
+
+		t.Run("the limit applies to each message", func(t *testing.T) {
+			d := &dummyConn{}
+			s := newStream(1, d, Options{MaxRecvMessageSize: 4})
+
+			s.handleData(&DataFrame{StreamID: 1, EndData: true, Payload: []byte{0x01, 0x02, 0x03}})
+			s.handleData(&DataFrame{StreamID: 1, EndData: true, Payload: []byte{0x04, 0x05, 0x06}})
+
+			buf := make([]byte, 6)
+			_, err := io.ReadFull(s, buf)
+			require.NoError(t, err)
+			assert.Equal(t, []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}, buf)
+		})
 	})
 
 	t.Run("closed state", func(t *testing.T) {
@@ -331,6 +421,22 @@ func TestStream(t *testing.T) {
 			err := s.CloseLocal()
 			assert.Equal(t, &StreamResetError{ErrorCodeCancel}, err)
 		})
+
+		t.Run("a blocked Read returns a StreamResetError", func(t *testing.T) {
+			_, s := makeStream()
+			errCh := make(chan error, 1)
+			go func() {
+				_, err := s.Read(make([]byte, 8))
+				errCh <- err
+			}()
+
+			s.handleResetStream(&ResetStreamFrame{
+				StreamID:  1,
+				ErrorCode: ErrorCodeCancel,
+			})
+			assert.Equal(t, &StreamResetError{ErrorCodeCancel}, <-errCh)
+			assert.Equal(t, &StreamResetError{ErrorCodeCancel}, s.Err())
+		})
 	})
 
 	t.Run("half-closed remote state", func(t *testing.T) {
@@ -388,5 +494,16 @@ func TestStream(t *testing.T) {
 			})
 			assert.Equal(t, streamStateClosed, s.(*stream).state.code)
 		})
+
+		t.Run("calling Reset issues a ResetStreamFrame", func(t *testing.T) {
+			d, s := makeStream()
+			require.NoError(t, s.CloseLocal())
+			NextAs[*DataFrame](t, d)
+
+			require.NoError(t, s.Reset(ErrorCodeCancel))
+			rs := NextAs[*ResetStreamFrame](t, d)
+			assert.Equal(t, ErrorCodeCancel, rs.ErrorCode)
+			assert.Equal(t, streamStateClosed, s.(*stream).state.code)
+		})
 	})
 }